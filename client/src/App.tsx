@@ -1,5 +1,4 @@
 import { ReactFlowProvider } from '@xyflow/react';
-import type { WorkflowNodeDisplaySelector } from './types/workflow';
 import '@xyflow/react/dist/style.css';
 import { QueryClient, QueryClientProvider } from '@tanstack/react-query';
 import { WorkflowPage } from './pages/WorkflowPage';
@@ -13,35 +12,6 @@ import Dashboard from './pages/Dashboard';
 import { ConnectionsPage } from './pages/ConnectionsPage';
 import { TemplatesPage } from './pages/TemplatesPage';
 
-// TODO: Get these from db
-const allNodes: WorkflowNodeDisplaySelector[] = [
-  {
-    taskName: 'get-email',
-    serviceName: 'gmail',
-    type: 'listener',
-  },
-  {
-    taskName: 'send-email',
-    serviceName: 'gmail',
-    type: 'action',
-  },
-  {
-    taskName: 'open-pr',
-    serviceName: 'github',
-    type: 'action',
-  },
-  {
-    taskName: 'get-issue',
-    serviceName: 'github',
-    type: 'listener',
-  },
-  {
-    taskName: 'store-file',
-    serviceName: 'drive',
-    type: 'action',
-  },
-];
-
 const queryClient = new QueryClient();
 
 const Layout = ({ children }: { children: ReactNode }) => (
@@ -76,7 +46,7 @@ export function App() {
               <AuthGuard>
                 <ReactFlowProvider>
                   <Layout>
-                    <WorkflowPage allNodes={allNodes} />
+                    <WorkflowPage />
                   </Layout>
                 </ReactFlowProvider>
               </AuthGuard>
@@ -88,7 +58,7 @@ export function App() {
               <AuthGuard>
                 <ReactFlowProvider>
                   <Layout>
-                    <WorkflowPage allNodes={allNodes} />
+                    <WorkflowPage />
                   </Layout>
                 </ReactFlowProvider>
               </AuthGuard>
@@ -1,11 +1,20 @@
 import type { WorkflowNodeDisplaySelector } from '../types/workflow';
 import { Handle, Position } from '@xyflow/react';
+import { Typography } from '@mui/material';
 import { NodeBase } from './NodeBase';
 
 export function Node({ data }: { data: WorkflowNodeDisplaySelector }) {
   return (
     <>
       <NodeBase data={data} />
+      {data.webhookToken && (
+        <Typography
+          fontSize={10}
+          sx={{ width: '200px', wordBreak: 'break-all', mt: 0.5 }}
+        >
+          POST http://localhost:3000/hooks/{data.webhookToken}
+        </Typography>
+      )}
       {data.type !== 'action' && (
         <Handle type="source" position={Position.Right} />
       )}
@@ -1,11 +1,12 @@
 import { Card, CardContent, Chip, Typography } from '@mui/material';
 import type { WorkflowNodeDisplaySelector } from '../types/workflow';
-import { serviceToUi } from '../utils/service-to-ui';
+import { defaultUi, serviceToUi } from '../utils/service-to-ui';
 
 export function NodeBase({ data }: { data: WorkflowNodeDisplaySelector }) {
   console.log(data);
-  const mainColor = serviceToUi[data.serviceName].color;
-  const secondaryColor = serviceToUi[data.serviceName].textColor;
+  const ui = serviceToUi[data.serviceName] ?? defaultUi;
+  const mainColor = ui.color;
+  const secondaryColor = ui.textColor;
 
   return (
     <Card
@@ -25,7 +26,7 @@ export function NodeBase({ data }: { data: WorkflowNodeDisplaySelector }) {
         }}
       >
         <Typography fontSize={14} color={secondaryColor}>
-          {data.taskName}
+          {data.displayName ?? data.taskName}
         </Typography>
         <Chip
           label={data.serviceName}
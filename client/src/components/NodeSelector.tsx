@@ -1,23 +1,69 @@
-import { Box, Button } from '@mui/material';
+import { Alert, Box, Button, CircularProgress } from '@mui/material';
 import type {
   WorkflowNodeDisplay,
   WorkflowNodeDisplaySelector,
 } from '../types/workflow';
+import type { CatalogResponse, JsonSchema } from '../types/catalog';
 import type { NodeChange } from '@xyflow/react';
+import { useQuery } from '@tanstack/react-query';
+import axios from 'axios';
 import { v4 } from 'uuid';
 import { NodeBase } from './NodeBase';
 
 interface Props {
-  allNodes: WorkflowNodeDisplaySelector[];
   onNodeAdd: (change: NodeChange<WorkflowNodeDisplay>) => void;
 }
 
-export function NodeSelector({ allNodes, onNodeAdd }: Props) {
+// The defaults of the config's properties, the starting config of a new node
+function defaultConfig(schema: JsonSchema | null): Record<string, unknown> {
+  const config: Record<string, unknown> = {};
+  for (const [name, property] of Object.entries(schema?.properties ?? {})) {
+    if (property.default !== undefined) {
+      config[name] = property.default;
+    }
+  }
+  return config;
+}
+
+// Offers the tasks of the registered workers, see GET /api/catalog
+export function NodeSelector({ onNodeAdd }: Props) {
+  const { data, isLoading, isError } = useQuery<CatalogResponse>({
+    queryKey: ['catalog'],
+    queryFn: async () => {
+      const token = localStorage.getItem('token');
+      const res = await axios.get('http://localhost:3000/api/catalog', {
+        headers: {
+          Authorization: `Bearer ${token}`,
+        },
+      });
+      return res.data;
+    },
+  });
+
+  if (isLoading) {
+    return <CircularProgress size={24} />;
+  }
+  if (isError || !data) {
+    return <Alert severity="error">Could not load the available tasks</Alert>;
+  }
+
+  const allNodes = data.services.flatMap(service =>
+    service.tasks.map(task => ({
+      node: {
+        taskName: task.name,
+        serviceName: service.service_name,
+        type: task.node_type,
+        displayName: task.display_name,
+      } satisfies WorkflowNodeDisplaySelector,
+      config: defaultConfig(task.config_schema),
+    }))
+  );
+
   return (
     <Box>
-      {allNodes.map((node, idx) => (
+      {allNodes.map(({ node, config }) => (
         <Button
-          key={idx}
+          key={`${node.serviceName}/${node.taskName}`}
           onClick={() =>
             onNodeAdd({
               item: {
@@ -28,7 +74,7 @@ export function NodeSelector({ allNodes, onNodeAdd }: Props) {
                 },
                 type: 'node',
                 data: {
-                  config: {},
+                  config,
                   dbId: '',
                   serviceName: node.serviceName,
                   taskName: node.taskName,
@@ -0,0 +1,133 @@
+import { useState } from 'react';
+import {
+  Button,
+  Checkbox,
+  Dialog,
+  DialogActions,
+  DialogContent,
+  DialogTitle,
+  FormControlLabel,
+  MenuItem,
+  TextField,
+} from '@mui/material';
+import { useMutation } from '@tanstack/react-query';
+import axios from 'axios';
+import type {
+  RunWorkflowPayload,
+  RunWorkflowResponse,
+  WorkflowNodeDisplay,
+} from '../types/workflow';
+
+interface Props {
+  open: boolean;
+  workflowId: number;
+  listeners: WorkflowNodeDisplay[];
+  onClose: () => void;
+  onStarted: (executionId: number) => void;
+  onError: (message: string) => void;
+}
+
+// Runs the saved workflow once with a test payload, also when it is not active.
+// A dry run records what the actions would have sent, using the mock_output of their settings
+export function RunWorkflowDialog({
+  open,
+  workflowId,
+  listeners,
+  onClose,
+  onStarted,
+  onError,
+}: Props) {
+  const [listener, setListener] = useState('');
+  const [payload, setPayload] = useState('{}');
+  const [dryRun, setDryRun] = useState(false);
+
+  const mutation = useMutation<RunWorkflowResponse, Error, void>({
+    mutationFn: async () => {
+      let parsed: Record<string, unknown>;
+      try {
+        parsed = JSON.parse(payload || '{}');
+      } catch {
+        throw new Error('The payload is not valid JSON');
+      }
+
+      const body: RunWorkflowPayload = {
+        listener: listener || listeners[0]?.id,
+        payload: parsed,
+        dry_run: dryRun,
+      };
+      const token = localStorage.getItem('token');
+      const response = await axios.post(
+        `http://localhost:3000/api/workflows/${workflowId}/run`,
+        body,
+        {
+          headers: {
+            Authorization: `Bearer ${token}`,
+          },
+        }
+      );
+      return response.data;
+    },
+    onSuccess: ({ execution_id }) => {
+      onStarted(execution_id);
+      onClose();
+    },
+    onError: error => {
+      const message = axios.isAxiosError(error)
+        ? (error.response?.data?.message ?? error.message)
+        : error.message;
+      onError(message);
+    },
+  });
+
+  return (
+    <Dialog open={open} onClose={onClose} fullWidth maxWidth="sm">
+      <DialogTitle>Run workflow</DialogTitle>
+      <DialogContent
+        sx={{ display: 'flex', flexDirection: 'column', gap: 2, pt: 1 }}
+      >
+        {listeners.length > 1 && (
+          <TextField
+            select
+            label="Listener"
+            value={listener || listeners[0].id}
+            onChange={e => setListener(e.target.value)}
+            sx={{ mt: 1 }}
+          >
+            {listeners.map(node => (
+              <MenuItem key={node.id} value={node.id}>
+                {node.data.serviceName} / {node.data.taskName} ({node.id})
+              </MenuItem>
+            ))}
+          </TextField>
+        )}
+        <TextField
+          label="Test payload"
+          value={payload}
+          onChange={e => setPayload(e.target.value)}
+          multiline
+          minRows={6}
+          sx={{ mt: 1, fontFamily: 'monospace' }}
+        />
+        <FormControlLabel
+          control={
+            <Checkbox
+              checked={dryRun}
+              onChange={e => setDryRun(e.target.checked)}
+            />
+          }
+          label="Dry run: record the actions instead of running them"
+        />
+      </DialogContent>
+      <DialogActions>
+        <Button onClick={onClose}>Cancel</Button>
+        <Button
+          variant="contained"
+          onClick={() => mutation.mutate()}
+          disabled={mutation.isPending || listeners.length === 0}
+        >
+          {mutation.isPending ? 'Starting...' : 'Run'}
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
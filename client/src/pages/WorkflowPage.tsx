@@ -27,6 +27,7 @@ import {
   CircularProgress,
 } from '@mui/material';
 import SaveIcon from '@mui/icons-material/Save';
+import PlayArrowIcon from '@mui/icons-material/PlayArrow';
 import axios from 'axios';
 import type {
   EdgeData,
@@ -34,28 +35,26 @@ import type {
   WorkflowData,
   WorkflowEdgeDisplay,
   WorkflowNodeDisplay,
-  WorkflowNodeDisplaySelector,
 } from '../types/workflow';
 import { toCreateWorkflowDto } from '../utils/transform';
 import { NodeSelector } from '../components/NodeSelector';
 import { Node } from '../components/Node';
+import { RunWorkflowDialog } from '../components/RunWorkflowDialog';
 import { useNavigate, useParams } from 'react-router-dom';
 
 const nodeTypes: NodeTypes = {
   node: Node,
 };
 
-interface Props {
-  allNodes: WorkflowNodeDisplaySelector[];
-}
-
-export function WorkflowPage({ allNodes }: Props) {
+export function WorkflowPage() {
   const { id } = useParams<{ id: string }>();
   const workflowId = id ? Number(id) : undefined;
 
   const [nodes, setNodes] = useState<WorkflowNodeDisplay[]>([]);
   const [edges, setEdges] = useState<WorkflowEdgeDisplay[]>([]);
   const [workflowName, setWorkflowName] = useState('');
+  const [maxDurationSeconds, setMaxDurationSeconds] = useState<number>();
+  const [runDialogOpen, setRunDialogOpen] = useState(false);
 
   // UI State for feedback
   const [toast, setToast] = useState<{
@@ -95,6 +94,7 @@ export function WorkflowPage({ allNodes }: Props) {
   useEffect(() => {
     if (existingWorkflow) {
       setWorkflowName(existingWorkflow.workflow.name);
+      setMaxDurationSeconds(existingWorkflow.workflow.max_duration_seconds);
 
       // Map Backend Nodes -> Frontend Nodes
       const loadedNodes = existingWorkflow.nodes.map((node: NodeData) => ({
@@ -108,6 +108,8 @@ export function WorkflowPage({ allNodes }: Props) {
           type: node.type,
           config: JSON.parse(node.config),
           credentialId: node.credential_id,
+          settings: node.settings ? JSON.parse(node.settings) : undefined,
+          webhookToken: node.webhook_token,
         },
       }));
       console.log(loadedNodes);
@@ -117,6 +119,7 @@ export function WorkflowPage({ allNodes }: Props) {
       // Map Backend Edges -> Frontend Edges
       const loadedEdges = existingWorkflow.edges.map((edge: EdgeData) => ({
         id: edge.display_id,
+        label: edge.label || undefined,
         data: {
           dbId: edge.id,
         },
@@ -149,7 +152,8 @@ export function WorkflowPage({ allNodes }: Props) {
         nodes,
         edges,
         workflowName,
-        workflowId
+        workflowId,
+        maxDurationSeconds
       );
       const token = localStorage.getItem('token');
       const response = await axios.post(
@@ -241,22 +245,52 @@ export function WorkflowPage({ allNodes }: Props) {
           />
         </Box>
 
-        <Button
-          variant="contained"
-          startIcon={
-            mutation.isPending ? (
-              <CircularProgress size={20} color="inherit" />
-            ) : (
-              <SaveIcon />
-            )
-          }
-          onClick={() => mutation.mutate()}
-          disabled={mutation.isPending}
-        >
-          {mutation.isPending ? 'Saving...' : 'Save Workflow'}
-        </Button>
+        <Box display="flex" gap={2}>
+          {workflowId && (
+            <Button
+              variant="outlined"
+              startIcon={<PlayArrowIcon />}
+              onClick={() => setRunDialogOpen(true)}
+            >
+              Run now
+            </Button>
+          )}
+          <Button
+            variant="contained"
+            startIcon={
+              mutation.isPending ? (
+                <CircularProgress size={20} color="inherit" />
+              ) : (
+                <SaveIcon />
+              )
+            }
+            onClick={() => mutation.mutate()}
+            disabled={mutation.isPending}
+          >
+            {mutation.isPending ? 'Saving...' : 'Save Workflow'}
+          </Button>
+        </Box>
       </Paper>
 
+      {workflowId && (
+        <RunWorkflowDialog
+          open={runDialogOpen}
+          workflowId={workflowId}
+          listeners={nodes.filter(node => node.data.type === 'listener')}
+          onClose={() => setRunDialogOpen(false)}
+          onStarted={executionId =>
+            setToast({
+              open: true,
+              message: `Execution ${executionId} started`,
+              severity: 'success',
+            })
+          }
+          onError={message =>
+            setToast({ open: true, message, severity: 'error' })
+          }
+        />
+      )}
+
       <Box sx={{ display: 'flex', flexGrow: 1, overflow: 'hidden' }}>
         <Box
           sx={{
@@ -271,7 +305,6 @@ export function WorkflowPage({ allNodes }: Props) {
             Available Tasks
           </Typography>
           <NodeSelector
-            allNodes={allNodes}
             onNodeAdd={change => onNodesChange([change])}
           />
         </Box>
@@ -0,0 +1,30 @@
+// A task of a service as its worker describes it
+export interface CatalogTask {
+  name: string;
+  node_type: 'listener' | 'action';
+  display_name: string;
+  // JSON Schemas, null when the worker gives none
+  config_schema: JsonSchema | null;
+  output_schema: JsonSchema | null;
+  oauth_scopes: string[];
+}
+
+export interface CatalogService {
+  service_name: string;
+  tasks: CatalogTask[];
+}
+
+export interface CatalogResponse {
+  services: CatalogService[];
+}
+
+export interface JsonSchema {
+  type?: string | string[];
+  title?: string;
+  description?: string;
+  properties?: Record<string, JsonSchema>;
+  required?: string[];
+  items?: JsonSchema;
+  enum?: unknown[];
+  default?: unknown;
+}
@@ -10,8 +10,11 @@ export interface WorkflowNodeDisplay {
     serviceName: string;
     taskName: string;
     credentialId?: number;
-    type: 'listener' | 'action' | 'transformer';
+    type: 'listener' | 'action' | 'transformer' | 'condition' | 'join' | 'loop';
     config: Record<string, unknown>;
+    settings?: Record<string, unknown>;
+    // Set for saved webhook listeners
+    webhookToken?: string;
   };
 }
 
@@ -19,6 +22,7 @@ export interface WorkflowEdgeDisplay {
   id: string;
   source: string;
   target: string;
+  label?: string;
   data?: {
     dbId?: string;
   };
@@ -27,7 +31,11 @@ export interface WorkflowEdgeDisplay {
 export type WorkflowNodeDisplaySelector = {
   taskName: string;
   serviceName: string;
-  type: 'listener' | 'action' | 'transformer';
+  type: 'listener' | 'action' | 'transformer' | 'condition' | 'join' | 'loop';
+  // From the task catalog, falls back to the task name
+  displayName?: string;
+  // Set for saved webhook listeners, their URL is /hooks/{webhookToken}
+  webhookToken?: string;
 };
 
 export interface CreateWorkflowNode {
@@ -35,10 +43,11 @@ export interface CreateWorkflowNode {
   displayId: string;
   serviceName: string;
   taskName: string;
-  type: 'listener' | 'action' | 'transformer';
+  type: 'listener' | 'action' | 'transformer' | 'condition' | 'join' | 'loop';
   position: string;
   config: string;
   credential_id?: number;
+  settings?: string;
 }
 
 export interface CreateWorkflowEdge {
@@ -46,12 +55,14 @@ export interface CreateWorkflowEdge {
   from: string;
   to: string;
   displayId: string;
+  label?: string;
 }
 
 export interface CreateWorkflowPayload {
   workflow: {
     id?: number;
     name: string;
+    max_duration_seconds?: number;
   };
   nodes: CreateWorkflowNode[];
   edges: CreateWorkflowEdge[];
@@ -64,6 +75,7 @@ export interface Workflow {
   name: string;
   active: boolean;
   user_id: number;
+  max_duration_seconds: number;
 }
 
 export interface NodeData {
@@ -72,10 +84,12 @@ export interface NodeData {
   service_name: string;
   task_name: string;
   workflow_id: number;
-  type: 'listener' | 'action' | 'transformer';
+  type: 'listener' | 'action' | 'transformer' | 'condition' | 'join' | 'loop';
   position: string;
   config: string;
   credential_id?: number;
+  settings?: string;
+  webhook_token?: string;
 }
 
 export interface EdgeData {
@@ -84,6 +98,7 @@ export interface EdgeData {
   workflow_id: number;
   node_from: string;
   node_to: string;
+  label: string;
 }
 
 export interface WorkflowData {
@@ -91,3 +106,15 @@ export interface WorkflowData {
   nodes: NodeData[];
   edges: EdgeData[];
 }
+
+export interface RunWorkflowPayload {
+  // Display id or id of the listener node the run starts at
+  listener?: string;
+  payload?: Record<string, unknown>;
+  // Record the actions instead of sending them
+  dry_run?: boolean;
+}
+
+export interface RunWorkflowResponse {
+  execution_id: number;
+}
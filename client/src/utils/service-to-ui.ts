@@ -20,3 +20,9 @@ export const serviceToUi: Record<string, UiConfig> = {
     textColor: '#eeeeee',
   },
 };
+
+// Services without their own colors, e.g. a newly registered worker
+export const defaultUi: UiConfig = {
+  color: '#f0f0f0',
+  textColor: '#404040',
+};
@@ -10,11 +10,13 @@ export function toCreateWorkflowDto(
   nodes: WorkflowNodeDisplay[],
   edges: WorkflowEdgeDisplay[],
   name: string,
-  workflowId?: number
+  workflowId?: number,
+  maxDurationSeconds?: number
 ): CreateWorkflowPayload {
   const workflow = {
     id: workflowId,
     name,
+    max_duration_seconds: maxDurationSeconds,
   };
 
   const nodesToSave: CreateWorkflowNode[] = nodes.map(node => ({
@@ -26,6 +28,7 @@ export function toCreateWorkflowDto(
     config: JSON.stringify(node.data.config) || '{}',
     position: JSON.stringify(node.position),
     credential_id: node.data.credentialId,
+    settings: node.data.settings ? JSON.stringify(node.data.settings) : undefined,
   }));
 
   const edgesToSave = edges.map(edge => ({
@@ -33,6 +36,7 @@ export function toCreateWorkflowDto(
     to: edge.target,
     displayId: edge.id,
     id: edge.data?.dbId,
+    label: edge.label,
   })) satisfies CreateWorkflowEdge[];
 
   return {
@@ -0,0 +1,37 @@
+-- Brings a database created from an older schema.sql up to date. The statements are in the order
+-- the columns were added, run the ones after the last change the database already has.
+-- Tables missing from the database are created with their CREATE TABLE from schema.sql.
+
+-- Condition nodes
+ALTER TABLE workflow_edges ADD COLUMN label VARCHAR(255);
+
+-- Retry policies
+ALTER TABLE workflow_nodes ADD COLUMN settings JSON AFTER config;
+
+-- Sub-workflows
+ALTER TABLE executions
+    ADD COLUMN output JSON AFTER trigger_payload,
+    ADD COLUMN parent_execution_id INT REFERENCES executions(id) ON DELETE SET NULL,
+    ADD COLUMN depth INT NOT NULL DEFAULT 0,
+    ADD INDEX idx_executions_parent (parent_execution_id);
+
+-- Cancellation and max duration
+ALTER TABLE workflows ADD COLUMN max_duration_seconds INT NOT NULL DEFAULT 0;
+ALTER TABLE executions ADD COLUMN cancel_requested BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Dry runs
+ALTER TABLE executions ADD COLUMN dry_run BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE execution_steps ADD COLUMN simulated_request JSON;
+
+-- Replays
+ALTER TABLE executions ADD COLUMN replay_of_execution_id INT REFERENCES executions(id) ON DELETE SET NULL;
+
+-- Polling listeners
+ALTER TABLE trigger_states ADD COLUMN seen_items MEDIUMTEXT;
+
+-- Error classes of failed steps
+ALTER TABLE execution_steps ADD COLUMN error_class VARCHAR(64) AFTER error;
+ALTER TABLE execution_step_attempts MODIFY COLUMN error_class VARCHAR(64);
+
+-- Invoke steps which started a child execution
+ALTER TABLE executions ADD COLUMN parent_step_id INT UNIQUE REFERENCES execution_steps(id) ON DELETE SET NULL AFTER depth;
@@ -1,3 +1,5 @@
+-- Creates a new database, migrations.sql updates one created from an older version
+
 CREATE TABLE services (
     service_name VARCHAR(50) PRIMARY KEY,
     display_name VARCHAR(100) NOT NULL,
@@ -21,7 +23,9 @@ CREATE TABLE workflows (
 
     name VARCHAR(255) NOT NULL,
     active BOOLEAN DEFAULT FALSE,
-    user_id INT NOT NULL
+    user_id INT NOT NULL,
+    -- Executions running longer are stopped as timed out, 0 means no limit
+    max_duration_seconds INT NOT NULL DEFAULT 0
 );
 
 CREATE TABLE workflow_nodes (
@@ -38,6 +42,8 @@ CREATE TABLE workflow_nodes (
     type VARCHAR(50) NOT NULL,
 
     config JSON,
+    -- How the orchestrator runs the node (retries...), config is what the worker gets
+    settings JSON,
     credential_id INT REFERENCES credentials(id),
     position JSON
 );
@@ -50,7 +56,9 @@ CREATE TABLE workflow_edges (
     node_from VARCHAR(255) REFERENCES workflow_nodes(id),
     node_to VARCHAR(255) REFERENCES workflow_nodes(id),
     workflow_id INT NOT NULL,
-    display_id VARCHAR(255) NOT NULL
+    display_id VARCHAR(255) NOT NULL,
+    -- Which branch of a condition node the edge belongs to
+    label VARCHAR(255)
 );
 
 CREATE TABLE users (
@@ -66,7 +74,9 @@ CREATE TABLE users (
 CREATE TABLE trigger_states (
     node_id VARCHAR(255) PRIMARY KEY REFERENCES workflow_nodes(id),
     last_check_at TIMESTAMP,
-    last_message_id VARCHAR(100)
+    last_message_id VARCHAR(100),
+    -- JSON array of the ids of the items a poller already fired for, newest last
+    seen_items MEDIUMTEXT
 );
 
 CREATE TABLE email_templates (
@@ -79,4 +89,109 @@ CREATE TABLE email_templates (
     subject VARCHAR(255) NOT NULL,
     body TEXT NOT NULL,
     email_to VARCHAR(255) NOT NULL
-);
\ No newline at end of file
+);
+
+CREATE TABLE executions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    workflow_id INT NOT NULL REFERENCES workflows(id) ON DELETE CASCADE,
+    listener_node_id VARCHAR(255) NOT NULL,
+
+    status INT NOT NULL,
+    trigger_payload JSON,
+    -- What the workflow returns to a parent execution which invoked it
+    output JSON,
+    error TEXT,
+    started_at DATETIME(3),
+    finished_at DATETIME(3),
+
+    -- Set on executions of sub-workflows, depth is 0 for executions started by a trigger
+    parent_execution_id INT REFERENCES executions(id) ON DELETE SET NULL,
+    depth INT NOT NULL DEFAULT 0,
+    -- The invoke step of the parent which started the execution, a step starts at most one
+    parent_step_id INT UNIQUE REFERENCES execution_steps(id) ON DELETE SET NULL,
+
+    -- Set by CancelExecution, the worker running the execution stops it
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Actions are not sent to their workers, see execution_steps.simulated_request
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    -- The execution whose trigger payload (and maybe succeeded steps) this one reuses
+    replay_of_execution_id INT REFERENCES executions(id) ON DELETE SET NULL,
+
+    INDEX idx_executions_workflow (workflow_id, id),
+    INDEX idx_executions_parent (parent_execution_id)
+);
+
+CREATE TABLE execution_steps (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    execution_id INT NOT NULL REFERENCES executions(id) ON DELETE CASCADE,
+    node_id VARCHAR(255) NOT NULL,
+    display_id VARCHAR(255) NOT NULL,
+
+    status INT NOT NULL,
+    input JSON,
+    output JSON,
+    error TEXT,
+    -- Set when the node itself failed, not when the execution was interrupted while it ran
+    error_class VARCHAR(64),
+    started_at DATETIME(3) NOT NULL,
+    finished_at DATETIME(3),
+    -- What an action of a dry run would have sent: service, task and resolved config
+    simulated_request JSON,
+
+    INDEX idx_execution_steps_execution (execution_id, id)
+);
+
+
+CREATE TABLE execution_step_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    step_id INT NOT NULL REFERENCES execution_steps(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+
+    status INT NOT NULL,
+    error TEXT,
+    error_class VARCHAR(64),
+    started_at DATETIME(3) NOT NULL,
+    finished_at DATETIME(3) NOT NULL,
+
+    INDEX idx_execution_step_attempts_step (step_id, attempt)
+);
+
+-- Executions waiting for an orchestrator worker, see services/orchestrator/mysql-queue.go
+CREATE TABLE execution_jobs (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    execution_id INT NOT NULL UNIQUE REFERENCES executions(id) ON DELETE CASCADE,
+    run_at DATETIME(3) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+
+    -- Set while a worker runs the job, the lease is extended by heartbeats
+    lease_token VARCHAR(64),
+    leased_by VARCHAR(255),
+    lease_expires_at DATETIME(3),
+
+    INDEX idx_execution_jobs_run_at (run_at)
+);
+-- Task workers by service, see services/orchestrator/registry.go
+CREATE TABLE workers (
+    service_name VARCHAR(50) PRIMARY KEY,
+    address VARCHAR(255) NOT NULL,
+    -- JSON array of the task names the worker runs
+    tasks JSON NOT NULL,
+    registered_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Tokens of the URLs of webhook listeners, POST /hooks/{token}
+CREATE TABLE webhooks (
+    node_id VARCHAR(255) PRIMARY KEY REFERENCES workflow_nodes(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    token VARCHAR(64) NOT NULL UNIQUE
+);
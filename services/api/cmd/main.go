@@ -48,7 +48,25 @@ func main() {
 		log.Fatalf("Did not connect to Workflow Service: %v", err)
 	}
 	defer workflowConn.Close()
-	workflowService := services.Workflow{ GrpcClient: pb.NewWorkflowServiceClient(workflowConn) }
+    orchestratorConn, err := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Did not connect to Orchestrator: %v", err)
+	}
+	defer orchestratorConn.Close()
+
+	workflowService := services.Workflow{
+		GrpcClient: pb.NewWorkflowServiceClient(workflowConn),
+		Orchestrator: pb.NewOrchestratorClient(orchestratorConn),
+	}
+	executionService := services.Execution{
+		GrpcClient: pb.NewExecutionServiceClient(workflowConn),
+		Orchestrator: pb.NewOrchestratorClient(orchestratorConn),
+	}
+	webhookService := services.Webhook{
+		Db: db,
+		Orchestrator: pb.NewOrchestratorClient(orchestratorConn),
+		Executions: pb.NewExecutionServiceClient(workflowConn),
+	}
 
 
 	var googleOauthConfig = &oauth2.Config{
@@ -65,6 +83,8 @@ func main() {
         Validator: validator.New(),
         UserService: &userService,
         WorkflowService: &workflowService,
+        ExecutionService: &executionService,
+        WebhookService: &webhookService,
 		OAuthConfig: googleOauthConfig,
     }
 
@@ -78,6 +98,8 @@ func main() {
     app.Router.Post("/api/register", app.RegisterUser)
     app.Router.Post("/api/login", app.LoginUser)
 	app.Router.Get("/api/auth/google/callback", app.GoogleCallback)
+	// Public, the token in the URL is the credential
+	app.Router.Post("/hooks/{token}", app.TriggerWebhook)
     
     app.Router.Group(func(r chi.Router) {
 		r.Use(middleware.AuthMiddleware)
@@ -86,6 +108,13 @@ func main() {
 		r.Post("/api/workflows", app.CreateWorkflow)
         r.Patch("/api/workflows/{id}/activate", app.ActivateWorkflow)
         r.Get("/api/workflows/{id}", app.GetWorkflowById)
+        r.Get("/api/workflows/{id}/executions", app.GetWorkflowExecutions)
+        r.Post("/api/workflows/{id}/run", app.RunWorkflow)
+        r.Get("/api/executions/{id}", app.GetExecution)
+        r.Get("/api/executions/{id}/steps", app.GetExecutionSteps)
+        r.Post("/api/executions/{id}/cancel", app.CancelExecution)
+        r.Post("/api/executions/{id}/replay", app.ReplayExecution)
+		r.Get("/api/catalog", app.GetCatalog)
 		r.Get("/api/connections", app.GetConnections)
 		r.Get("/api/auth/google/login", app.GoogleLogin)
 		r.Get("/api/templates", app.GetTemplates)
@@ -9,14 +9,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/services/api/services"
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/services/api/utils"
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/dto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
 	"golang.org/x/oauth2"
 
@@ -31,9 +34,14 @@ type App struct {
 	Validator *validator.Validate
 	UserService *services.User
 	WorkflowService *services.Workflow
+	ExecutionService *services.Execution
+	WebhookService *services.Webhook
 	OAuthConfig *oauth2.Config
 }
 
+// Largest body a webhook accepts
+const maxWebhookBody = 1 << 20
+
 func (app *App) GetWorkflows(w http.ResponseWriter, r *http.Request) {
 	res, err := app.WorkflowService.GetWorkflows(r.Context())
 	if err != nil {
@@ -67,6 +75,10 @@ func (app *App) CreateWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	res, err := app.WorkflowService.CreateWorkflow(r.Context(), payload)
 	if err != nil {
+		if errors.Is(err, errs.InvalidInputError{}) {
+			utils.SendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
@@ -200,12 +212,15 @@ func (app *App) GetWorkflowById(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-	// userId := r.Context().Value("user_id").(int64)
-
 	fmt.Println("TEST")
 
     res, err := app.WorkflowService.GetWorkflowById(r.Context(), id)
     if err != nil {
+		var notFound errs.NotFoundError
+		if errors.As(err, &notFound) {
+			utils.SendError(w, http.StatusNotFound, "Workflow not found")
+			return
+		}
 		fmt.Println(err)
 		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
 		return
@@ -220,6 +235,259 @@ func (app *App) GetWorkflowById(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonRes)
 }
 
+// GetCatalog lists the nodes the editor offers, as described by the workers
+func (app *App) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	res, err := app.WorkflowService.GetCatalog(r.Context())
+	if err != nil {
+		fmt.Println(err)
+		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// TriggerWebhook serves the URL of a webhook listener. It is public, the token in the URL identifies the node
+// and its secret can require a signature. It answers 202 with the execution id, or with the output of the
+// workflow when the node waits for it
+func (app *App) TriggerWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.SendError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		utils.SendError(w, http.StatusBadRequest, "Could not read the request body")
+		return
+	}
+
+	res, err := app.WebhookService.Trigger(r.Context(), chi.URLParam(r, "token"), services.WebhookRequest{
+		Method: r.Method,
+		Header: r.Header,
+		Query:  r.URL.Query(),
+		Body:   body,
+	})
+	if err != nil {
+		var notFound errs.NotFoundError
+		var conflict errs.ConflictError
+		switch {
+		case errors.As(err, &notFound):
+			utils.SendError(w, http.StatusNotFound, "Webhook not found")
+		case errors.As(err, &conflict):
+			utils.SendError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrInvalidSignature):
+			utils.SendError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, errs.InvalidInputError{}):
+			utils.SendError(w, http.StatusBadRequest, err.Error())
+		default:
+			fmt.Println(err)
+			utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	if !res.Finished {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(dto.WebhookResponse{ExecutionId: res.ExecutionId})
+		return
+	}
+	if res.Execution.Status != models.Succeeded.String() {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(dto.WebhookResponse{
+			ExecutionId: res.ExecutionId,
+			Status:      res.Execution.Status,
+		})
+		return
+	}
+
+	output := res.Execution.Output
+	if len(output) == 0 {
+		output = json.RawMessage("{}")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(output)
+}
+
+// RunWorkflow starts the workflow from the editor with a test payload instead of waiting for its trigger
+func (app *App) RunWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflowId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid workflow ID")
+		return
+	}
+
+	// The body is optional, an empty one runs the only listener with {}
+	var payload dto.RunWorkflowPayload
+	if err := utils.ParseJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		utils.SendError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	res, err := app.WorkflowService.RunWorkflow(r.Context(), workflowId, payload)
+	if err != nil {
+		var notFound errs.NotFoundError
+		if errors.As(err, &notFound) {
+			utils.SendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, errs.InvalidInputError{}) {
+			utils.SendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fmt.Println(err)
+		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (app *App) GetWorkflowExecutions(w http.ResponseWriter, r *http.Request) {
+	workflowId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid workflow ID")
+		return
+	}
+
+	params := r.URL.Query()
+	query := dto.ListExecutionsQuery{
+		Status: params.Get("status"),
+		Cursor: params.Get("cursor"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			utils.SendError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+	for name, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.SendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s, expected an RFC3339 time", name))
+				return
+			}
+			*target = &parsed
+		}
+	}
+	err = app.Validator.Struct(query)
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, utils.FormValidationErrorMessage(err))
+		return
+	}
+
+	res, err := app.ExecutionService.ListExecutions(r.Context(), workflowId, query)
+	if err != nil {
+		sendExecutionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (app *App) GetExecution(w http.ResponseWriter, r *http.Request) {
+	executionId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid execution ID")
+		return
+	}
+
+	res, err := app.ExecutionService.GetExecution(r.Context(), executionId)
+	if err != nil {
+		sendExecutionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (app *App) GetExecutionSteps(w http.ResponseWriter, r *http.Request) {
+	executionId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid execution ID")
+		return
+	}
+
+	res, err := app.ExecutionService.GetExecutionSteps(r.Context(), executionId)
+	if err != nil {
+		sendExecutionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// CancelExecution stops an execution, a running one is stopped by its worker shortly after
+func (app *App) CancelExecution(w http.ResponseWriter, r *http.Request) {
+	executionId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid execution ID")
+		return
+	}
+
+	res, err := app.ExecutionService.CancelExecution(r.Context(), executionId)
+	if err != nil {
+		sendExecutionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(res)
+}
+
+// ReplayExecution runs a finished execution again, the body picks what is re-run
+func (app *App) ReplayExecution(w http.ResponseWriter, r *http.Request) {
+	executionId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendError(w, http.StatusBadRequest, "Invalid execution ID")
+		return
+	}
+
+	// An empty body replays the whole execution
+	var payload dto.ReplayExecutionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		utils.SendError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	res, err := app.ExecutionService.ReplayExecution(r.Context(), executionId, payload)
+	if err != nil {
+		sendExecutionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(res)
+}
+
+func sendExecutionError(w http.ResponseWriter, err error) {
+	var notFound errs.NotFoundError
+	if errors.As(err, &notFound) {
+		utils.SendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var conflict errs.ConflictError
+	if errors.As(err, &conflict) {
+		utils.SendError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errs.InvalidInputError{}) {
+		utils.SendError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	fmt.Println(err)
+	utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+}
+
 func generateState(userID int64) string {
     data := fmt.Sprintf("%d", userID)
     h := hmac.New(sha256.New, []byte(os.Getenv("OAUTH_STATE_SECRET")))
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/dto"
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+type Execution struct {
+	GrpcClient pb.ExecutionServiceClient
+	// Queues the replays
+	Orchestrator pb.OrchestratorClient
+}
+
+func (s *Execution) ListExecutions(ctx context.Context, workflowId int, query dto.ListExecutionsQuery) (*dto.ListExecutionsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	req := &pb.ListExecutionsRequest{
+		WorkflowId: int64(workflowId),
+		UserId:     userId,
+		Cursor:     query.Cursor,
+		Limit:      int32(query.Limit),
+	}
+	if query.Status != "" {
+		req.Status = &query.Status
+	}
+	if query.From != nil {
+		req.From = timestamppb.New(*query.From)
+	}
+	if query.To != nil {
+		req.To = timestamppb.New(*query.To)
+	}
+
+	res, err := s.GrpcClient.ListExecutions(ctx, req)
+	if err != nil {
+		return nil, mapExecutionError(err)
+	}
+
+	executions := make([]dto.Execution, 0, len(res.Executions))
+	for _, execution := range res.Executions {
+		executions = append(executions, executionFromPb(execution))
+	}
+
+	return &dto.ListExecutionsResponse{
+		Executions: executions,
+		NextCursor: res.NextCursor,
+	}, nil
+}
+
+func (s *Execution) GetExecution(ctx context.Context, executionId int) (*dto.Execution, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	res, err := s.GrpcClient.GetExecution(ctx, &pb.GetExecutionRequest{
+		Id:     int64(executionId),
+		UserId: userId,
+	})
+	if err != nil {
+		return nil, mapExecutionError(err)
+	}
+
+	execution := executionFromPb(res.Execution)
+	return &execution, nil
+}
+
+func (s *Execution) GetExecutionSteps(ctx context.Context, executionId int) ([]dto.ExecutionStep, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	res, err := s.GrpcClient.GetExecutionSteps(ctx, &pb.GetExecutionStepsRequest{
+		ExecutionId: int64(executionId),
+		UserId:      userId,
+	})
+	if err != nil {
+		return nil, mapExecutionError(err)
+	}
+
+	steps := make([]dto.ExecutionStep, 0, len(res.Steps))
+	for _, step := range res.Steps {
+		attempts := make([]dto.ExecutionStepAttempt, 0, len(step.Attempts))
+		for _, attempt := range step.Attempts {
+			attempts = append(attempts, dto.ExecutionStepAttempt{
+				Attempt:    int(attempt.Attempt),
+				Status:     attempt.Status,
+				Error:      attempt.Error,
+				ErrorClass: attempt.ErrorClass,
+				StartedAt:  attempt.StartedAt.AsTime(),
+				FinishedAt: attempt.FinishedAt.AsTime(),
+			})
+		}
+		steps = append(steps, dto.ExecutionStep{
+			Id:               int(step.Id),
+			ExecutionId:      int(step.ExecutionId),
+			NodeId:           step.NodeId,
+			DisplayId:        step.DisplayId,
+			Status:           step.Status,
+			Input:            rawJSON(step.Input),
+			Output:           rawJSON(step.Output),
+			Error:            step.Error,
+			StartedAt:        step.StartedAt.AsTime(),
+			FinishedAt:       optionalTime(step.FinishedAt),
+			Attempts:         attempts,
+			SimulatedRequest: rawJSON(step.SimulatedRequest),
+		})
+	}
+	return steps, nil
+}
+
+func (s *Execution) CancelExecution(ctx context.Context, executionId int) (*dto.Execution, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	res, err := s.GrpcClient.CancelExecution(ctx, &pb.CancelExecutionRequest{
+		Id:     int64(executionId),
+		UserId: userId,
+	})
+	if err != nil {
+		return nil, mapExecutionError(err)
+	}
+
+	execution := executionFromPb(res.Execution)
+	return &execution, nil
+}
+
+// ReplayExecution runs an execution of the user again, see dto.ReplayExecutionPayload
+func (s *Execution) ReplayExecution(ctx context.Context, executionId int, data dto.ReplayExecutionPayload) (*dto.ReplayExecutionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	// Only the owner of the execution can replay it
+	if _, err := s.GrpcClient.GetExecution(ctx, &pb.GetExecutionRequest{
+		Id:     int64(executionId),
+		UserId: userId,
+	}); err != nil {
+		return nil, mapExecutionError(err)
+	}
+
+	res, err := s.Orchestrator.ReplayExecution(ctx, &pb.ReplayRequest{
+		ExecutionId: int32(executionId),
+		FromFailure: data.FromFailure,
+		FromNode:    data.FromNode,
+	})
+	if err != nil {
+		return nil, mapExecutionError(err)
+	}
+	return &dto.ReplayExecutionResponse{ExecutionId: int(res.ExecutionId)}, nil
+}
+
+func executionFromPb(execution *pb.Execution) dto.Execution {
+	result := dto.Execution{
+		Id:             int(execution.Id),
+		WorkflowId:     int(execution.WorkflowId),
+		ListenerNodeId: execution.ListenerNodeId,
+		Status:         execution.Status,
+		TriggerPayload: rawJSON(execution.TriggerPayload),
+		Output:         rawJSON(execution.Output),
+		Error:          execution.Error,
+		Depth:          int(execution.Depth),
+		DryRun:         execution.DryRun,
+		CreatedAt:      execution.CreatedAt.AsTime(),
+		StartedAt:      optionalTime(execution.StartedAt),
+		FinishedAt:     optionalTime(execution.FinishedAt),
+	}
+	if execution.ParentExecutionId != nil {
+		parentId := int(*execution.ParentExecutionId)
+		result.ParentExecutionId = &parentId
+	}
+	if execution.ReplayOfExecutionId != nil {
+		replayOfId := int(*execution.ReplayOfExecutionId)
+		result.ReplayOfExecutionId = &replayOfId
+	}
+	return result
+}
+
+func mapExecutionError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return errs.NotFoundError{EntityName: "Execution"}
+	case codes.InvalidArgument:
+		return errs.InvalidInputError{}
+	case codes.FailedPrecondition:
+		return errs.ConflictError{Message: st.Message()}
+	default:
+		return err
+	}
+}
+
+// Stored JSON is passed through as is, missing JSON becomes null
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+func optionalTime(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := t.AsTime()
+	return &converted
+}
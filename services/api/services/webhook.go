@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/dto"
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// How often a webhook which responds with the output checks on its execution
+const webhookPollInterval = 250 * time.Millisecond
+
+// Multipart bodies are kept in memory up to this size
+const maxWebhookFormMemory = 1 << 20
+
+// ErrInvalidSignature is a webhook request whose signature header doesn't match its body
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// The webhook listener is served by the API itself, so the API adds it to the catalog of the workers
+var webhookCatalog = dto.CatalogService{
+	ServiceName: models.WebhookServiceName,
+	Tasks: []dto.CatalogTask{{
+		Name:        models.WebhookTask,
+		NodeType:    "listener",
+		DisplayName: "Webhook",
+		ConfigSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"secret": {"type": "string", "title": "Secret", "description": "Requests need the hex HMAC-SHA256 of their body in the signature header"},
+				"signature_header": {"type": "string", "title": "Signature header", "default": "X-Signature"},
+				"respond_with_output": {"type": "boolean", "title": "Respond with the output of the workflow"},
+				"response_timeout_seconds": {"type": "integer", "title": "Response timeout in seconds", "default": 10}
+			},
+			"additionalProperties": false
+		}`),
+		OutputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"method": {"type": "string"},
+				"headers": {"type": "object", "description": "Lower case names"},
+				"query": {"type": "object"},
+				"body": {"description": "Parsed JSON or form body, the raw body as a string otherwise"}
+			}
+		}`),
+		OAuthScopes: []string{},
+	}},
+}
+
+type Webhook struct {
+	Db           *sql.DB
+	Orchestrator pb.OrchestratorClient
+	// Used to wait for the output of the execution
+	Executions pb.ExecutionServiceClient
+}
+
+// WebhookRequest is a request which was received on the URL of a webhook
+type WebhookRequest struct {
+	Method string
+	Header http.Header
+	Query  url.Values
+	Body   []byte
+}
+
+// WebhookResult is the execution a webhook started. Finished is only set when the webhook waited for it
+type WebhookResult struct {
+	ExecutionId int
+	Finished    bool
+	Execution   *dto.Execution
+}
+
+// Trigger starts the workflow of the webhook listener with the token. The trigger payload is
+// {"method": "POST", "headers": {...}, "query": {...}, "body": ...}
+func (s *Webhook) Trigger(ctx context.Context, token string, req WebhookRequest) (*WebhookResult, error) {
+	webhookRepo := repositories.Webhook{Db: s.Db}
+	workflowNodeRepo := repositories.WorkflowNode{Db: s.Db}
+	workflowRepo := repositories.Workflow{Db: s.Db}
+
+	webhook, err := webhookRepo.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	node, err := workflowNodeRepo.FindById(webhook.NodeId)
+	if err != nil {
+		// The node was removed from its workflow
+		return nil, errs.NotFoundError{EntityName: "Webhook"}
+	}
+	workflow, err := workflowRepo.FindById(node.WorkflowId)
+	if err != nil {
+		return nil, err
+	}
+	if !workflow.Active {
+		return nil, errs.ConflictError{Message: "the workflow of the webhook is not active"}
+	}
+
+	config, err := models.ParseWebhookConfig(node.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config of webhook node %s: %v", node.Id, err)
+	}
+	if config.Secret != "" && !validSignature(config.Secret, req.Header.Get(config.SignatureHeader), req.Body) {
+		return nil, ErrInvalidSignature
+	}
+
+	body, err := parseWebhookBody(req.Header.Get("Content-Type"), req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.InvalidInputError{}, err)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"method":  req.Method,
+		"headers": flattenHeader(req.Header),
+		"query":   flattenValues(req.Query),
+		"body":    body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	triggerCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := s.Orchestrator.TriggerWorkflow(triggerCtx, &pb.TriggerRequest{
+		ListenerNodeId: node.Id,
+		InitialPayload: string(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WebhookResult{ExecutionId: int(res.ExecutionId)}
+	if !config.RespondWithOutput {
+		return result, nil
+	}
+
+	execution, err := s.waitForExecution(ctx, res.ExecutionId, int64(workflow.UserId), time.Duration(config.ResponseTimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if execution != nil {
+		result.Finished = true
+		result.Execution = execution
+	}
+	return result, nil
+}
+
+// waitForExecution returns the execution once it is finished, or nil when it runs longer than timeout
+func (s *Webhook) waitForExecution(ctx context.Context, executionId int32, userId int64, timeout time.Duration) (*dto.Execution, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+			res, err := s.Executions.GetExecution(ctx, &pb.GetExecutionRequest{Id: int64(executionId), UserId: userId})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, nil
+				}
+				return nil, err
+			}
+			if models.ExecutionStatusFromString(res.Execution.Status).IsFinal() {
+				execution := executionFromPb(res.Execution)
+				return &execution, nil
+			}
+		}
+	}
+}
+
+// validSignature checks the hex HMAC-SHA256 of the body, GitHub style "sha256=<hex>" signatures are accepted too
+func validSignature(secret string, signature string, body []byte) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	received, err := hex.DecodeString(signature)
+	if err != nil || len(received) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+// parseWebhookBody decodes JSON and form bodies, anything else is passed on as a string
+func parseWebhookBody(contentType string, body []byte) (interface{}, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return string(body), nil
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var parsed interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		return parsed, nil
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid form body: %v", err)
+		}
+		return flattenValues(values), nil
+	case mediaType == "multipart/form-data":
+		// Only the fields, files are left out
+		form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(maxWebhookFormMemory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %v", err)
+		}
+		defer form.RemoveAll()
+		return flattenValues(form.Value), nil
+	default:
+		return string(body), nil
+	}
+}
+
+// flattenValues keeps single values as strings and repeated ones as arrays
+func flattenValues(values map[string][]string) map[string]interface{} {
+	flat := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			flat[key] = list[0]
+		} else {
+			flat[key] = list
+		}
+	}
+	return flat
+}
+
+// flattenHeader lower cases the names and joins repeated headers
+func flattenHeader(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for name, values := range header {
+		flat[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return flat
+}
@@ -2,10 +2,15 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
 
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/dto"
@@ -13,6 +18,8 @@ import (
 
 type Workflow struct {
 	GrpcClient pb.WorkflowServiceClient
+	// Runs workflows on demand
+	Orchestrator pb.OrchestratorClient
 }
 
 func (s *Workflow) CreateWorkflow(ctx context.Context, data dto.CreateWorkflowPayload) (*dto.CreateWorkflowResponse, error) {
@@ -36,6 +43,7 @@ func (s *Workflow) CreateWorkflow(ctx context.Context, data dto.CreateWorkflowPa
 			Position:    node.Position,
 			Config:      node.Config,
 			CredentialId: node.CredentialId,
+			Settings:    node.Settings,
 		})
 	}
 
@@ -50,6 +58,7 @@ func (s *Workflow) CreateWorkflow(ctx context.Context, data dto.CreateWorkflowPa
 			DisplayId: edge.DisplayId,
 			FromId:    edge.From,
 			ToId:      edge.To,
+			Label:     edge.Label,
 		})
 	}
 
@@ -67,12 +76,17 @@ func (s *Workflow) CreateWorkflow(ctx context.Context, data dto.CreateWorkflowPa
 		Id:     workflowId, // NEW: 0 = Create, >0 = Update
 		Name:   data.Workflow.Name,
 		UserId: rawId.(int64),
+		MaxDurationSeconds: int32(data.Workflow.MaxDurationSeconds),
 		Nodes:  nodeInput,
 		Edges:  edgeInput,
 	}
 
 	res, err := s.GrpcClient.CreateWorkflow(ctx, req)
 	if err != nil {
+		// The graph or a node config is invalid
+		if status.Code(err) == codes.InvalidArgument {
+			return nil, fmt.Errorf("%w: %s", errs.InvalidInputError{}, status.Convert(err).Message())
+		}
 		return nil, err
 	}
 
@@ -107,24 +121,38 @@ func (s *Workflow) GetWorkflows(ctx context.Context) ([]dto.Workflow, error) {
             CreatedAt: w.CreatedAt.AsTime(),
             UpdatedAt: w.UpdatedAt.AsTime(),
             UserId:    int(w.UserId),
+            MaxDurationSeconds: int(w.MaxDurationSeconds),
         })
     }
 
     return workflows, nil
 }
 
+// GetWorkflowById returns a workflow of the user with its nodes, including their webhook tokens and secrets
 func (s *Workflow) GetWorkflowById(ctx context.Context, workflowId int) (*dto.GetWorkflowResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
     defer cancel()
 
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
     req := &pb.GetWorkflowByIdRequest{
         Id: int64(workflowId),
     }
 
     res, err := s.GrpcClient.GetWorkflowById(ctx, req)
     if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, errs.NotFoundError{EntityName: "Workflow"}
+		}
         return nil, err
     }
+	// Other users' workflows are reported as missing
+	if res.Workflow.UserId != userId {
+		return nil, errs.NotFoundError{EntityName: "Workflow"}
+	}
 
 	fmt.Println(res)
 
@@ -135,6 +163,7 @@ func (s *Workflow) GetWorkflowById(ctx context.Context, workflowId int) (*dto.Ge
 		Name: res.Workflow.Name,
 		Active: res.Workflow.IsActive,
 		UserId: int(res.Workflow.UserId),
+		MaxDurationSeconds: int(res.Workflow.MaxDurationSeconds),
 	}
 
 	nodes := make([]dto.GetNodeResponse, 0)
@@ -149,6 +178,8 @@ func (s *Workflow) GetWorkflowById(ctx context.Context, workflowId int) (*dto.Ge
 			Position:     node.Position,
 			Config:       node.Config,
 			CredentialId: node.CredentialId,
+			Settings:     node.Settings,
+			WebhookToken: node.WebhookToken,
 		})
 	}
 
@@ -160,6 +191,7 @@ func (s *Workflow) GetWorkflowById(ctx context.Context, workflowId int) (*dto.Ge
 			WorkflowId:  int(edge.WorkflowId),
 			NodeFrom: edge.FromId,
 			NodeTo: edge.ToId,
+			Label: edge.Label,
 		})
 	}
 
@@ -169,3 +201,99 @@ func (s *Workflow) GetWorkflowById(ctx context.Context, workflowId int) (*dto.Ge
 		Edges: edges,
 	}, nil
 }
+
+// RunWorkflow queues an execution of the workflow as if its listener fired with the given payload.
+// The workflow doesn't have to be active
+func (s *Workflow) RunWorkflow(ctx context.Context, workflowId int, data dto.RunWorkflowPayload) (*dto.RunWorkflowResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userId, ok := ctx.Value("user_id").(int64)
+	if !ok {
+		return nil, fmt.Errorf("user_id not found in context")
+	}
+
+	res, err := s.GrpcClient.GetWorkflowById(ctx, &pb.GetWorkflowByIdRequest{Id: int64(workflowId)})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, errs.NotFoundError{EntityName: "Workflow"}
+		}
+		return nil, err
+	}
+	// Other users' workflows are reported as missing
+	if res.Workflow.UserId != userId {
+		return nil, errs.NotFoundError{EntityName: "Workflow"}
+	}
+
+	listeners := make([]*pb.Node, 0)
+	for _, node := range res.Nodes {
+		if node.Type != "listener" {
+			continue
+		}
+		if data.Listener == "" || data.Listener == node.DisplayId || data.Listener == node.Id {
+			listeners = append(listeners, node)
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("%w: no listener node %q in the workflow", errs.InvalidInputError{}, data.Listener)
+	}
+	if len(listeners) > 1 {
+		return nil, fmt.Errorf("%w: the workflow has several listeners, pick one with listener", errs.InvalidInputError{})
+	}
+
+	payload := "{}"
+	if len(data.Payload) > 0 && string(data.Payload) != "null" {
+		var object map[string]interface{}
+		if err := json.Unmarshal(data.Payload, &object); err != nil {
+			return nil, fmt.Errorf("%w: payload must be a JSON object", errs.InvalidInputError{})
+		}
+		payload = string(data.Payload)
+	}
+
+	triggerRes, err := s.Orchestrator.TriggerWorkflow(ctx, &pb.TriggerRequest{
+		ListenerNodeId: listeners[0].Id,
+		InitialPayload: payload,
+		DryRun:         data.DryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.RunWorkflowResponse{ExecutionId: int(triggerRes.ExecutionId)}, nil
+}
+
+// GetCatalog lists the services and their tasks as the registered workers describe them
+func (s *Workflow) GetCatalog(ctx context.Context) (*dto.CatalogResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := s.Orchestrator.GetCatalog(ctx, &pb.GetCatalogRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]dto.CatalogService, 0, len(res.Services))
+	for _, service := range res.Services {
+		tasks := make([]dto.CatalogTask, 0, len(service.Tasks))
+		for _, task := range service.Tasks {
+			tasks = append(tasks, dto.CatalogTask{
+				Name:         task.Name,
+				NodeType:     task.NodeType,
+				DisplayName:  task.DisplayName,
+				ConfigSchema: schemaOrNull(task.ConfigSchema),
+				OutputSchema: schemaOrNull(task.OutputSchema),
+				OAuthScopes:  task.OauthScopes,
+			})
+		}
+		services = append(services, dto.CatalogService{ServiceName: service.ServiceName, Tasks: tasks})
+	}
+	services = append(services, webhookCatalog)
+	return &dto.CatalogResponse{Services: services}, nil
+}
+
+// schemaOrNull passes a schema on as JSON, a missing or broken one becomes null
+func schemaOrNull(schema string) json.RawMessage {
+	if schema == "" || !json.Valid([]byte(schema)) {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(schema)
+}
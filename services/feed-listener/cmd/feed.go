@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed is a parsed RSS or Atom feed
+type Feed struct {
+	Title   string
+	Entries []FeedEntry
+}
+
+// FeedEntry is an RSS item or an Atom entry
+type FeedEntry struct {
+	// The RSS guid or Atom id, the link when there is none
+	GUID   string
+	Title  string
+	Link   string
+	Author string
+	// RFC 3339 when it could be parsed, as written in the feed otherwise
+	Published string
+	// May contain HTML
+	Summary string
+}
+
+// RSS 2.0, and RSS 1.0 whose items are next to the channel
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title string `xml:"title"`
+	// Items of RSS 2.0 feeds can have an atom:link next to their link
+	Links       []string `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string   `xml:"description"`
+	About       string   `xml:"about,attr"`
+}
+
+type atomDocument struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Id    string `xml:"id"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Authors []struct {
+		Name  string `xml:"name"`
+		Email string `xml:"email"`
+	} `xml:"author"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+}
+
+// The formats of RSS dates are loose, these are the ones seen in practice
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"Mon, 02 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseFeed reads an RSS 0.9x, 1.0 or 2.0 or an Atom feed, the entries in the order of the document
+func ParseFeed(data []byte) (*Feed, error) {
+	decoder := newFeedDecoder(data)
+	// The root element tells the format
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("not an RSS or Atom feed: %v", err)
+		}
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(root.Name.Local) {
+		case "rss", "rdf":
+			var document rssDocument
+			if err := decoder.DecodeElement(&document, &root); err != nil {
+				return nil, fmt.Errorf("invalid RSS feed: %v", err)
+			}
+			return rssFeed(&document), nil
+		case "feed":
+			var document atomDocument
+			if err := decoder.DecodeElement(&document, &root); err != nil {
+				return nil, fmt.Errorf("invalid Atom feed: %v", err)
+			}
+			return atomFeed(&document), nil
+		default:
+			return nil, fmt.Errorf("not an RSS or Atom feed: root element is <%s>", root.Name.Local)
+		}
+	}
+}
+
+func newFeedDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// HTML entities like &nbsp; are common in feeds
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
+
+// charsetReader handles the single byte Latin charsets next to UTF-8. Windows-1252 is read as Latin-1,
+// only a few punctuation characters differ
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		decoded := make([]byte, 0, len(data))
+		for _, b := range data {
+			decoded = utf8.AppendRune(decoded, rune(b))
+		}
+		return bytes.NewReader(decoded), nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+}
+
+func rssFeed(document *rssDocument) *Feed {
+	feed := &Feed{Title: strings.TrimSpace(document.Channel.Title), Entries: make([]FeedEntry, 0)}
+	items := append(document.Channel.Items, document.Items...)
+	for _, item := range items {
+		entry := FeedEntry{
+			GUID:      strings.TrimSpace(item.GUID),
+			Title:     strings.TrimSpace(item.Title),
+			Link:      strings.TrimSpace(firstNonEmpty(item.Links...)),
+			Author:    strings.TrimSpace(firstNonEmpty(item.Author, item.Creator)),
+			Published: feedDate(firstNonEmpty(item.PubDate, item.Date)),
+			Summary:   strings.TrimSpace(item.Description),
+		}
+		if entry.GUID == "" {
+			entry.GUID = strings.TrimSpace(item.About)
+		}
+		feed.Entries = append(feed.Entries, withGUID(entry))
+	}
+	return feed
+}
+
+func atomFeed(document *atomDocument) *Feed {
+	feed := &Feed{Title: strings.TrimSpace(document.Title), Entries: make([]FeedEntry, 0)}
+	for _, item := range document.Entries {
+		entry := FeedEntry{
+			GUID:      strings.TrimSpace(item.Id),
+			Title:     strings.TrimSpace(item.Title),
+			Published: feedDate(firstNonEmpty(item.Published, item.Updated)),
+			Summary:   strings.TrimSpace(firstNonEmpty(item.Summary, item.Content)),
+		}
+		// The alternate link is the page of the entry
+		for _, link := range item.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				entry.Link = strings.TrimSpace(link.Href)
+				break
+			}
+		}
+		if entry.Link == "" && len(item.Links) > 0 {
+			entry.Link = strings.TrimSpace(item.Links[0].Href)
+		}
+		if len(item.Authors) > 0 {
+			author := item.Authors[0]
+			entry.Author = strings.TrimSpace(author.Name)
+			if author.Email != "" {
+				entry.Author = strings.TrimSpace(fmt.Sprintf("%s <%s>", entry.Author, strings.TrimSpace(author.Email)))
+			}
+		}
+		feed.Entries = append(feed.Entries, withGUID(entry))
+	}
+	return feed
+}
+
+// withGUID falls back to the link, and to a hash of the title and date for entries without either
+func withGUID(entry FeedEntry) FeedEntry {
+	if entry.GUID != "" {
+		return entry
+	}
+	if entry.Link != "" {
+		entry.GUID = entry.Link
+		return entry
+	}
+	sum := sha256.Sum256([]byte(entry.Title + "\n" + entry.Published))
+	entry.GUID = "sha256:" + hex.EncodeToString(sum[:])
+	return entry
+}
+
+// feedDate converts a feed date to RFC 3339 in UTC, unknown formats are kept as they are
+func feedDate(value string) string {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC().Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     *Feed
+		wantErr  bool
+	}{
+		{
+			name: "rss 2.0",
+			document: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title> Release notes </title>
+	<item>
+		<title>v2 &amp; more&nbsp;</title>
+		<link>https://example.com/v2</link>
+		<atom:link href="https://example.com/v2" rel="self"/>
+		<guid isPermaLink="false">release-2</guid>
+		<dc:creator>Ann</dc:creator>
+		<pubDate>Tue, 03 Sep 2024 10:00:00 +0200</pubDate>
+		<description><![CDATA[<p>Second</p>]]></description>
+	</item>
+	<item>
+		<title>v1</title>
+		<link>https://example.com/v1</link>
+		<author>bob@example.com (Bob)</author>
+		<pubDate>Mon, 2 Sep 2024 08:30:00 GMT</pubDate>
+	</item>
+</channel>
+</rss>`,
+			want: &Feed{Title: "Release notes", Entries: []FeedEntry{
+				{GUID: "release-2", Title: "v2 & more", Link: "https://example.com/v2", Author: "Ann", Published: "2024-09-03T08:00:00Z", Summary: "<p>Second</p>"},
+				{GUID: "https://example.com/v1", Title: "v1", Link: "https://example.com/v1", Author: "bob@example.com (Bob)", Published: "2024-09-02T08:30:00Z"},
+			}},
+		},
+		{
+			name: "rss 0.91 without links",
+			document: `<rss version="0.91"><channel><title>Old</title>
+	<item><title>Only a title</title><pubDate>sometime</pubDate></item>
+</channel></rss>`,
+			want: &Feed{Title: "Old", Entries: []FeedEntry{
+				{GUID: withGUID(FeedEntry{Title: "Only a title", Published: "sometime"}).GUID, Title: "Only a title", Published: "sometime"},
+			}},
+		},
+		{
+			name: "rdf",
+			document: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<channel rdf:about="https://example.com/"><title>Journal</title></channel>
+	<item rdf:about="https://example.com/a">
+		<title>A</title>
+		<link>https://example.com/a?ref=rss</link>
+		<dc:date>2024-09-01T12:00:00+02:00</dc:date>
+		<description>First</description>
+	</item>
+</rdf:RDF>`,
+			want: &Feed{Title: "Journal", Entries: []FeedEntry{
+				{GUID: "https://example.com/a", Title: "A", Link: "https://example.com/a?ref=rss", Published: "2024-09-01T10:00:00Z", Summary: "First"},
+			}},
+		},
+		{
+			name: "atom",
+			document: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>The Go Blog</title>
+	<entry>
+		<title>Range functions</title>
+		<id>tag:blog.golang.org,2013:blog.golang.org/range-functions</id>
+		<link rel="self" href="https://go.dev/blog/range-functions.atom"/>
+		<link rel="alternate" href="https://go.dev/blog/range-functions"/>
+		<author><name>Ian</name><email>ian@example.com</email></author>
+		<updated>2024-08-20T00:00:00Z</updated>
+		<summary>How they work</summary>
+		<content type="html">Long</content>
+	</entry>
+	<entry>
+		<title>No id</title>
+		<link href="https://go.dev/blog/no-id"/>
+		<published>2024-08-01T00:00:00-07:00</published>
+		<content>Body</content>
+	</entry>
+</feed>`,
+			want: &Feed{Title: "The Go Blog", Entries: []FeedEntry{
+				{GUID: "tag:blog.golang.org,2013:blog.golang.org/range-functions", Title: "Range functions", Link: "https://go.dev/blog/range-functions", Author: "Ian <ian@example.com>", Published: "2024-08-20T00:00:00Z", Summary: "How they work"},
+				{GUID: "https://go.dev/blog/no-id", Title: "No id", Link: "https://go.dev/blog/no-id", Published: "2024-08-01T07:00:00Z", Summary: "Body"},
+			}},
+		},
+		{
+			name:     "latin-1",
+			document: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>Caf\xe9</title></channel></rss>",
+			want:     &Feed{Title: "Café", Entries: []FeedEntry{}},
+		},
+		{
+			name:     "empty feed",
+			document: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Nothing</title></feed>`,
+			want:     &Feed{Title: "Nothing", Entries: []FeedEntry{}},
+		},
+		{name: "html", document: `<!DOCTYPE html><html><body>Not a feed</body></html>`, wantErr: true},
+		{name: "not xml", document: `{"items": []}`, wantErr: true},
+		{name: "empty", document: ``, wantErr: true},
+		{name: "unsupported charset", document: `<?xml version="1.0" encoding="Shift_JIS"?><rss></rss>`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseFeed([]byte(test.document))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFeed() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseFeed() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFeedDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "Tue, 03 Sep 2024 10:00:00 +0200", want: "2024-09-03T08:00:00Z"},
+		{value: "Tue, 03 Sep 2024 10:00:00 GMT", want: "2024-09-03T10:00:00Z"},
+		{value: "Tue, 3 Sep 2024 10:00:00 -0500", want: "2024-09-03T15:00:00Z"},
+		{value: "Tue, 03 Sep 2024 10:00 +0000", want: "2024-09-03T10:00:00Z"},
+		{value: "3 Sep 2024 10:00:00 +0100", want: "2024-09-03T09:00:00Z"},
+		{value: " 2024-09-03T10:00:00.5+02:00 ", want: "2024-09-03T08:00:00Z"},
+		{value: "2024-09-03T10:00:00", want: "2024-09-03T10:00:00Z"},
+		{value: "2024-09-03", want: "2024-09-03T00:00:00Z"},
+		{value: "yesterday", want: "yesterday"},
+		{value: "", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			if got := feedDate(test.value); got != test.want {
+				t.Errorf("feedDate(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/workers"
+)
+
+// Every node has its own interval, this is how often the nodes which are due are looked for
+const pollInterval = 30 * time.Second
+
+const requestTimeout = 15 * time.Second
+
+// Largest feed which is read
+const maxFeedSize = 10 << 20
+
+// At least this many GUIDs are remembered per node, more when the feed has more entries
+const maxSeenEntries = 1000
+
+// FeedListener fires a workflow for every new entry of an RSS or Atom feed
+type FeedListener struct {
+	Client       *http.Client
+	Orchestrator pb.OrchestratorClient
+	// Finds the feed listener nodes and stores the GUIDs they have seen
+	Poller *poller.Poller
+}
+
+// The trigger payload of a feed listener
+type entryPayload struct {
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	Author    string `json:"author"`
+	Published string `json:"published"`
+	Summary   string `json:"summary"`
+	GUID      string `json:"guid"`
+	FeedTitle string `json:"feed_title"`
+	FeedURL   string `json:"feed_url"`
+}
+
+// CheckForNewEntries is the check of the poller. The first check only remembers the entries which are there already,
+// otherwise a new workflow would send the whole history of the feed
+func (l *FeedListener) CheckForNewEntries(ctx context.Context, job poller.Job) (*poller.Checkpoint, error) {
+	config, err := models.ParseFeedConfig(job.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	firstCheck := job.Checkpoint.LastCheckAt.IsZero()
+
+	feed, err := l.fetchFeed(ctx, config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed %s: %v", config.URL, err)
+	}
+
+	seen := make(map[string]bool, len(job.Checkpoint.SeenItems))
+	for _, guid := range job.Checkpoint.SeenItems {
+		seen[guid] = true
+	}
+
+	// Feeds list the newest entry first, they fire oldest first
+	remembered := make([]string, 0, len(feed.Entries))
+	fired := 0
+	failed := false
+	for i := len(feed.Entries) - 1; i >= 0; i-- {
+		entry := feed.Entries[i]
+		if !seen[entry.GUID] && !firstCheck {
+			if failed {
+				continue
+			}
+			if err := l.fire(ctx, job, config, feed, entry); err != nil {
+				log.Printf("Failed to trigger workflow %d for node %s: %v", job.WorkflowId, job.NodeId, err)
+				// The rest is new again on the next check
+				failed = true
+				continue
+			}
+			fired++
+		}
+		seen[entry.GUID] = true
+		remembered = append(remembered, entry.GUID)
+	}
+	log.Printf("Node %s: %d entries, %d new", job.NodeId, len(feed.Entries), fired)
+
+	return &poller.Checkpoint{
+		SeenItems: poller.RememberSeen(job.Checkpoint.SeenItems, remembered, maxSeenEntries),
+	}, nil
+}
+
+// feedIntervalOf is how often the poller checks a node
+func feedIntervalOf(job poller.Job) (time.Duration, error) {
+	config, err := models.ParseFeedConfig(job.Config)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(config.IntervalSeconds) * time.Second, nil
+}
+
+func (l *FeedListener) fetchFeed(ctx context.Context, url string) (*Feed, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+
+	res, err := l.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeed(data)
+}
+
+func (l *FeedListener) fire(ctx context.Context, job poller.Job, config *models.FeedConfig, feed *Feed, entry FeedEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(entryPayload{
+		Title:     entry.Title,
+		Link:      entry.Link,
+		Author:    entry.Author,
+		Published: entry.Published,
+		Summary:   entry.Summary,
+		GUID:      entry.GUID,
+		FeedTitle: feed.Title,
+		FeedURL:   config.URL,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = l.Orchestrator.TriggerWorkflow(ctx, &pb.TriggerRequest{
+		ListenerNodeId: job.NodeId,
+		InitialPayload: string(payload),
+	})
+	return err
+}
+
+func main() {
+	db, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/was_api?parseTime=true&loc=UTC")
+	if err != nil {
+		log.Fatal("Could not connect to db", err)
+		return
+	}
+
+	orchConn, _ := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	defer orchConn.Close()
+
+	// The listener describes the new entry task for the catalog, it runs no actions
+	grpcListener, err := net.Listen("tcp", ":50059")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterTaskWorkerServer(grpcServer, &FeedServer{})
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	go workers.Register(workers.Getenv("ORCHESTRATOR_ADDRESS", "localhost:50051"), &pb.RegisterWorkerRequest{
+		ServiceName: models.FeedServiceName,
+		Address:     workers.Getenv("WORKER_ADDRESS", "localhost:50059"),
+		Tasks:       []string{},
+	})
+
+	listener := &FeedListener{
+		Client:       &http.Client{},
+		Orchestrator: pb.NewOrchestratorClient(orchConn),
+	}
+	listener.Poller = &poller.Poller{
+		Db:           db,
+		ServiceName:  models.FeedServiceName,
+		Interval:     pollInterval,
+		Check:        listener.CheckForNewEntries,
+		NodeInterval: feedIntervalOf,
+	}
+
+	log.Printf("Feed Listener started. Looking for due nodes every %v...\n", pollInterval)
+	listener.Poller.Run(context.Background())
+}
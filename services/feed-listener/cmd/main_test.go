@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// fakeOrchestrator records the triggered payloads, the trigger number failAt fails
+type fakeOrchestrator struct {
+	pb.OrchestratorClient
+	fired  []entryPayload
+	failAt int
+}
+
+func (o *fakeOrchestrator) TriggerWorkflow(ctx context.Context, req *pb.TriggerRequest, opts ...grpc.CallOption) (*pb.TriggerResponse, error) {
+	if o.failAt == len(o.fired)+1 {
+		return nil, errors.New("orchestrator unavailable")
+	}
+	var payload entryPayload
+	if err := json.Unmarshal([]byte(req.InitialPayload), &payload); err != nil {
+		return nil, err
+	}
+	o.fired = append(o.fired, payload)
+	return &pb.TriggerResponse{}, nil
+}
+
+// rssWith is a feed of the entries with these GUIDs, newest first like feeds list them
+func rssWith(guids ...string) string {
+	items := make([]string, 0, len(guids))
+	for _, guid := range guids {
+		items = append(items, fmt.Sprintf("<item><title>Entry %s</title><guid>%s</guid></item>", guid, guid))
+	}
+	return "<rss><channel><title>News</title>" + strings.Join(items, "") + "</channel></rss>"
+}
+
+func TestCheckForNewEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		feed       string
+		seen       []string
+		firstCheck bool
+		failAt     int
+		wantFired  []string
+		wantSeen   []string
+		wantErr    bool
+	}{
+		{
+			name:       "first check remembers the entries",
+			feed:       rssWith("c", "b", "a"),
+			firstCheck: true,
+			wantFired:  []string{},
+			wantSeen:   []string{"a", "b", "c"},
+		},
+		{
+			name:      "new entries fire oldest first",
+			feed:      rssWith("d", "c", "b", "a"),
+			seen:      []string{"a", "b"},
+			wantFired: []string{"c", "d"},
+			wantSeen:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "duplicate entries fire once",
+			feed:      rssWith("c", "c", "b"),
+			seen:      []string{"b"},
+			wantFired: []string{"c"},
+			wantSeen:  []string{"b", "c"},
+		},
+		{
+			name:      "entries which left the feed are kept",
+			feed:      rssWith("d", "c"),
+			seen:      []string{"a", "b", "c"},
+			wantFired: []string{"d"},
+			wantSeen:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "fire failure keeps the rest new",
+			feed:      rssWith("e", "d", "c", "b"),
+			seen:      []string{"b"},
+			failAt:    2,
+			wantFired: []string{"c"},
+			wantSeen:  []string{"b", "c"},
+		},
+		{name: "not a feed", feed: `<html></html>`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(test.feed))
+			}))
+			defer server.Close()
+
+			orchestrator := &fakeOrchestrator{failAt: test.failAt}
+			listener := &FeedListener{Client: server.Client(), Orchestrator: orchestrator}
+			job := poller.Job{
+				NodeId:     "node-1",
+				Config:     `{"url": "` + server.URL + `"}`,
+				Checkpoint: poller.Checkpoint{SeenItems: test.seen},
+			}
+			if !test.firstCheck {
+				job.Checkpoint.LastCheckAt = time.Now().Add(-time.Hour)
+			}
+
+			checkpoint, err := listener.CheckForNewEntries(context.Background(), job)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("CheckForNewEntries() = %+v, want an error", checkpoint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fired := make([]string, 0)
+			for _, payload := range orchestrator.fired {
+				fired = append(fired, payload.GUID)
+				if payload.FeedTitle != "News" || payload.FeedURL != server.URL {
+					t.Errorf("payload feed = %q %q, want News %q", payload.FeedTitle, payload.FeedURL, server.URL)
+				}
+			}
+			if !reflect.DeepEqual(fired, test.wantFired) {
+				t.Errorf("fired %v, want %v", fired, test.wantFired)
+			}
+			if !reflect.DeepEqual(checkpoint.SeenItems, test.wantSeen) {
+				t.Errorf("seen items %v, want %v", checkpoint.SeenItems, test.wantSeen)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// FeedServer only describes the feed listener, there is nothing to execute
+type FeedServer struct {
+	pb.UnimplementedTaskWorkerServer
+}
+
+var taskDescriptions = []*pb.TaskDescription{
+	{
+		Name:        models.FeedEntryTask,
+		NodeType:    "listener",
+		DisplayName: "New RSS/Atom entry",
+		ConfigSchema: `{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "title": "Feed URL", "description": "RSS or Atom, e.g. https://go.dev/blog/feed.atom"},
+				"interval_seconds": {"type": "integer", "title": "Interval in seconds", "description": "At least 60", "default": 300}
+			},
+			"required": ["url"],
+			"additionalProperties": false
+		}`,
+		OutputSchema: `{
+			"type": "object",
+			"properties": {
+				"title": {"type": "string"},
+				"link": {"type": "string"},
+				"author": {"type": "string"},
+				"published": {"type": "string", "description": "RFC 3339 in UTC when the feed's date could be read"},
+				"summary": {"type": "string", "description": "May contain HTML"},
+				"guid": {"type": "string"},
+				"feed_title": {"type": "string"},
+				"feed_url": {"type": "string"}
+			}
+		}`,
+	},
+}
+
+func (s *FeedServer) DescribeTasks(ctx context.Context, req *pb.DescribeTasksRequest) (*pb.DescribeTasksResponse, error) {
+	return &pb.DescribeTasksResponse{Tasks: taskDescriptions}, nil
+}
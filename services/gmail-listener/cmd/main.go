@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/mail"
@@ -18,84 +19,45 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/utils"
 )
 
 const pollInterval int = 20
 
+// A check lists the emails and reads the new ones one by one
+const checkTimeout = time.Minute
+
+// The search starts this long before the last check, Gmail indexes some emails late
+const searchOverlap = 2 * time.Minute
+
+// Emails per page of the list call, and the most emails fired by one check
+const (
+	listPageSize      = 100
+	maxEmailsPerCheck = 500
+)
+
+// Ids of fired emails remembered per node, enough to cover the overlap of the searches
+const maxSeenEmails = 1000
+
 type GmailListener struct {
 	Db           *sql.DB
 	UserService  pb.UserServiceClient
 	Orchestrator pb.OrchestratorClient
+	// Finds the gmail listener nodes and stores their checkpoints
+	Poller *poller.Poller
 }
 
-type TriggerJob struct {
-	NodeId       string
-	WorkflowId int
-	UserId int
-	Active bool
-	Credentialid *int
-	LastCheckAt  time.Time
-	// This is used as a hack because there are cases when we can get duplicate gmail emails
-	LastMessageId string
-}
-
-
-func (l *GmailListener) Poll() {
-	// We get each node from every workflow which is a listener node and we query the gmail API for it
-	rows, err := l.Db.Query(`
-        SELECT 
-            n.id,
-			n.workflow_id,
-			workflows.user_id,
-			workflows.active,
-            n.credential_id, 
-            COALESCE(t.last_check_at, CAST('1971-01-01 00:00:00' AS DATETIME)), 
-            COALESCE(t.last_message_id, '')
-        FROM workflow_nodes n
-		JOIN workflows ON n.workflow_id = workflows.id
-        LEFT JOIN trigger_states t ON n.id = t.node_id
-        WHERE n.type = 0 
-          AND n.service_name = 'gmail'
-        ORDER BY t.last_check_at ASC
-        LIMIT 10
-	`)
-	// AND (t.last_check_at IS NULL OR t.last_check_at < NOW() - INTERVAL 60 SECOND)
+// CheckForNewEmails is the check of the poller, it fires the emails which arrived since the last check
+func (l *GmailListener) CheckForNewEmails(ctx context.Context, job poller.Job) (*poller.Checkpoint, error) {
+	config, err := models.ParseGmailListenerConfig(job.Config)
 	if err != nil {
-		log.Printf("DB Error: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var job TriggerJob
-		err := rows.Scan(
-            &job.NodeId,
-			&job.WorkflowId,
-			&job.UserId,
-			&job.Active,
-            &job.Credentialid, 
-            &job.LastCheckAt, 
-            &job.LastMessageId,
-        )
-		if err != nil {
-            log.Printf("Scan error: %v", err)
-            continue
-        }
-		if(!job.Active) {
-			continue
-		}
-
-		// Note: We don't parallelize this due to rate limits
-		l.CheckForNewEmails(job)
+		return nil, fmt.Errorf("invalid config: %v", err)
 	}
 
-	log.Println("Finished polling for now")
-}
-
-func (l *GmailListener) CheckForNewEmails(job TriggerJob) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
 	defer cancel()
 	
 	credentialId := 0
@@ -104,10 +66,10 @@ func (l *GmailListener) CheckForNewEmails(job TriggerJob) {
 
 	tokenResp, err := l.UserService.GetCredentials(ctx, &pb.GetCredentialsRequest{
 		CredentialId: int32(credentialId),
+		UserId:       int64(job.UserId),
 	})
 	if err != nil {
-		log.Printf("Auth Failed for Node %s: %v", job.NodeId, err)
-		return
+		return nil, fmt.Errorf("auth failure: %w", err)
 	}
 
 	token := &oauth2.Token{AccessToken: tokenResp.AccessToken}
@@ -121,40 +83,101 @@ func (l *GmailListener) CheckForNewEmails(job TriggerJob) {
 
 	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Fatal("Gmail Client Error:", err)
-		return
+		return nil, fmt.Errorf("gmail client error: %v", err)
 	}
+	return l.fireNewEmails(ctx, srv, job, config)
+}
 
-	// Gmail Query: "after:1698300000" (Unix Timestamp)
-	query := fmt.Sprintf("after:%d", job.LastCheckAt.Add(-2 * time.Minute).Unix())
-	
-	listCall := srv.Users.Messages.List("me").Q(query).MaxResults(1)
-	res, err := listCall.Do()
+// fireNewEmails fires the emails matching the config which are not in the checkpoint yet, oldest first.
+// The first check only remembers the emails which are there
+func (l *GmailListener) fireNewEmails(ctx context.Context, srv *gmail.Service, job poller.Job, config *models.GmailListenerConfig) (*poller.Checkpoint, error) {
+	firstCheck := job.Checkpoint.LastCheckAt.IsZero()
+	after := job.Checkpoint.LastCheckAt
+	if firstCheck {
+		after = time.Now()
+	}
+	// Gmail Query: "after:1698300000" (Unix Timestamp) and the filters of the node
+	query := config.SearchQuery(after.Add(-searchOverlap))
+
+	ids, err := listMessageIds(ctx, srv, query)
 	if err != nil {
-		log.Printf("Gmail API Error: %v", err)
-		return
+		return nil, fmt.Errorf("gmail API error for query %q: %v", query, err)
+	}
+
+	seen := make(map[string]bool, len(job.Checkpoint.SeenItems)+1)
+	for _, id := range job.Checkpoint.SeenItems {
+		seen[id] = true
+	}
+	// Checkpoints from before the seen items only know the last email
+	if job.Checkpoint.LastMessageId != "" {
+		seen[job.Checkpoint.LastMessageId] = true
 	}
 
-	if len(res.Messages) == 0 {
-		log.Printf("No new emails found for node %s", job.NodeId)
-		l.updateCheckpoint(job.NodeId, job.LastMessageId)
-		return
+	// Gmail lists the newest email first, they fire oldest first
+	remembered := make([]string, 0, len(ids))
+	lastMessageId := job.Checkpoint.LastMessageId
+	fired := 0
+	failed := false
+	for i := len(ids) - 1; i >= 0; i-- {
+		id := ids[i]
+		if !seen[id] && !firstCheck {
+			if failed {
+				continue
+			}
+			if err := l.fire(ctx, srv, job, id); err != nil {
+				log.Printf("Failed to trigger workflow %d for node %s: %v", job.WorkflowId, job.NodeId, err)
+				// The rest is new again on the next check
+				failed = true
+				continue
+			}
+			fired++
+			lastMessageId = id
+		}
+		seen[id] = true
+		remembered = append(remembered, id)
 	}
+	log.Printf("Node %s: %d emails, %d new", job.NodeId, len(ids), fired)
 
-	// We have a new email
-	messageID := res.Messages[0].Id
+	checkpoint := &poller.Checkpoint{
+		LastMessageId: lastMessageId,
+		SeenItems:     poller.RememberSeen(job.Checkpoint.SeenItems, remembered, maxSeenEmails),
+	}
+	if failed {
+		// The next check searches from the same time, so it finds the emails which didn't fire
+		checkpoint.LastCheckAt = job.Checkpoint.LastCheckAt
+	}
+	return checkpoint, nil
+}
 
-	// This is a hack because in some cases we can read an already read email
-	if messageID == job.LastMessageId {
-		log.Printf("Skipping duplicate message: %s", messageID)
-        l.updateCheckpoint(job.NodeId, messageID)
-        return
+// listMessageIds pages through the emails matching the query, newest first. Only the newest
+// maxEmailsPerCheck are returned
+func listMessageIds(ctx context.Context, srv *gmail.Service, query string) ([]string, error) {
+	ids := make([]string, 0)
+	call := srv.Users.Messages.List("me").Q(query).MaxResults(listPageSize).Context(ctx)
+	for {
+		res, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range res.Messages {
+			ids = append(ids, message.Id)
+		}
+		if res.NextPageToken == "" {
+			return ids, nil
+		}
+		if len(ids) >= maxEmailsPerCheck {
+			log.Printf("More than %d emails match %q, the older ones are skipped", maxEmailsPerCheck, query)
+			return ids[:maxEmailsPerCheck], nil
+		}
+		call.PageToken(res.NextPageToken)
 	}
+}
 
-	fullMsg, err := srv.Users.Messages.Get("me", messageID).Do()
+// fire reads the email and triggers the workflow with it
+func (l *GmailListener) fire(ctx context.Context, srv *gmail.Service, job poller.Job, messageID string) error {
+	fullMsg, err := srv.Users.Messages.Get("me", messageID).Context(ctx).Do()
 	if err != nil {
-		log.Println("Error getting messages: ", err)
-		return
+		return fmt.Errorf("error getting message %s: %v", messageID, err)
 	}
 
 	// Extract the data from the email
@@ -183,35 +206,25 @@ func (l *GmailListener) CheckForNewEmails(job TriggerJob) {
 
 	// Trigger the workflow
 
-	payload := fmt.Sprintf(`{"email_subject": "%s", "email_from": "%s", "email_body": "%s", "id": "%s"}`, 
-		subject, from, body, messageID)
+	payload, err := json.Marshal(map[string]string{
+		"email_subject": subject,
+		"email_from":    from,
+		"email_body":    body,
+		"id":            messageID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode trigger payload: %v", err)
+	}
 
 	_, err = l.Orchestrator.TriggerWorkflow(ctx, &pb.TriggerRequest{
 		ListenerNodeId: job.NodeId,
-		InitialPayload: payload,
+		InitialPayload: string(payload),
 	})
 
 	if err != nil {
-		log.Printf("Failed to trigger workflow: %v", err)
-		return
+		return fmt.Errorf("failed to trigger workflow: %v", err)
 	}
-
-	l.updateCheckpoint(job.NodeId, messageID)
-}
-
-func (l *GmailListener) updateCheckpoint(nodeId, msgId string) {
-	// Upsert
-	query := `
-		INSERT INTO trigger_states (node_id, last_check_at, last_message_id)
-		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE 
-			last_check_at = VALUES(last_check_at),
-            last_message_id = VALUES(last_message_id);
-	`
-	_, err := l.Db.Exec(query, nodeId, time.Now().UTC(), msgId)
-	if err != nil {
-        log.Printf("Failed to update checkpoint for %s: %v", nodeId, err)
-    }
+	return nil
 }
 
 
@@ -238,12 +251,17 @@ func main() {
 		UserService:  pb.NewUserServiceClient(userConn),
 		Orchestrator: pb.NewOrchestratorClient(orchConn),
 	}
+	// Note: We don't parallelize this due to rate limits
+	listener.Poller = &poller.Poller{
+		Db:          db,
+		ServiceName: models.GmailServiceName,
+		Interval:    time.Duration(pollInterval) * time.Second,
+		BatchSize:   10,
+		Check:       listener.CheckForNewEmails,
+	}
 
 	log.Printf("Gmail Listener started. Polling every %ds...\n", pollInterval)
 	
 	// TODO: Webhooks
-	ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
-	for range ticker.C {
-		listener.Poll()
-	}
+	listener.Poller.Run(context.Background())
 }
\ No newline at end of file
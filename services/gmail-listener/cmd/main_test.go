@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// fakeOrchestrator records the ids of the triggered emails, the trigger number failAt fails
+type fakeOrchestrator struct {
+	pb.OrchestratorClient
+	fired  []string
+	failAt int
+}
+
+func (o *fakeOrchestrator) TriggerWorkflow(ctx context.Context, req *pb.TriggerRequest, opts ...grpc.CallOption) (*pb.TriggerResponse, error) {
+	if o.failAt == len(o.fired)+1 {
+		return nil, errors.New("orchestrator unavailable")
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(req.InitialPayload), &payload); err != nil {
+		return nil, err
+	}
+	o.fired = append(o.fired, payload["id"])
+	return &pb.TriggerResponse{}, nil
+}
+
+// gmailServer serves the mailbox, newest email first, in pages of pageSize
+func gmailServer(t *testing.T, mailbox []string, pageSize int) (*gmail.Service, *[]string) {
+	queries := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/messages")
+		if path == "" {
+			queries = append(queries, r.URL.Query().Get("q"))
+			start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+			end := min(start+pageSize, len(mailbox))
+			messages := make([]*gmail.Message, 0)
+			for _, id := range mailbox[start:end] {
+				messages = append(messages, &gmail.Message{Id: id})
+			}
+			list := gmail.ListMessagesResponse{Messages: messages}
+			if end < len(mailbox) {
+				list.NextPageToken = strconv.Itoa(end)
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		id := strings.TrimPrefix(path, "/")
+		json.NewEncoder(w).Encode(gmail.Message{Id: id, Snippet: "Body of " + id, Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{
+				{Name: "Subject", Value: "Email " + id},
+				{Name: "From", Value: "Ann <ann@example.com>"},
+			},
+		}})
+	}))
+	t.Cleanup(server.Close)
+
+	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, &queries
+}
+
+func TestFireNewEmails(t *testing.T) {
+	lastCheckAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	// m9 is the newest
+	mailbox := []string{"m9", "m8", "m7", "m6", "m5", "m4", "m3", "m2", "m1"}
+
+	tests := []struct {
+		name            string
+		mailbox         []string
+		checkpoint      poller.Checkpoint
+		failAt          int
+		wantFired       []string
+		wantSeen        []string
+		wantLastMessage string
+		wantLastCheckAt time.Time
+	}{
+		{
+			name:       "first check remembers the emails",
+			mailbox:    mailbox[:3],
+			checkpoint: poller.Checkpoint{},
+			wantFired:  []string{},
+			wantSeen:   []string{"m7", "m8", "m9"},
+		},
+		{
+			name:            "new emails fire oldest first across pages",
+			mailbox:         mailbox,
+			checkpoint:      poller.Checkpoint{LastCheckAt: lastCheckAt, SeenItems: []string{"m1", "m2"}},
+			wantFired:       []string{"m3", "m4", "m5", "m6", "m7", "m8", "m9"},
+			wantSeen:        []string{"m1", "m2", "m3", "m4", "m5", "m6", "m7", "m8", "m9"},
+			wantLastMessage: "m9",
+		},
+		{
+			name:            "last message of an old checkpoint is seen",
+			mailbox:         mailbox[7:],
+			checkpoint:      poller.Checkpoint{LastCheckAt: lastCheckAt, LastMessageId: "m1"},
+			wantFired:       []string{"m2"},
+			wantSeen:        []string{"m1", "m2"},
+			wantLastMessage: "m2",
+		},
+		{
+			name:            "nothing new",
+			mailbox:         []string{},
+			checkpoint:      poller.Checkpoint{LastCheckAt: lastCheckAt, LastMessageId: "m1", SeenItems: []string{"m1"}},
+			wantFired:       []string{},
+			wantSeen:        []string{"m1"},
+			wantLastMessage: "m1",
+		},
+		{
+			name:            "fire failure keeps the time of the last check",
+			mailbox:         mailbox[5:],
+			checkpoint:      poller.Checkpoint{LastCheckAt: lastCheckAt, SeenItems: []string{"m1"}},
+			failAt:          2,
+			wantFired:       []string{"m2"},
+			wantSeen:        []string{"m1", "m2"},
+			wantLastMessage: "m2",
+			wantLastCheckAt: lastCheckAt,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, queries := gmailServer(t, test.mailbox, 2)
+			orchestrator := &fakeOrchestrator{failAt: test.failAt}
+			listener := &GmailListener{Orchestrator: orchestrator}
+			job := poller.Job{NodeId: "node-1", Checkpoint: test.checkpoint}
+			config := &models.GmailListenerConfig{Query: "from:github.com"}
+
+			checkpoint, err := listener.fireNewEmails(context.Background(), srv, job, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fired := append([]string{}, orchestrator.fired...)
+			if !reflect.DeepEqual(fired, test.wantFired) {
+				t.Errorf("fired %v, want %v", fired, test.wantFired)
+			}
+			if !reflect.DeepEqual(checkpoint.SeenItems, test.wantSeen) {
+				t.Errorf("seen items %v, want %v", checkpoint.SeenItems, test.wantSeen)
+			}
+			if checkpoint.LastMessageId != test.wantLastMessage {
+				t.Errorf("last message %q, want %q", checkpoint.LastMessageId, test.wantLastMessage)
+			}
+			if !checkpoint.LastCheckAt.Equal(test.wantLastCheckAt) {
+				t.Errorf("last check at %v, want %v", checkpoint.LastCheckAt, test.wantLastCheckAt)
+			}
+
+			if len(*queries) == 0 || !strings.HasSuffix((*queries)[0], " (from:github.com)") {
+				t.Fatalf("queries %q, want the filter of the node", *queries)
+			}
+			if !test.checkpoint.LastCheckAt.IsZero() {
+				want := fmt.Sprintf("after:%d ", lastCheckAt.Add(-searchOverlap).Unix())
+				if !strings.HasPrefix((*queries)[0], want) {
+					t.Errorf("query %q, want it to start with %q", (*queries)[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestListMessageIdsLimit(t *testing.T) {
+	mailbox := make([]string, 0, maxEmailsPerCheck+listPageSize)
+	for i := len(mailbox); i < cap(mailbox); i++ {
+		mailbox = append(mailbox, fmt.Sprintf("m%d", i))
+	}
+	srv, queries := gmailServer(t, mailbox, listPageSize)
+
+	ids, err := listMessageIds(context.Background(), srv, "after:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, mailbox[:maxEmailsPerCheck]) {
+		t.Errorf("listMessageIds() returned %d ids, want the newest %d", len(ids), maxEmailsPerCheck)
+	}
+	if len(*queries) != maxEmailsPerCheck/listPageSize {
+		t.Errorf("listed %d pages, want %d", len(*queries), maxEmailsPerCheck/listPageSize)
+	}
+}
@@ -4,18 +4,26 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/workers"
 	"golang.org/x/oauth2"
 
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
 )
 
+// The tasks this worker runs, the orchestrator only sends it these
+var tasks = []string{"send-email"}
+
 type GmailServer struct {
 	pb.UnimplementedTaskWorkerServer
 }
@@ -34,11 +42,11 @@ func (s *GmailServer) ExecuteTask(ctx context.Context, req *pb.TaskRequest) (*pb
 		var config EmailConfig
 		// TODO: Validator
 		if err := json.Unmarshal([]byte(req.ConfigJson), &config); err != nil {
-			return &pb.TaskResponse{Success: false, ErrorMessage: "Invalid config JSON"}, nil
+			return &pb.TaskResponse{Success: false, ErrorMessage: "Invalid config JSON", ErrorClass: models.ErrorClassInvalidInput}, nil
 		}
 
 		if req.AuthToken == "" {
-			return &pb.TaskResponse{Success: false, ErrorMessage: "Missing OAuth2 Access Token"}, nil
+			return &pb.TaskResponse{Success: false, ErrorMessage: "Missing OAuth2 Access Token", ErrorClass: models.ErrorClassAuth}, nil
 		}
 
 		client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
@@ -47,7 +55,7 @@ func (s *GmailServer) ExecuteTask(ctx context.Context, req *pb.TaskRequest) (*pb
 
 		srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 		if err != nil {
-			return &pb.TaskResponse{Success: false, ErrorMessage: fmt.Sprintf("Gmail Client Error: %v", err)}, nil
+			return &pb.TaskResponse{Success: false, ErrorMessage: fmt.Sprintf("Gmail Client Error: %v", err), ErrorClass: models.ErrorClassUnknown}, nil
 		}
 
 		messageStr := fmt.Sprintf("To: %s\r\n"+
@@ -60,17 +68,47 @@ func (s *GmailServer) ExecuteTask(ctx context.Context, req *pb.TaskRequest) (*pb
 			Raw: base64.URLEncoding.EncodeToString([]byte(messageStr)),
 		}
 
-		_, err = srv.Users.Messages.Send("me", gMessage).Do()
+		// The orchestrator cancels ctx when the execution is cancelled or the node times out
+		_, err = srv.Users.Messages.Send("me", gMessage).Context(ctx).Do()
 		if err != nil {
-			// TODO: Handle Google API specific errors (e.g., 401 Unauthorized, 403 Quota Exceeded)
 			return &pb.TaskResponse{
 				Success:      false,
 				ErrorMessage: fmt.Sprintf("Google API Error: %v", err),
+				ErrorClass:   googleErrorClass(err),
 			}, nil
 		}
 		return &pb.TaskResponse{Success: true, OutputPayload: `{"status": "sent"}`}, nil
 	}
-	return &pb.TaskResponse{Success: false, ErrorMessage: "Invalid task name"}, nil
+	return &pb.TaskResponse{Success: false, ErrorMessage: "Invalid task name", ErrorClass: models.ErrorClassInvalidInput}, nil
+}
+
+// googleErrorClass tells the orchestrator if a failed Gmail call is worth retrying
+func googleErrorClass(err error) string {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		// The request did not reach Google
+		return models.ErrorClassTransient
+	}
+
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return models.ErrorClassRateLimited
+	case apiErr.Code == http.StatusForbidden:
+		// Quota errors are reported as 403 too
+		for _, item := range apiErr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return models.ErrorClassRateLimited
+			}
+		}
+		return models.ErrorClassAuth
+	case apiErr.Code == http.StatusUnauthorized:
+		return models.ErrorClassAuth
+	case apiErr.Code >= 500:
+		return models.ErrorClassTransient
+	case apiErr.Code >= 400:
+		return models.ErrorClassInvalidInput
+	}
+	return models.ErrorClassUnknown
 }
 
 func main() {
@@ -82,6 +120,13 @@ func main() {
 	s := grpc.NewServer()
 	pb.RegisterTaskWorkerServer(s, &GmailServer{})
 
+	// WORKER_ADDRESS is where the orchestrator reaches this worker
+	go workers.Register(workers.Getenv("ORCHESTRATOR_ADDRESS", "localhost:50051"), &pb.RegisterWorkerRequest{
+		ServiceName: "gmail",
+		Address:     workers.Getenv("WORKER_ADDRESS", "localhost:50052"),
+		Tasks:       tasks,
+	})
+
 	log.Println("Gmail Service running on :50052")
 	if err := s.Serve(listener); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
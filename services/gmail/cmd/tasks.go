@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// The get-email listener is polled by the gmail-listener service, the worker describes it as part of the gmail service
+var taskDescriptions = []*pb.TaskDescription{
+	{
+		Name:        models.GmailListenerTask,
+		NodeType:    "listener",
+		DisplayName: "New email",
+		ConfigSchema: `{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "title": "Search query", "description": "Gmail search syntax, e.g. from:github.com has:attachment -category:promotions"},
+				"labels": {"type": "array", "title": "Labels", "description": "Only emails with one of these labels", "items": {"type": "string"}}
+			},
+			"additionalProperties": false
+		}`,
+		OutputSchema: `{
+			"type": "object",
+			"properties": {
+				"email_subject": {"type": "string"},
+				"email_from": {"type": "string"},
+				"email_body": {"type": "string", "description": "Snippet of the body"},
+				"id": {"type": "string", "description": "Gmail message id"}
+			}
+		}`,
+		OauthScopes: []string{"https://www.googleapis.com/auth/gmail.readonly"},
+	},
+	{
+		Name:        "send-email",
+		NodeType:    "action",
+		DisplayName: "Send email",
+		// Fields which are left out are taken from the user's email template
+		ConfigSchema: `{
+			"type": "object",
+			"properties": {
+				"to": {"type": "string", "title": "To"},
+				"subject": {"type": "string", "title": "Subject"},
+				"body": {"type": "string", "title": "Body"}
+			}
+		}`,
+		OutputSchema: `{"type": "object", "properties": {"status": {"type": "string", "enum": ["sent"]}}}`,
+		OauthScopes:  []string{"https://www.googleapis.com/auth/gmail.send"},
+	},
+}
+
+func (s *GmailServer) DescribeTasks(ctx context.Context, req *pb.DescribeTasksRequest) (*pb.DescribeTasksResponse, error) {
+	return &pb.DescribeTasksResponse{Tasks: taskDescriptions}, nil
+}
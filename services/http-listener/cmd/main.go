@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/workers"
+)
+
+// Every node has its own interval, this is how often the nodes which are due are looked for
+const pollInterval = 20 * time.Second
+
+const requestTimeout = 10 * time.Second
+
+// Largest response which is read
+const maxResponseSize = 5 << 20
+
+// At least this many ids are remembered per node, more when the endpoint returns more items
+const maxSeenItems = 1000
+
+// HttpListener fires a workflow for every new item in the response of an HTTP endpoint
+type HttpListener struct {
+	Client       *http.Client
+	UserService  pb.UserServiceClient
+	Orchestrator pb.OrchestratorClient
+	// Finds the http listener nodes and stores the items they have seen
+	Poller *poller.Poller
+}
+
+// An item of the response with the id it is recognised by
+type pollItem struct {
+	Id    string
+	Value interface{}
+}
+
+// The trigger payload of an http listener
+type itemPayload struct {
+	Item   interface{} `json:"item"`
+	ItemId string      `json:"item_id"`
+	URL    string      `json:"url"`
+}
+
+// CheckForNewItems is the check of the poller. The first check only remembers the items which are there already
+func (l *HttpListener) CheckForNewItems(ctx context.Context, job poller.Job) (*poller.Checkpoint, error) {
+	poll, err := models.ParseHttpPollConfig(job.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	items, err := l.fetchItems(ctx, job, poll)
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll %s: %v", poll.Config.URL, err)
+	}
+
+	fired := make([]pollItem, 0)
+	if !job.Checkpoint.LastCheckAt.IsZero() {
+		for _, item := range newItems(items, job.Checkpoint.SeenItems) {
+			if err := l.fire(ctx, job, poll, item); err != nil {
+				log.Printf("Failed to trigger workflow %d for node %s: %v", job.WorkflowId, job.NodeId, err)
+				// The rest is new again on the next check
+				items = withoutNew(items, job.Checkpoint.SeenItems, fired)
+				break
+			}
+			fired = append(fired, item)
+		}
+	}
+	log.Printf("Node %s: %d items, %d new", job.NodeId, len(items), len(fired))
+
+	return &poller.Checkpoint{SeenItems: seenAfter(job.Checkpoint.SeenItems, items)}, nil
+}
+
+// pollIntervalOf is how often the poller checks a node
+func pollIntervalOf(job poller.Job) (time.Duration, error) {
+	poll, err := models.ParseHttpPollConfig(job.Config)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(poll.Config.IntervalSeconds) * time.Second, nil
+}
+
+func (l *HttpListener) fetchItems(ctx context.Context, job poller.Job, poll *models.HttpPoll) ([]pollItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if poll.Config.Body != "" {
+		body = strings.NewReader(poll.Config.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, poll.Config.Method, poll.Config.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range poll.Config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// The credential of the node is sent as a bearer token, unless the headers authenticate already.
+	// It has to belong to the user of the workflow and is only sent to the API of its service
+	if job.CredentialId != nil && req.Header.Get("Authorization") == "" {
+		tokenResp, err := l.UserService.GetCredentials(ctx, &pb.GetCredentialsRequest{
+			CredentialId: *job.CredentialId,
+			UserId:       int64(job.UserId),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("auth failure: %w", err)
+		}
+		if !models.CredentialAllowed(tokenResp.ServiceName, poll.Config.URL) {
+			return nil, fmt.Errorf("the %s credential can't be sent to %s", tokenResp.ServiceName, req.URL.Host)
+		}
+		req.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+	}
+
+	res, err := l.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	// Numbers are kept as they are written, large ids like snowflakes don't fit into a float64
+	var document interface{}
+	decoder := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %v", err)
+	}
+	return extractItems(document, poll)
+}
+
+// extractItems returns the items at the items path with their ids
+func extractItems(document interface{}, poll *models.HttpPoll) ([]pollItem, error) {
+	value, ok := poll.Items.Get(document)
+	if !ok {
+		return nil, fmt.Errorf("nothing at %s", poll.Config.ItemsPath)
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", poll.Config.ItemsPath)
+	}
+
+	items := make([]pollItem, 0, len(list))
+	for index, value := range list {
+		id, err := itemId(value, poll)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", index, err)
+		}
+		items = append(items, pollItem{Id: id, Value: value})
+	}
+	return items, nil
+}
+
+// itemId is the value at the id path, or the hash of the item when items are compared by content
+func itemId(item interface{}, poll *models.HttpPoll) (string, error) {
+	if poll.Id == nil {
+		// Object keys are sorted when encoding, so equal items hash the same
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(encoded)
+		return "sha256:" + hex.EncodeToString(sum[:]), nil
+	}
+
+	id, ok := poll.Id.Get(item)
+	if !ok || id == nil {
+		return "", fmt.Errorf("no id at %s", poll.Config.IdPath)
+	}
+	switch id := id.(type) {
+	case string:
+		return id, nil
+	case json.Number:
+		return id.String(), nil
+	}
+	encoded, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// newItems returns the items which were not seen before, in the order of the response
+func newItems(items []pollItem, seen []string) []pollItem {
+	known := make(map[string]bool, len(seen))
+	for _, id := range seen {
+		known[id] = true
+	}
+	fresh := make([]pollItem, 0)
+	for _, item := range items {
+		if !known[item.Id] {
+			known[item.Id] = true
+			fresh = append(fresh, item)
+		}
+	}
+	return fresh
+}
+
+// withoutNew drops the new items which did not fire, so they are not remembered as seen
+func withoutNew(items []pollItem, seen []string, fired []pollItem) []pollItem {
+	keep := make(map[string]bool, len(seen)+len(fired))
+	for _, id := range seen {
+		keep[id] = true
+	}
+	for _, item := range fired {
+		keep[item.Id] = true
+	}
+	kept := make([]pollItem, 0, len(items))
+	for _, item := range items {
+		if keep[item.Id] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// seenAfter is the ids to remember after a check which returned items
+func seenAfter(seen []string, items []pollItem) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Id)
+	}
+	return poller.RememberSeen(seen, ids, maxSeenItems)
+}
+
+func (l *HttpListener) fire(ctx context.Context, job poller.Job, poll *models.HttpPoll, item pollItem) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(itemPayload{Item: item.Value, ItemId: item.Id, URL: poll.Config.URL})
+	if err != nil {
+		return err
+	}
+	_, err = l.Orchestrator.TriggerWorkflow(ctx, &pb.TriggerRequest{
+		ListenerNodeId: job.NodeId,
+		InitialPayload: string(payload),
+	})
+	return err
+}
+
+func main() {
+	db, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/was_api?parseTime=true&loc=UTC")
+	if err != nil {
+		log.Fatal("Could not connect to db", err)
+		return
+	}
+
+	userConn, _ := grpc.NewClient("localhost:50055", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	defer userConn.Close()
+	orchConn, _ := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	defer orchConn.Close()
+
+	// The listener describes the poll task for the catalog, it runs no actions
+	grpcListener, err := net.Listen("tcp", ":50058")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterTaskWorkerServer(grpcServer, &HttpServer{})
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	go workers.Register(workers.Getenv("ORCHESTRATOR_ADDRESS", "localhost:50051"), &pb.RegisterWorkerRequest{
+		ServiceName: models.HttpServiceName,
+		Address:     workers.Getenv("WORKER_ADDRESS", "localhost:50058"),
+		Tasks:       []string{},
+	})
+
+	listener := &HttpListener{
+		Client:       &http.Client{},
+		UserService:  pb.NewUserServiceClient(userConn),
+		Orchestrator: pb.NewOrchestratorClient(orchConn),
+	}
+	listener.Poller = &poller.Poller{
+		Db:           db,
+		ServiceName:  models.HttpServiceName,
+		Interval:     pollInterval,
+		Check:        listener.CheckForNewItems,
+		NodeInterval: pollIntervalOf,
+	}
+
+	log.Printf("HTTP Listener started. Looking for due nodes every %v...\n", pollInterval)
+	listener.Poller.Run(context.Background())
+}
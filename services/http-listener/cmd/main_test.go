@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/poller"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// fakeOrchestrator records the triggered payloads, the trigger number failAt fails
+type fakeOrchestrator struct {
+	pb.OrchestratorClient
+	fired  []itemPayload
+	failAt int
+}
+
+func (o *fakeOrchestrator) TriggerWorkflow(ctx context.Context, req *pb.TriggerRequest, opts ...grpc.CallOption) (*pb.TriggerResponse, error) {
+	if o.failAt == len(o.fired)+1 {
+		return nil, errors.New("orchestrator unavailable")
+	}
+	var payload itemPayload
+	if err := json.Unmarshal([]byte(req.InitialPayload), &payload); err != nil {
+		return nil, err
+	}
+	o.fired = append(o.fired, payload)
+	return &pb.TriggerResponse{}, nil
+}
+
+type fakeUserService struct {
+	pb.UserServiceClient
+	serviceName string
+}
+
+func (s *fakeUserService) GetCredentials(ctx context.Context, req *pb.GetCredentialsRequest, opts ...grpc.CallOption) (*pb.GetCredentialsResponse, error) {
+	return &pb.GetCredentialsResponse{AccessToken: "token", Success: true, ServiceName: s.serviceName}, nil
+}
+
+func TestCheckForNewItems(t *testing.T) {
+	itemsById := `{"data": [{"id": 3, "name": "c"}, {"id": 2, "name": "b"}, {"id": 1, "name": "a"}]}`
+	itemsByHash := `[{"name": "a", "price": 1}, {"name": "b", "price": 5}]`
+	hashOf := func(item string) string {
+		var value interface{}
+		json.Unmarshal([]byte(item), &value)
+		id, _ := itemId(value, &models.HttpPoll{})
+		return id
+	}
+
+	tests := []struct {
+		name       string
+		config     string
+		response   string
+		seen       []string
+		firstCheck bool
+		failAt     int
+		wantFired  []string
+		wantSeen   []string
+		wantErr    bool
+	}{
+		{
+			name:       "first check remembers the items",
+			config:     `{"items_path": "$.data", "id_path": "$.id"}`,
+			response:   itemsById,
+			firstCheck: true,
+			wantFired:  []string{},
+			wantSeen:   []string{"3", "2", "1"},
+		},
+		{
+			name:      "new items fire",
+			config:    `{"items_path": "$.data", "id_path": "$.id"}`,
+			response:  itemsById,
+			seen:      []string{"1"},
+			wantFired: []string{"3", "2"},
+			wantSeen:  []string{"3", "2", "1"},
+		},
+		{
+			name:      "nothing new",
+			config:    `{"items_path": "$.data", "id_path": "$.id"}`,
+			response:  itemsById,
+			seen:      []string{"0", "1", "2", "3"},
+			wantFired: []string{},
+			wantSeen:  []string{"0", "3", "2", "1"},
+		},
+		{
+			name:      "fire failure keeps the rest new",
+			config:    `{"items_path": "$.data", "id_path": "$.id"}`,
+			response:  itemsById,
+			seen:      []string{"1"},
+			failAt:    2,
+			wantFired: []string{"3"},
+			wantSeen:  []string{"3", "1"},
+		},
+		{
+			name:      "hash mode fires changed items",
+			config:    `{}`,
+			response:  itemsByHash,
+			seen:      []string{hashOf(`{"name": "a", "price": 1}`), hashOf(`{"name": "b", "price": 4}`)},
+			wantFired: []string{hashOf(`{"name": "b", "price": 5}`)},
+			wantSeen: []string{
+				hashOf(`{"name": "b", "price": 4}`),
+				hashOf(`{"name": "a", "price": 1}`),
+				hashOf(`{"name": "b", "price": 5}`),
+			},
+		},
+		{
+			name:      "large numeric ids stay apart",
+			config:    `{"id_path": "$.id"}`,
+			response:  `[{"id": 1234567890123456789}, {"id": 1234567890123456788}]`,
+			seen:      []string{"1234567890123456788"},
+			wantFired: []string{"1234567890123456789"},
+			wantSeen:  []string{"1234567890123456789", "1234567890123456788"},
+		},
+		{name: "items path is not an array", config: `{"items_path": "$.data"}`, response: `{"data": {}}`, wantErr: true},
+		{name: "item without id", config: `{"id_path": "$.id"}`, response: `[{"name": "a"}]`, wantErr: true},
+		{name: "invalid JSON", config: `{}`, response: `<html>`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+
+			var config map[string]interface{}
+			json.Unmarshal([]byte(test.config), &config)
+			config["url"] = server.URL
+			configJSON, _ := json.Marshal(config)
+
+			orchestrator := &fakeOrchestrator{failAt: test.failAt}
+			listener := &HttpListener{Client: server.Client(), Orchestrator: orchestrator}
+			job := poller.Job{NodeId: "node-1", Config: string(configJSON), Checkpoint: poller.Checkpoint{SeenItems: test.seen}}
+			if !test.firstCheck {
+				job.Checkpoint.LastCheckAt = time.Now().Add(-time.Hour)
+			}
+
+			checkpoint, err := listener.CheckForNewItems(context.Background(), job)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("CheckForNewItems() = %+v, want an error", checkpoint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fired := make([]string, 0)
+			for _, payload := range orchestrator.fired {
+				fired = append(fired, payload.ItemId)
+				if payload.URL != server.URL {
+					t.Errorf("payload url = %q, want %q", payload.URL, server.URL)
+				}
+			}
+			if !reflect.DeepEqual(fired, test.wantFired) {
+				t.Errorf("fired %v, want %v", fired, test.wantFired)
+			}
+			if !reflect.DeepEqual(checkpoint.SeenItems, test.wantSeen) {
+				t.Errorf("seen items %v, want %v", checkpoint.SeenItems, test.wantSeen)
+			}
+		})
+	}
+}
+
+func TestCheckForNewItemsCredential(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	credentialId := int32(4)
+	job := poller.Job{NodeId: "node-1", UserId: 2, CredentialId: &credentialId, Config: `{"url": "` + server.URL + `"}`}
+	listener := &HttpListener{
+		Client:       server.Client(),
+		UserService:  &fakeUserService{serviceName: models.GmailServiceName},
+		Orchestrator: &fakeOrchestrator{},
+	}
+
+	_, err := listener.CheckForNewItems(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "can't be sent") {
+		t.Errorf("CheckForNewItems() returned %v, want the credential to be refused", err)
+	}
+	if authorization != "" {
+		t.Errorf("the server received Authorization %q", authorization)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// HttpServer only describes the poll listener, there is nothing to execute
+type HttpServer struct {
+	pb.UnimplementedTaskWorkerServer
+}
+
+var taskDescriptions = []*pb.TaskDescription{
+	{
+		Name:        models.HttpPollTask,
+		NodeType:    "listener",
+		DisplayName: "Poll an HTTP endpoint",
+		ConfigSchema: `{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "title": "URL", "description": "http or https"},
+				"method": {"type": "string", "title": "Method", "enum": ["GET", "POST", "PUT", "PATCH"], "default": "GET"},
+				"headers": {"type": "object", "title": "Headers", "description": "Name to value"},
+				"body": {"type": "string", "title": "Body"},
+				"items_path": {"type": "string", "title": "Items", "description": "JSONPath to the list of items, e.g. $.data.items", "default": "$"},
+				"id_path": {"type": "string", "title": "Item id", "description": "JSONPath inside an item, e.g. $.id. Without it an item is new when its content changed"},
+				"interval_seconds": {"type": "integer", "title": "Interval in seconds", "description": "At least 20", "default": 60}
+			},
+			"required": ["url"],
+			"additionalProperties": false
+		}`,
+		OutputSchema: `{
+			"type": "object",
+			"properties": {
+				"item": {"description": "The new item as returned by the endpoint"},
+				"item_id": {"type": "string", "description": "Value at the id path, or sha256:<hex> of the item"},
+				"url": {"type": "string"}
+			}
+		}`,
+	},
+}
+
+func (s *HttpServer) DescribeTasks(ctx context.Context, req *pb.DescribeTasksRequest) (*pb.DescribeTasksResponse, error) {
+	return &pb.DescribeTasksResponse{Tasks: taskDescriptions}, nil
+}
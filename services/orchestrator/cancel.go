@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// How often a running execution checks if its user cancelled it
+const cancelPollInterval = 2 * time.Second
+
+var (
+	// ErrExecutionCancelled is the cause of the context of an execution which was cancelled by its user
+	ErrExecutionCancelled = errors.New("execution cancelled")
+	// ErrExecutionTimedOut is the cause of the context of an execution which ran longer than its workflow allows
+	ErrExecutionTimedOut = errors.New("execution timed out")
+)
+
+// NodeTimeoutError is an attempt of a node which ran longer than the timeout in the node's settings
+type NodeTimeoutError struct {
+	Node    string
+	Timeout time.Duration
+}
+
+func (err NodeTimeoutError) Error() string {
+	return fmt.Sprintf("node %s timed out after %v", err.Node, err.Timeout)
+}
+
+// executionContext is the context the execution runs with. It is cancelled when the user cancels the
+// execution and expires max_duration_seconds after the execution first started, waiting included
+func (orchestrator *OrchestratorService) executionContext(ctx context.Context, execution *models.Execution, workflow *models.Workflow) (context.Context, context.CancelFunc) {
+	execCtx, cancel := context.WithCancelCause(ctx)
+	stop := func() { cancel(nil) }
+
+	if workflow.MaxDurationSeconds > 0 {
+		startedAt := time.Now()
+		if execution.StartedAt != nil {
+			startedAt = *execution.StartedAt
+		}
+		deadline := startedAt.Add(time.Duration(workflow.MaxDurationSeconds) * time.Second)
+		var cancelDeadline context.CancelFunc
+		execCtx, cancelDeadline = context.WithDeadlineCause(execCtx, deadline, ErrExecutionTimedOut)
+		stop = func() {
+			cancelDeadline()
+			cancel(nil)
+		}
+	}
+
+	go orchestrator.watchCancellation(execCtx, cancel, execution.Id)
+	return execCtx, stop
+}
+
+// watchCancellation cancels the execution once its user asked for it, until ctx is done
+func (orchestrator *OrchestratorService) watchCancellation(ctx context.Context, cancel context.CancelCauseFunc, executionId int) {
+	executionRepo := repositories.Execution{Db: orchestrator.Db}
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := executionRepo.IsCancelRequested(executionId)
+			if err != nil {
+				log.Printf("Failed to check cancellation of execution %d: %v", executionId, err)
+				continue
+			}
+			if cancelRequested {
+				log.Printf("Cancelling execution %d", executionId)
+				cancel(ErrExecutionCancelled)
+				return
+			}
+		}
+	}
+}
+
+// interruptedStatus is the final status of an execution or step whose context was stopped
+// by a cancellation or a timeout. Anything else, e.g. the worker shutting down, is not final
+func interruptedStatus(ctx context.Context) (models.ExecutionStatus, bool) {
+	cause := context.Cause(ctx)
+	switch {
+	case errors.Is(cause, ErrExecutionCancelled):
+		return models.Cancelled, true
+	case errors.Is(cause, ErrExecutionTimedOut):
+		return models.TimedOut, true
+	default:
+		return 0, false
+	}
+}
@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/services/orchestrator"
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
 )
 
@@ -19,23 +27,86 @@ import (
 type OrchestratorServiceServer struct {
 	pb.UnimplementedOrchestratorServer
 	OrchestratorService *orchestrator.OrchestratorService
+	// Workers have to send it to register, registration is disabled without one.
+	// Whoever registers a service receives its tasks, including the users' access tokens
+	RegistrationSecret string
 }
 
 func (s *OrchestratorServiceServer) TriggerWorkflow(ctx context.Context, req *pb.TriggerRequest) (*pb.TriggerResponse, error) {
-	// Note: In a real system, use RabbitMQ or some other message broker here
-	go func() {
-		err := s.OrchestratorService.ExecuteWorkflow(context.Background(), req.ListenerNodeId, req.InitialPayload)
-		if err != nil {
-			log.Printf("Background execution failed: %v", err)
+	// The execution is queued, a worker picks it up
+	execution, err := s.OrchestratorService.CreateExecution(ctx, req.ListenerNodeId, req.InitialPayload, req.DryRun)
+	if err != nil {
+		log.Printf("Could not create execution: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.TriggerResponse{
+		ExecutionId: int32(execution.Id),
+		Success:     true,
+	}, nil
+}
+
+// ReplayExecution runs a finished execution again, completely or from the nodes which failed or a chosen node
+func (s *OrchestratorServiceServer) ReplayExecution(ctx context.Context, req *pb.ReplayRequest) (*pb.TriggerResponse, error) {
+	execution, err := s.OrchestratorService.ReplayExecution(ctx, int(req.ExecutionId), orchestrator.ReplayOptions{
+		FromFailure: req.FromFailure,
+		FromNode:    req.FromNode,
+	})
+	if err != nil {
+		var replayErr orchestrator.ReplayError
+		if errors.As(err, &replayErr) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
 		}
-	}()
+		if errors.Is(err, errs.NotFoundError{EntityName: "Execution"}) {
+			return nil, status.Error(codes.NotFound, "execution not found")
+		}
+		log.Printf("Could not replay execution %d: %v", req.ExecutionId, err)
+		return nil, status.Error(codes.Internal, "failed to replay execution")
+	}
 
 	return &pb.TriggerResponse{
-		ExecutionId: int32(1), // TODO: Get from DB, maybe uuid
+		ExecutionId: int32(execution.Id),
 		Success:     true,
 	}, nil
 }
 
+// RegisterWorker stores where the worker of a service runs, actions of the service are sent there from now on
+func (s *OrchestratorServiceServer) RegisterWorker(ctx context.Context, req *pb.RegisterWorkerRequest) (*pb.RegisterWorkerResponse, error) {
+	if s.RegistrationSecret == "" {
+		return nil, status.Error(codes.PermissionDenied, "worker registration is disabled, the orchestrator has no WORKER_REGISTRATION_SECRET")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Secret), []byte(s.RegistrationSecret)) != 1 {
+		log.Printf("Rejected registration of worker %s at %s: wrong secret", req.ServiceName, req.Address)
+		return nil, status.Error(codes.Unauthenticated, "invalid registration secret")
+	}
+
+	registration := orchestrator.WorkerRegistration{
+		ServiceName: req.ServiceName,
+		Address:     req.Address,
+		Tasks:       req.Tasks,
+	}
+	if err := registration.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.OrchestratorService.Workers.Register(ctx, registration); err != nil {
+		log.Printf("Could not register worker %s: %v", req.ServiceName, err)
+		return nil, status.Error(codes.Internal, "failed to register worker")
+	}
+
+	log.Printf("Worker %s registered at %s with tasks %v", req.ServiceName, req.Address, req.Tasks)
+	return &pb.RegisterWorkerResponse{Success: true}, nil
+}
+
+// GetCatalog lists the tasks of the registered workers, the API and the workflow service build on it
+func (s *OrchestratorServiceServer) GetCatalog(ctx context.Context, req *pb.GetCatalogRequest) (*pb.GetCatalogResponse, error) {
+	services, err := s.OrchestratorService.Workers.Catalog(ctx)
+	if err != nil {
+		log.Printf("Could not build catalog: %v", err)
+		return nil, status.Error(codes.Internal, "failed to build catalog")
+	}
+	return &pb.GetCatalogResponse{Services: services}, nil
+}
+
 func main() {
 	// parseTime = true -> parses DATETIME into time.Time
 	// TODO: Change this to some other port
@@ -45,27 +116,61 @@ func main() {
         return;
     }
 
-	// TODO: Use Service Discovery/Registry
-
-	gmailConn, _ := grpc.NewClient("localhost:50052", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	defer gmailConn.Close()
+	// Workers register themselves on startup with WORKER_REGISTRATION_SECRET, WORKER_REGISTRY_FILE can list them up front
+	registrationSecret := os.Getenv("WORKER_REGISTRATION_SECRET")
+	if registrationSecret == "" {
+		log.Println("WORKER_REGISTRATION_SECRET is not set, only the workers of WORKER_REGISTRY_FILE are used")
+	}
+	workers := orchestrator.NewWorkerRegistry(db)
+	defer workers.Close()
+	if path := os.Getenv("WORKER_REGISTRY_FILE"); path != "" {
+		if err := workers.LoadFile(context.Background(), path); err != nil {
+			log.Fatalf("Failed to load workers: %v", err)
+		}
+	}
 
 	userConn, _ := grpc.NewClient("localhost:50055", grpc.WithTransportCredentials(insecure.NewCredentials()))
 	defer userConn.Close()
 
-	orchestrator := &orchestrator.OrchestratorService{
+	// EXECUTION_QUEUE=memory keeps the queue in the process, e.g. for local development
+	var queue orchestrator.Queue = &orchestrator.MySQLQueue{Db: db}
+	if os.Getenv("EXECUTION_QUEUE") == "memory" {
+		queue = orchestrator.NewMemoryQueue()
+	}
+
+	orchestratorService := &orchestrator.OrchestratorService{
 		Db: db,
-		GmailService: pb.NewTaskWorkerClient(gmailConn),
+		Workers: workers,
 		UserService: pb.NewUserServiceClient(userConn),
+		Queue: queue,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	worker := &orchestrator.Worker{Orchestrator: orchestratorService}
+	go func() {
+		if err := worker.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Fatalf("Worker stopped: %v", err)
+		}
+	}()
+
 	listener, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
 	grpcServer := grpc.NewServer()
-	pb.RegisterOrchestratorServer(grpcServer, &OrchestratorServiceServer{OrchestratorService: orchestrator})
+	pb.RegisterOrchestratorServer(grpcServer, &OrchestratorServiceServer{
+		OrchestratorService: orchestratorService,
+		RegistrationSecret:  registrationSecret,
+	})
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		grpcServer.GracefulStop()
+	}()
 
 	log.Println("Orchestrator Service running on :50051...")
 	if err := grpcServer.Serve(listener); err != nil {
@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// Branch taken by a switch node when no case matches
+const defaultBranch = "default"
+
+// Condition nodes have two tasks:
+//
+//	if:     {"expression": "endsWith(trigger.email_from, '@customer.com')"} -> branch "true" or "false"
+//	switch: {"expression": "lower(trigger.category)", "cases": ["billing", "support"]} -> branch "billing", "support" or "default"
+type conditionConfig struct {
+	Expression string   `json:"expression"`
+	Cases      []string `json:"cases"`
+}
+
+type conditionOutput struct {
+	Result interface{} `json:"result"`
+	Branch string      `json:"branch"`
+}
+
+func (orchestrator *OrchestratorService) executeCondition(node models.WorkflowNode, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	var config conditionConfig
+	if err := json.Unmarshal([]byte(node.Config), &config); err != nil {
+		return "", fmt.Errorf("invalid config of condition node %s: %v", node.DisplayId, err)
+	}
+	if config.Expression == "" {
+		return "", fmt.Errorf("condition node %s has no expression", node.DisplayId)
+	}
+	step.Input = node.Config
+
+	result, err := evaluateExpression(config.Expression, state.Snapshot())
+	if err != nil {
+		return "", fmt.Errorf("condition node %s: %w", node.DisplayId, err)
+	}
+
+	output := conditionOutput{Result: result}
+	switch node.TaskName {
+	case "if":
+		output.Branch = fmt.Sprint(isTruthy(result))
+	case "switch":
+		output.Branch = defaultBranch
+		value := toText(result)
+		for _, c := range config.Cases {
+			if c == value {
+				output.Branch = c
+				break
+			}
+		}
+	default:
+		return "", fmt.Errorf("unknown condition task %s", node.TaskName)
+	}
+
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// selectedBranch reads the branch back from the output of a condition node
+func selectedBranch(output interface{}) string {
+	if outputMap, ok := output.(map[string]interface{}); ok {
+		if branch, ok := outputMap["branch"].(string); ok {
+			return branch
+		}
+	}
+	return ""
+}
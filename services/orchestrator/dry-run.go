@@ -0,0 +1,46 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// What an action of a dry run would have sent to its worker, stored on its step
+type simulatedRequest struct {
+	ServiceName string          `json:"service_name"`
+	TaskName    string          `json:"task_name"`
+	Config      json.RawMessage `json:"config"`
+}
+
+// simulateAction records the task instead of sending it. The action returns the mock_output
+// from the node's settings, with its variables resolved, or {} without one
+func (orchestrator *OrchestratorService) simulateAction(node models.WorkflowNode, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	config := json.RawMessage(step.Input)
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	request, err := json.Marshal(simulatedRequest{
+		ServiceName: node.ServiceName,
+		TaskName:    node.TaskName,
+		Config:      config,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode simulated request of node %s: %v", node.DisplayId, err)
+	}
+	step.SimulatedRequest = string(request)
+
+	settings, err := models.ParseNodeSettings(node.Settings)
+	if err != nil {
+		return "", TaskError{Class: models.ErrorClassInvalidInput, Message: fmt.Sprintf("invalid settings of node %s: %v", node.DisplayId, err)}
+	}
+	if len(settings.MockOutput) == 0 {
+		return "{}", nil
+	}
+	output, err := resolveVariables(string(settings.MockOutput), state.Snapshot())
+	if err != nil {
+		return "", fmt.Errorf("variable resolution failed for the mock output of node %s: %w", node.DisplayId, err)
+	}
+	return output, nil
+}
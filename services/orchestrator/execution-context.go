@@ -0,0 +1,63 @@
+package orchestrator
+
+import "sync"
+
+type ExecutionContext struct {
+	WorkflowID  int
+	ExecutionID int
+	// How deep the execution is in a chain of sub-workflows
+	Depth int
+	// Actions are recorded instead of being sent to their workers
+	DryRun bool
+	// The "Bag of State"
+	// Every step also stores its output in execution_steps.
+	// Parallel branches write into it, so it is only accessed through Set and Snapshot
+	CurrentData map[string]interface{}
+	mu          sync.RWMutex
+	// Set on the scope of a loop iteration, reads fall back to it
+	parent *ExecutionContext
+}
+
+func NewExecutionContext(workflowId int, executionId int, triggerData interface{}) *ExecutionContext {
+	return &ExecutionContext{
+		WorkflowID:  workflowId,
+		ExecutionID: executionId,
+		CurrentData: map[string]interface{}{"trigger": triggerData},
+	}
+}
+
+// NewScope returns the context of one loop iteration. It sees everything in its parent, while the
+// values (item and index) and the outputs of the nodes which run inside the iteration stay in the scope
+func (state *ExecutionContext) NewScope(values map[string]interface{}) *ExecutionContext {
+	return &ExecutionContext{
+		WorkflowID:  state.WorkflowID,
+		ExecutionID: state.ExecutionID,
+		Depth:       state.Depth,
+		DryRun:      state.DryRun,
+		CurrentData: values,
+		parent:      state,
+	}
+}
+
+// Set stores the output of a node under its display id
+func (state *ExecutionContext) Set(key string, value interface{}) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.CurrentData[key] = value
+}
+
+// Snapshot returns a copy of the state which can be read while other branches keep running.
+// Outputs are never modified after they are stored, so a shallow copy is enough
+func (state *ExecutionContext) Snapshot() map[string]interface{} {
+	snapshot := make(map[string]interface{})
+	if state.parent != nil {
+		snapshot = state.parent.Snapshot()
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	for key, value := range state.CurrentData {
+		snapshot[key] = value
+	}
+	return snapshot
+}
@@ -0,0 +1,509 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A small expression language for conditions over the execution state:
+//
+//	endsWith(trigger.email_from, "@customer.com") && !contains(lower(trigger.email_subject), "unsubscribe")
+//	{{node-1.count}} >= 10 || trigger.labels[0] == "urgent"
+//
+// Paths are resolved like config variables, the {{ }} around them are optional.
+// Supported operators: || && ! == != < <= > >= and parentheses.
+// && and || short circuit, so exists(trigger.x) && trigger.x == "a" guards against a missing x.
+
+type ExpressionError struct {
+	Expression string
+	Reason     string
+}
+
+func (err ExpressionError) Error() string {
+	return fmt.Sprintf("invalid expression %q: %s", err.Expression, err.Reason)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenPath
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+var expressionOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+func isPathChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '[' || r == ']'
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ","})
+			i++
+		case r == '"' || r == '\'':
+			// Strings are JSON style, single quotes are allowed so they can be used inside JSON configs
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			raw := string(runes[i+1 : end])
+			if r == '\'' {
+				raw = strings.ReplaceAll(strings.ReplaceAll(raw, `\'`, `'`), `"`, `\"`)
+			}
+			value, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", string(runes[i:end+1]))
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		case r == '{' && i+1 < len(runes) && runes[i+1] == '{':
+			end := i + 2
+			for end+1 < len(runes) && !(runes[end] == '}' && runes[end+1] == '}') {
+				end++
+			}
+			if end+1 >= len(runes) {
+				return nil, fmt.Errorf("missing }}")
+			}
+			tokens = append(tokens, token{tokenPath, strings.TrimSpace(string(runes[i+2 : end]))})
+			i = end + 2
+		case isPathChar(r):
+			end := i
+			for end < len(runes) && isPathChar(runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				tokens = append(tokens, token{tokenNumber, word})
+			} else {
+				tokens = append(tokens, token{tokenPath, word})
+			}
+			i = end
+		default:
+			matched := false
+			for _, operator := range expressionOperators {
+				if strings.HasPrefix(string(runes[i:]), operator) {
+					tokens = append(tokens, token{tokenOperator, operator})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+		}
+	}
+	return append(tokens, token{tokenEOF, ""}), nil
+}
+
+type expressionParser struct {
+	tokens []token
+	pos    int
+	data   map[string]interface{}
+	// Set while parsing the side of && or || whose value doesn't matter.
+	// It is still parsed, so syntax errors are reported, but nothing is resolved or called
+	skip bool
+}
+
+// evaluateExpression parses and evaluates the expression against the execution state
+func evaluateExpression(expression string, data map[string]interface{}) (interface{}, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, ExpressionError{Expression: expression, Reason: err.Error()}
+	}
+
+	parser := &expressionParser{tokens: tokens, data: data}
+	value, err := parser.parseOr()
+	if err != nil {
+		var variableErr VariableError
+		if errors.As(err, &variableErr) {
+			return nil, err
+		}
+		return nil, ExpressionError{Expression: expression, Reason: err.Error()}
+	}
+	if parser.peek().kind != tokenEOF {
+		return nil, ExpressionError{Expression: expression, Reason: fmt.Sprintf("unexpected %q", parser.peek().value)}
+	}
+	return value, nil
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *expressionParser) acceptOperator(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.value == operator {
+			p.pos++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *expressionParser) parseOr() (interface{}, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("||"); !ok {
+			return left, nil
+		}
+		known := isTruthy(left)
+		right, err := p.parseSide(known, p.parseAnd)
+		if err != nil {
+			return nil, err
+		}
+		left = known || isTruthy(right)
+	}
+}
+
+func (p *expressionParser) parseAnd() (interface{}, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("&&"); !ok {
+			return left, nil
+		}
+		known := !isTruthy(left)
+		right, err := p.parseSide(known, p.parseNot)
+		if err != nil {
+			return nil, err
+		}
+		left = !known && isTruthy(right)
+	}
+}
+
+// parseSide parses the right side of && or ||, skipping its evaluation when the result is known already
+func (p *expressionParser) parseSide(known bool, parse func() (interface{}, error)) (interface{}, error) {
+	if !known || p.skip {
+		return parse()
+	}
+	p.skip = true
+	defer func() { p.skip = false }()
+	return parse()
+}
+
+func (p *expressionParser) parseNot() (interface{}, error) {
+	if _, ok := p.acceptOperator("!"); ok {
+		value, err := p.parseNot()
+		if err != nil || p.skip {
+			return nil, err
+		}
+		return !isTruthy(value), nil
+	}
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (interface{}, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.acceptOperator("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parsePrimary()
+	if err != nil || p.skip {
+		return nil, err
+	}
+	return compareValues(operator, left, right)
+}
+
+func (p *expressionParser) parsePrimary() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+	case tokenNumber:
+		return strconv.ParseFloat(t.value, 64)
+	case tokenLParen:
+		value, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		return value, nil
+	case tokenPath:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if p.peek().kind == tokenLParen {
+			p.next()
+			return p.parseCall(t.value)
+		}
+		if p.skip {
+			return nil, nil
+		}
+		value, err := lookupVariable(t.value, p.data)
+		if err != nil {
+			return nil, err
+		}
+		return normalizeValue(value), nil
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q", t.value)
+	}
+}
+
+func (p *expressionParser) parseCall(name string) (interface{}, error) {
+	args := make([]interface{}, 0)
+	for p.peek().kind != tokenRParen {
+		var arg interface{}
+		var err error
+		// exists(path) checks for a missing value instead of failing on it
+		if name == "exists" && p.peek().kind == tokenPath {
+			path := p.next().value
+			if !p.skip {
+				_, err = lookupVariable(path, p.data)
+				arg = err == nil
+				err = nil
+			}
+		} else {
+			arg, err = p.parseOr()
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.peek().kind == tokenComma {
+			p.next()
+		} else if p.peek().kind != tokenRParen {
+			return nil, fmt.Errorf("expected , or ) in call to %s", name)
+		}
+	}
+	p.next()
+	if p.skip {
+		return nil, nil
+	}
+	return callFunction(name, args)
+}
+
+func callFunction(name string, args []interface{}) (interface{}, error) {
+	expectArgs := func(count int) error {
+		if len(args) != count {
+			return fmt.Errorf("%s expects %d arguments, got %d", name, count, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "exists":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		exists, ok := args[0].(bool)
+		if !ok {
+			return nil, fmt.Errorf("exists expects a path")
+		}
+		return exists, nil
+	case "contains":
+		if err := expectArgs(2); err != nil {
+			return nil, err
+		}
+		if list, ok := args[0].([]interface{}); ok {
+			for _, item := range list {
+				if equal, _ := compareValues("==", item, args[1]); equal == true {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toText(args[0]), toText(args[1])), nil
+	case "startsWith":
+		if err := expectArgs(2); err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(toText(args[0]), toText(args[1])), nil
+	case "endsWith":
+		if err := expectArgs(2); err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(toText(args[0]), toText(args[1])), nil
+	case "matches":
+		if err := expectArgs(2); err != nil {
+			return nil, err
+		}
+		pattern, err := regexp.Compile(toText(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex in matches: %v", err)
+		}
+		return pattern.MatchString(toText(args[0])), nil
+	case "lower":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		return strings.ToLower(toText(args[0])), nil
+	case "upper":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(toText(args[0])), nil
+	case "trim":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(toText(args[0])), nil
+	case "len":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		default:
+			return float64(len([]rune(toText(v)))), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+}
+
+// Numbers from the execution state are json.Number or float64, comparisons work on float64
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+func compareValues(operator string, left, right interface{}) (interface{}, error) {
+	left, right = normalizeValue(left), normalizeValue(right)
+
+	switch operator {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	}
+
+	if l, ok := left.(float64); ok {
+		if r, ok := right.(float64); ok {
+			switch operator {
+			case "<":
+				return l < r, nil
+			case "<=":
+				return l <= r, nil
+			case ">":
+				return l > r, nil
+			default:
+				return l >= r, nil
+			}
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			switch operator {
+			case "<":
+				return l < r, nil
+			case "<=":
+				return l <= r, nil
+			case ">":
+				return l > r, nil
+			default:
+				return l >= r, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot compare %s %s %s", jsonTypeName(left), operator, jsonTypeName(right))
+}
+
+func isTruthy(value interface{}) bool {
+	switch v := normalizeValue(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func toText(value interface{}) string {
+	text, err := stringifyValue(normalizeValue(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return text
+}
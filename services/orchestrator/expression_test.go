@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	data := map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"email_from":    "Ann@Customer.com",
+			"email_subject": "Please UNSUBSCRIBE me",
+			"labels":        []interface{}{"urgent", "inbox"},
+			"count":         json.Number("12"),
+			"empty":         "",
+			"nothing":       nil,
+		},
+		"node-1": map[string]interface{}{
+			"count": float64(3),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       interface{}
+	}{
+		{name: "literal", expression: `true`, want: true},
+		{name: "path", expression: `trigger.labels[0]`, want: "urgent"},
+		{name: "braced path", expression: `{{ node-1.count }} >= 3`, want: true},
+		{name: "json number", expression: `trigger.count > 10`, want: true},
+		{name: "string equality", expression: `trigger.labels[0] == "urgent"`, want: true},
+		{name: "single quotes", expression: `trigger.labels[1] == 'inbox'`, want: true},
+		{name: "string order", expression: `"a" < "b"`, want: true},
+		{name: "not equal", expression: `trigger.count != 12`, want: false},
+		{name: "null", expression: `trigger.nothing == null`, want: true},
+		{name: "and binds tighter than or", expression: `true || false && false`, want: true},
+		{name: "parentheses", expression: `(true || false) && false`, want: false},
+		{name: "not binds tighter than and", expression: `!false && true`, want: true},
+		{name: "double not", expression: `!!trigger.empty`, want: false},
+		{name: "comparison inside not", expression: `!(trigger.count < 5)`, want: true},
+		{name: "truthy operands", expression: `trigger.labels && trigger.email_from`, want: true},
+		{name: "functions", expression: `endsWith(lower(trigger.email_from), "@customer.com") && !contains(lower(trigger.email_subject), "unsubscribe")`, want: false},
+		{name: "contains array", expression: `contains(trigger.labels, "inbox")`, want: true},
+		{name: "matches", expression: `matches(trigger.email_from, "^[A-Z]")`, want: true},
+		{name: "len", expression: `len(trigger.labels) == 2 && len("héllo") == 5`, want: true},
+		{name: "exists", expression: `exists(trigger.labels)`, want: true},
+		{name: "exists missing", expression: `exists(trigger.subject)`, want: false},
+		{name: "exists guards and", expression: `exists(trigger.subject) && trigger.subject == "a"`, want: false},
+		{name: "exists guards or", expression: `!exists(trigger.subject) || trigger.subject == "a"`, want: true},
+		{name: "skipped call", expression: `false && len(trigger.subject) > 0`, want: false},
+		{name: "skipped nested", expression: `true || (trigger.a.b == 1 && matches(trigger.c, "x"))`, want: true},
+		{name: "evaluated after skip", expression: `false && trigger.subject || trigger.count == 12`, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := evaluateExpression(test.expression, data)
+			if err != nil {
+				t.Fatalf("evaluateExpression(%q) returned %v", test.expression, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("evaluateExpression(%q) = %#v, want %#v", test.expression, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	data := map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"name":  "Bob",
+			"count": float64(1),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		// A missing variable is a VariableError, anything else an ExpressionError
+		wantVariableErr bool
+	}{
+		{name: "missing variable", expression: `trigger.subject == "a"`, wantVariableErr: true},
+		{name: "missing variable evaluated by and", expression: `true && trigger.subject == "a"`, wantVariableErr: true},
+		{name: "missing variable evaluated by or", expression: `false || trigger.subject`, wantVariableErr: true},
+		{name: "syntax error in skipped side", expression: `false && (trigger.name ==`},
+		{name: "unclosed parenthesis in skipped side", expression: `true || (trigger.count`},
+		{name: "unterminated string", expression: `trigger.name == "Bob`},
+		{name: "unknown function", expression: `shout(trigger.name)`},
+		{name: "wrong argument count", expression: `lower(trigger.name, "x")`},
+		{name: "invalid comparison", expression: `trigger.name < 3`},
+		{name: "invalid regex", expression: `matches(trigger.name, "(")`},
+		{name: "trailing tokens", expression: `true false`},
+		{name: "empty", expression: ``},
+		{name: "unexpected character", expression: `trigger.name = "Bob"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := evaluateExpression(test.expression, data)
+			if err == nil {
+				t.Fatalf("evaluateExpression(%q) = %#v, want an error", test.expression, got)
+			}
+			var variableErr VariableError
+			var expressionErr ExpressionError
+			if test.wantVariableErr && !errors.As(err, &variableErr) {
+				t.Errorf("evaluateExpression(%q) returned %v, want a VariableError", test.expression, err)
+			}
+			if !test.wantVariableErr && !errors.As(err, &expressionErr) {
+				t.Errorf("evaluateExpression(%q) returned %v, want an ExpressionError", test.expression, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// NodeError is the failure of a node which stopped the execution
+type NodeError struct {
+	Node models.WorkflowNode
+	Err  error
+}
+
+func (err NodeError) Error() string {
+	return fmt.Sprintf("node %s failed: %v", err.Node.DisplayId, err.Err)
+}
+
+func (err NodeError) Unwrap() error {
+	return err.Err
+}
+
+// nodeFailure takes the place of the output of a failed node which has on_error edges.
+// Only those edges are followed, the recovery path reads the error through the node's display id:
+// {{send-email.error}}, {{send-email.error_class}}
+type nodeFailure struct {
+	Error      string
+	ErrorClass string
+}
+
+func (failure nodeFailure) data() map[string]interface{} {
+	return map[string]interface{}{
+		"error":       failure.Error,
+		"error_class": failure.ErrorClass,
+	}
+}
+
+// hasErrorHandler tells if a failure of the node is handled by an on_error edge instead of failing the execution
+func (graph *workflowGraph) hasErrorHandler(nodeId string) bool {
+	for _, edge := range graph.outgoing[nodeId] {
+		if edge.Label == models.ErrorEdgeLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// isFailureListener tells if the node is the core listener which fires when its workflow fails
+func isFailureListener(node *models.WorkflowNode) bool {
+	return node.Type == models.Listener && node.ServiceName == models.CoreServiceName && node.TaskName == models.WorkflowFailedTask
+}
+
+// triggerFailureHandlers queues an execution from every workflow-failed listener of the workflow.
+// The trigger payload holds the failed node, the error and the data of the failed execution:
+// {"execution_id": 12, "failed_node_id": "...", "failed_node": "send-email", "error": "...", "error_class": "auth", "context": {...}}
+func (orchestrator *OrchestratorService) triggerFailureHandlers(ctx context.Context, listenerNode *models.WorkflowNode, state *ExecutionContext, err error) {
+	// A failing failure handler does not trigger itself again
+	if isFailureListener(listenerNode) {
+		return
+	}
+
+	workflowNodeRepo := repositories.WorkflowNode{Db: orchestrator.Db}
+	nodes, findErr := workflowNodeRepo.FindByWorkflowId(listenerNode.WorkflowId)
+	if findErr != nil {
+		log.Printf("Failed to fetch failure handlers of workflow %d: %v", listenerNode.WorkflowId, findErr)
+		return
+	}
+
+	payload := map[string]interface{}{
+		"execution_id": state.ExecutionID,
+		"workflow_id":  state.WorkflowID,
+		"error":        err.Error(),
+		"error_class":  errorClassOf(err),
+		"context":      state.Snapshot(),
+	}
+	var nodeErr NodeError
+	if errors.As(err, &nodeErr) {
+		payload["failed_node_id"] = nodeErr.Node.Id
+		payload["failed_node"] = nodeErr.Node.DisplayId
+		payload["error"] = nodeErr.Err.Error()
+	}
+	encoded, encodeErr := json.Marshal(payload)
+	if encodeErr != nil {
+		log.Printf("Failed to encode failure of execution %d: %v", state.ExecutionID, encodeErr)
+		return
+	}
+
+	for _, node := range nodes {
+		if !isFailureListener(&node) {
+			continue
+		}
+
+		// The handler is queued even if the failed execution was cancelled
+		execution, createErr := orchestrator.CreateExecution(context.WithoutCancel(ctx), node.Id, string(encoded), state.DryRun)
+		if createErr != nil {
+			log.Printf("Failed to create failure handler execution for node %s: %v", node.Id, createErr)
+			continue
+		}
+		log.Printf("Execution %d failed, queued failure handler %s (execution %d)", state.ExecutionID, node.DisplayId, execution.Id)
+	}
+}
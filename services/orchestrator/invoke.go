@@ -0,0 +1,332 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// How often a parent waiting for a sub-workflow checks on it. The child queues its parent
+// when it finishes, this only covers a child which finished while the parent was still running.
+// Every poll queues the parent again, which starts its job attempts over, so a child can run for as long as it needs
+const invokePollInterval = 30 * time.Second
+
+// The core invoke-workflow action runs another workflow of the same user, starting at its workflow-called listener:
+//
+//	{"workflow_id": 12, "input": {"subject": "{{trigger.email_subject}}"}, "wait": true, "listener": "called"}
+//
+// input becomes the trigger payload of the child, listener picks the listener by display id when there are several.
+// With wait the parent pauses until the child finishes and its output is
+// {"execution_id": 34, "output": <the child's output>}, otherwise it continues at once with {"execution_id": 34}
+type invokeConfig struct {
+	WorkflowId int         `json:"workflow_id"`
+	Input      interface{} `json:"input"`
+	Wait       *bool       `json:"wait"`
+	Listener   string      `json:"listener"`
+}
+
+type invokeOutput struct {
+	ExecutionId int         `json:"execution_id"`
+	Output      interface{} `json:"output,omitempty"`
+}
+
+// waits tells if the parent pauses until the child finishes, which it does unless wait is false
+func (config invokeConfig) waits() bool {
+	return config.Wait == nil || *config.Wait
+}
+
+func isInvokeNode(node models.WorkflowNode) bool {
+	return node.Type == models.Action && node.ServiceName == models.CoreServiceName && node.TaskName == models.InvokeWorkflowTask
+}
+
+// invokeStore is what executeInvoke reads and writes, the db outside of tests
+type invokeStore interface {
+	InsertStep(step *models.ExecutionStep) error
+	SetStepOutput(step *models.ExecutionStep) error
+	FinishStep(step *models.ExecutionStep) error
+	FindExecution(id int) (*models.Execution, error)
+	// FindChild returns the execution started by the invoke step, nil when there is none
+	FindChild(stepId int) (*models.Execution, error)
+	StartChild(ctx context.Context, userId int, state *ExecutionContext, config invokeConfig, stepId int) (*models.Execution, error)
+}
+
+type dbInvokeStore struct {
+	orchestrator *OrchestratorService
+}
+
+func (store dbInvokeStore) InsertStep(step *models.ExecutionStep) error {
+	return (&repositories.ExecutionStep{Db: store.orchestrator.Db}).Insert(step)
+}
+
+func (store dbInvokeStore) SetStepOutput(step *models.ExecutionStep) error {
+	return (&repositories.ExecutionStep{Db: store.orchestrator.Db}).SetOutput(step)
+}
+
+func (store dbInvokeStore) FinishStep(step *models.ExecutionStep) error {
+	return (&repositories.ExecutionStep{Db: store.orchestrator.Db}).Finish(step)
+}
+
+func (store dbInvokeStore) FindExecution(id int) (*models.Execution, error) {
+	return (&repositories.Execution{Db: store.orchestrator.Db}).FindById(id)
+}
+
+func (store dbInvokeStore) FindChild(stepId int) (*models.Execution, error) {
+	child, err := (&repositories.Execution{Db: store.orchestrator.Db}).FindByParentStep(stepId)
+	var notFound errs.NotFoundError
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	return child, err
+}
+
+func (store dbInvokeStore) StartChild(ctx context.Context, userId int, state *ExecutionContext, config invokeConfig, stepId int) (*models.Execution, error) {
+	return store.orchestrator.startChildExecution(ctx, userId, state, config, stepId)
+}
+
+func (orchestrator *OrchestratorService) invokeStore() invokeStore {
+	if orchestrator.invokes != nil {
+		return orchestrator.invokes
+	}
+	return dbInvokeStore{orchestrator: orchestrator}
+}
+
+// parseInvokeConfig reads the resolved config of an invoke node
+func parseInvokeConfig(node models.WorkflowNode, resolvedConfig string, state *ExecutionContext) (invokeConfig, error) {
+	var config invokeConfig
+	if err := json.Unmarshal([]byte(resolvedConfig), &config); err != nil {
+		return config, fmt.Errorf("invalid config of node %s: %v", node.DisplayId, err)
+	}
+	// A loop iteration can't pause, so it fails before a child is started which nobody would wait for
+	if state.parent != nil && config.waits() {
+		return config, fmt.Errorf("invoke node %s inside a loop needs \"wait\": false", node.DisplayId)
+	}
+	return config, nil
+}
+
+// executeInvoke starts the child execution the first time the node is reached, previous is its waiting step afterwards.
+// The step is stored before the child is started and the child records the step, so a parent which
+// is resumed after a crash finds its child instead of starting another one
+func (orchestrator *OrchestratorService) executeInvoke(ctx context.Context, node models.WorkflowNode, userId int, state *ExecutionContext, previous *models.ExecutionStep) (interface{}, error) {
+	store := orchestrator.invokeStore()
+
+	var step *models.ExecutionStep
+	var output invokeOutput
+	if previous != nil && previous.Status == models.Waiting {
+		step = previous
+		if step.Output != "" {
+			if err := json.Unmarshal([]byte(step.Output), &output); err != nil {
+				return nil, fmt.Errorf("invalid waiting step of node %s: %v", node.DisplayId, err)
+			}
+		}
+	} else {
+		step = &models.ExecutionStep{
+			ExecutionId: state.ExecutionID,
+			NodeId:      node.Id,
+			DisplayId:   node.DisplayId,
+			Status:      models.Waiting,
+		}
+
+		resolvedConfig, err := resolveVariables(node.Config, state.Snapshot())
+		if err != nil {
+			return nil, failInvokeStep(store, step, fmt.Errorf("variable resolution failed for node %s: %w", node.DisplayId, err))
+		}
+		step.Input = resolvedConfig
+		if _, err := parseInvokeConfig(node, step.Input, state); err != nil {
+			return nil, failInvokeStep(store, step, err)
+		}
+		if err := store.InsertStep(step); err != nil {
+			return nil, fmt.Errorf("failed to store step: %v", err)
+		}
+	}
+
+	config, err := parseInvokeConfig(node, step.Input, state)
+	if err != nil {
+		return nil, failInvokeStep(store, step, err)
+	}
+
+	if output.ExecutionId == 0 {
+		child, err := store.FindChild(step.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look for the child of node %s: %v", node.DisplayId, err)
+		}
+		if child == nil {
+			child, err = store.StartChild(ctx, userId, state, config, step.Id)
+			if err != nil {
+				return nil, failInvokeStep(store, step, fmt.Errorf("node %s: %w", node.DisplayId, err))
+			}
+		}
+		output.ExecutionId = child.Id
+
+		encoded, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		step.Output = string(encoded)
+		if err := store.SetStepOutput(step); err != nil {
+			return nil, fmt.Errorf("failed to store step: %v", err)
+		}
+	}
+
+	// Fire and forget
+	if !config.waits() {
+		step.Status = models.Succeeded
+		if err := store.FinishStep(step); err != nil {
+			return nil, fmt.Errorf("failed to store step: %v", err)
+		}
+		return orchestrator.setInvokeOutput(node, state, output)
+	}
+
+	child, err := store.FindExecution(output.ExecutionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch child execution %d: %v", output.ExecutionId, err)
+	}
+
+	switch child.Status {
+	case models.Succeeded:
+		if child.Output != "" {
+			if err := json.Unmarshal([]byte(child.Output), &output.Output); err != nil {
+				return nil, fmt.Errorf("invalid output of child execution %d: %v", child.Id, err)
+			}
+		}
+		encoded, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		step.Output = string(encoded)
+		step.Status = models.Succeeded
+		if err := store.FinishStep(step); err != nil {
+			return nil, fmt.Errorf("failed to store step: %v", err)
+		}
+		return orchestrator.setInvokeOutput(node, state, output)
+	case models.Failed, models.Cancelled, models.TimedOut:
+		err := fmt.Errorf("child execution %d %s: %s", child.Id, child.Status, child.Error)
+		step.Status = models.Failed
+		step.Error = err.Error()
+		step.ErrorClass = errorClassOf(err)
+		if finishErr := store.FinishStep(step); finishErr != nil {
+			log.Printf("Failed to store step %d: %v", step.Id, finishErr)
+		}
+		return nil, err
+	default:
+		return nil, WaitingError{Until: time.Now().UTC().Add(invokePollInterval)}
+	}
+}
+
+// failInvokeStep stores the step as failed, inserting it when it wasn't stored yet, and returns err
+func failInvokeStep(store invokeStore, step *models.ExecutionStep, err error) error {
+	step.Status = models.Failed
+	step.Error = err.Error()
+	step.ErrorClass = errorClassOf(err)
+	if step.Id == 0 {
+		if insertErr := store.InsertStep(step); insertErr != nil {
+			return fmt.Errorf("failed to store step: %v", insertErr)
+		}
+	}
+	if finishErr := store.FinishStep(step); finishErr != nil {
+		return fmt.Errorf("failed to store step: %v", finishErr)
+	}
+	return err
+}
+
+func (orchestrator *OrchestratorService) setInvokeOutput(node models.WorkflowNode, state *ExecutionContext, output invokeOutput) (interface{}, error) {
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	state.Set(node.DisplayId, data)
+	return data, nil
+}
+
+// startChildExecution queues an execution of the invoked workflow, linked to the current one and the invoke step
+func (orchestrator *OrchestratorService) startChildExecution(ctx context.Context, userId int, state *ExecutionContext, config invokeConfig, stepId int) (*models.Execution, error) {
+	workflowRepo := repositories.Workflow{Db: orchestrator.Db}
+	workflowNodeRepo := repositories.WorkflowNode{Db: orchestrator.Db}
+
+	if state.Depth+1 > models.MaxExecutionDepth {
+		return nil, fmt.Errorf("sub-workflows can be nested at most %d levels deep", models.MaxExecutionDepth)
+	}
+
+	// Other users' workflows are reported as missing
+	workflow, err := workflowRepo.FindById(config.WorkflowId)
+	if err != nil || workflow.UserId != userId {
+		return nil, fmt.Errorf("workflow %d not found", config.WorkflowId)
+	}
+
+	nodes, err := workflowNodeRepo.FindByWorkflowId(workflow.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nodes of workflow %d: %v", workflow.Id, err)
+	}
+	var listener *models.WorkflowNode
+	for _, node := range nodes {
+		if node.Type != models.Listener || node.ServiceName != models.CoreServiceName || node.TaskName != models.WorkflowCalledTask {
+			continue
+		}
+		if config.Listener == "" || config.Listener == node.DisplayId {
+			listener = &node
+			break
+		}
+	}
+	if listener == nil {
+		return nil, fmt.Errorf("workflow %d has no %s listener", workflow.Id, models.WorkflowCalledTask)
+	}
+
+	if config.Input == nil {
+		config.Input = map[string]interface{}{}
+	}
+	if _, ok := config.Input.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("input must be an object, got %s", jsonTypeName(config.Input))
+	}
+	payload, err := json.Marshal(config.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	parentId := state.ExecutionID
+	execution := &models.Execution{
+		WorkflowId:        workflow.Id,
+		ListenerNodeId:    listener.Id,
+		Status:            models.Pending,
+		TriggerPayload:    string(payload),
+		ParentExecutionId: &parentId,
+		ParentStepId:      &stepId,
+		Depth:             state.Depth + 1,
+		DryRun:            state.DryRun,
+	}
+	if err := orchestrator.queueExecution(ctx, execution); err != nil {
+		return nil, err
+	}
+	log.Printf("Execution %d invoked workflow %d (execution %d)", state.ExecutionID, workflow.Id, execution.Id)
+	return execution, nil
+}
+
+// notifyParent queues the parent of a finished sub-workflow execution if it is waiting for it
+func (orchestrator *OrchestratorService) notifyParent(ctx context.Context, execution *models.Execution) {
+	if execution.ParentExecutionId == nil {
+		return
+	}
+
+	executionRepo := repositories.Execution{Db: orchestrator.Db}
+	parent, err := executionRepo.FindById(*execution.ParentExecutionId)
+	if err != nil {
+		log.Printf("Failed to fetch parent of execution %d: %v", execution.Id, err)
+		return
+	}
+	// A parent which is still running picks the result up when it polls again
+	if parent.Status != models.Waiting {
+		return
+	}
+	if err := orchestrator.Queue.Enqueue(context.WithoutCancel(ctx), parent.Id, time.Now()); err != nil {
+		log.Printf("Failed to queue parent execution %d: %v", parent.Id, err)
+	}
+}
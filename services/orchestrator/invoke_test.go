@@ -0,0 +1,311 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// A parent waiting for a child polls it every invokePollInterval, each poll is a lease of its job
+// which ends with the job queued again. A child running much longer than maxJobAttempts polls
+// must not get the parent abandoned
+func TestInvokePollingLongRunningChild(t *testing.T) {
+	ctx := context.Background()
+	queue := NewMemoryQueue()
+	const parentId = 12
+	queue.Enqueue(ctx, parentId, time.Now())
+
+	childRunsFor := time.Hour
+	polls := int(childRunsFor / invokePollInterval)
+	if polls <= maxJobAttempts {
+		t.Fatalf("%d polls don't exceed maxJobAttempts", polls)
+	}
+
+	for poll := 1; poll <= polls; poll++ {
+		job, err := queue.Lease(ctx, "worker", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil {
+			t.Fatalf("poll %d: the parent's job is not due", poll)
+		}
+		if job.Attempts > maxJobAttempts {
+			t.Fatalf("poll %d: the parent would be abandoned after %d attempts", poll, job.Attempts-1)
+		}
+
+		// What ExecuteWorkflow does with the WaitingError of executeInvoke, then the worker completes the job
+		queue.Enqueue(ctx, parentId, time.Now().Add(invokePollInterval))
+		queue.Complete(ctx, job)
+		// The poll interval passes
+		queue.jobs[parentId].runAt = time.Now()
+	}
+}
+
+// fakeInvokeStore keeps the steps and child executions of executeInvoke in memory
+type fakeInvokeStore struct {
+	steps      map[int]models.ExecutionStep
+	executions map[int]*models.Execution
+	startErr   error
+}
+
+func newFakeInvokeStore() *fakeInvokeStore {
+	return &fakeInvokeStore{steps: map[int]models.ExecutionStep{}, executions: map[int]*models.Execution{}}
+}
+
+func (store *fakeInvokeStore) InsertStep(step *models.ExecutionStep) error {
+	step.Id = len(store.steps) + 1
+	store.steps[step.Id] = *step
+	return nil
+}
+
+func (store *fakeInvokeStore) SetStepOutput(step *models.ExecutionStep) error {
+	stored := store.steps[step.Id]
+	stored.Output = step.Output
+	store.steps[step.Id] = stored
+	return nil
+}
+
+func (store *fakeInvokeStore) FinishStep(step *models.ExecutionStep) error {
+	store.steps[step.Id] = *step
+	return nil
+}
+
+func (store *fakeInvokeStore) FindExecution(id int) (*models.Execution, error) {
+	execution, ok := store.executions[id]
+	if !ok {
+		return nil, errors.New("execution not found")
+	}
+	return execution, nil
+}
+
+func (store *fakeInvokeStore) FindChild(stepId int) (*models.Execution, error) {
+	for _, execution := range store.executions {
+		if execution.ParentStepId != nil && *execution.ParentStepId == stepId {
+			return execution, nil
+		}
+	}
+	return nil, nil
+}
+
+func (store *fakeInvokeStore) StartChild(ctx context.Context, userId int, state *ExecutionContext, config invokeConfig, stepId int) (*models.Execution, error) {
+	if store.startErr != nil {
+		return nil, store.startErr
+	}
+	payload, _ := json.Marshal(config.Input)
+	child := &models.Execution{
+		Id:                100 + len(store.executions),
+		WorkflowId:        config.WorkflowId,
+		Status:            models.Pending,
+		TriggerPayload:    string(payload),
+		ParentExecutionId: &state.ExecutionID,
+		ParentStepId:      &stepId,
+	}
+	store.executions[child.Id] = child
+	return child, nil
+}
+
+func invokeNode(config string) models.WorkflowNode {
+	return models.WorkflowNode{
+		Id:          "invoke-id",
+		DisplayId:   "node-2",
+		Type:        models.Action,
+		ServiceName: models.CoreServiceName,
+		TaskName:    models.InvokeWorkflowTask,
+		Config:      config,
+	}
+}
+
+func TestExecuteInvoke(t *testing.T) {
+	const waitingConfig = `{"workflow_id": 12, "input": {"subject": "{{trigger.subject}}"}}`
+
+	tests := []struct {
+		name        string
+		config      string
+		childStatus models.ExecutionStatus
+		childOutput string
+		childError  string
+		wantOutput  interface{}
+		wantErr     string
+		wantStatus  models.ExecutionStatus
+	}{
+		{
+			name:        "child succeeded",
+			config:      waitingConfig,
+			childStatus: models.Succeeded,
+			childOutput: `{"sum": 3}`,
+			wantOutput:  map[string]interface{}{"execution_id": float64(100), "output": map[string]interface{}{"sum": float64(3)}},
+			wantStatus:  models.Succeeded,
+		},
+		{
+			name:        "child failed",
+			config:      waitingConfig,
+			childStatus: models.Failed,
+			childError:  "boom",
+			wantErr:     "child execution 100 failed: boom",
+			wantStatus:  models.Failed,
+		},
+		{
+			name:        "child cancelled",
+			config:      `{"workflow_id": 12, "input": {"subject": "{{trigger.subject}}"}, "wait": true}`,
+			childStatus: models.Cancelled,
+			childError:  "execution cancelled",
+			wantErr:     "child execution 100 cancelled: execution cancelled",
+			wantStatus:  models.Failed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newFakeInvokeStore()
+			orchestrator := &OrchestratorService{invokes: store}
+			node := invokeNode(test.config)
+			state := NewExecutionContext(1, 10, map[string]interface{}{"subject": "Hi"})
+
+			// Reached the first time: the child is queued and the parent parks
+			_, err := orchestrator.executeInvoke(ctx, node, 7, state, nil)
+			var waitErr WaitingError
+			if !errors.As(err, &waitErr) {
+				t.Fatalf("first run returned %v, want a WaitingError", err)
+			}
+			if len(store.executions) != 1 {
+				t.Fatalf("%d children started, want 1", len(store.executions))
+			}
+			child := store.executions[100]
+			if child.TriggerPayload != `{"subject":"Hi"}` || *child.ParentStepId != 1 {
+				t.Errorf("child = %+v, want the resolved input and the step", child)
+			}
+			step := store.steps[1]
+			if step.Status != models.Waiting || step.Output != `{"execution_id":100}` {
+				t.Fatalf("step = %+v, want waiting for execution 100", step)
+			}
+
+			// Polled while the child is still running
+			child.Status = models.Running
+			previous := store.steps[1]
+			if _, err := orchestrator.executeInvoke(ctx, node, 7, state, &previous); !errors.As(err, &waitErr) {
+				t.Fatalf("poll returned %v, want a WaitingError", err)
+			}
+
+			// Resumed after the child finished
+			child.Status = test.childStatus
+			child.Output = test.childOutput
+			child.Error = test.childError
+			previous = store.steps[1]
+			output, err := orchestrator.executeInvoke(ctx, node, 7, state, &previous)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("resume returned %v, want %q", err, test.wantErr)
+				}
+				if store.steps[1].ErrorClass == "" {
+					t.Error("the failed step has no error class")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(output, test.wantOutput) {
+					t.Errorf("output = %#v, want %#v", output, test.wantOutput)
+				}
+				if got := state.Snapshot()[node.DisplayId]; !reflect.DeepEqual(got, test.wantOutput) {
+					t.Errorf("state of %s = %#v, want %#v", node.DisplayId, got, test.wantOutput)
+				}
+			}
+			if store.steps[1].Status != test.wantStatus {
+				t.Errorf("step status = %v, want %v", store.steps[1].Status, test.wantStatus)
+			}
+			if len(store.executions) != 1 || len(store.steps) != 1 {
+				t.Errorf("%d children and %d steps, want 1 of each", len(store.executions), len(store.steps))
+			}
+		})
+	}
+}
+
+func TestExecuteInvokeWithoutWaiting(t *testing.T) {
+	store := newFakeInvokeStore()
+	orchestrator := &OrchestratorService{invokes: store}
+	node := invokeNode(`{"workflow_id": 12, "wait": false}`)
+	state := NewExecutionContext(1, 10, map[string]interface{}{})
+
+	output, err := orchestrator.executeInvoke(context.Background(), node, 7, state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"execution_id": float64(100)}; !reflect.DeepEqual(output, want) {
+		t.Errorf("output = %#v, want %#v", output, want)
+	}
+	if len(store.executions) != 1 {
+		t.Errorf("%d children started, want 1", len(store.executions))
+	}
+	if step := store.steps[1]; step.Status != models.Succeeded {
+		t.Errorf("step status = %v, want succeeded", step.Status)
+	}
+}
+
+// A parent which stopped after starting its child, before the step recorded the child, must not start another one
+func TestExecuteInvokeResumedBeforeStepOutput(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeInvokeStore()
+	orchestrator := &OrchestratorService{invokes: store}
+	node := invokeNode(`{"workflow_id": 12}`)
+	state := NewExecutionContext(1, 10, map[string]interface{}{})
+
+	step := &models.ExecutionStep{ExecutionId: 10, NodeId: node.Id, DisplayId: node.DisplayId, Status: models.Waiting, Input: `{"workflow_id":12}`}
+	store.InsertStep(step)
+	child, _ := store.StartChild(ctx, 7, state, invokeConfig{WorkflowId: 12}, step.Id)
+	child.Status = models.Succeeded
+
+	previous := store.steps[step.Id]
+	if _, err := orchestrator.executeInvoke(ctx, node, 7, state, &previous); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.executions) != 1 {
+		t.Errorf("%d children started, want the existing one only", len(store.executions))
+	}
+	if got := store.steps[step.Id]; got.Status != models.Succeeded || got.Output != `{"execution_id":100}` {
+		t.Errorf("step = %+v, want succeeded with execution 100", got)
+	}
+}
+
+func TestExecuteInvokeFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		inLoop   bool
+		startErr error
+		wantErr  string
+	}{
+		{name: "child can't start", config: `{"workflow_id": 99}`, startErr: errors.New("workflow 99 not found"), wantErr: "node node-2: workflow 99 not found"},
+		{name: "missing variable", config: `{"workflow_id": 12, "input": {"a": "{{trigger.missing}}"}}`, wantErr: "variable resolution failed"},
+		{name: "waiting inside a loop", config: `{"workflow_id": 12}`, inLoop: true, wantErr: `needs "wait": false`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeInvokeStore()
+			store.startErr = test.startErr
+			orchestrator := &OrchestratorService{invokes: store}
+			state := NewExecutionContext(1, 10, map[string]interface{}{})
+			if test.inLoop {
+				state = state.NewScope(map[string]interface{}{"item": 1, "index": 0})
+			}
+
+			_, err := orchestrator.executeInvoke(context.Background(), invokeNode(test.config), 7, state, nil)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("executeInvoke() returned %v, want %q", err, test.wantErr)
+			}
+			if len(store.executions) != 0 {
+				t.Errorf("%d children started, want none", len(store.executions))
+			}
+			if step := store.steps[1]; step.Status != models.Failed || step.Error == "" {
+				t.Errorf("step = %+v, want it stored as failed", step)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// executeJoin combines the outputs of the upstream nodes which reached the join node
+func (orchestrator *OrchestratorService) executeJoin(node models.WorkflowNode, state *ExecutionContext, upstream []models.WorkflowNode, step *models.ExecutionStep) (string, error) {
+	config, err := models.ParseJoinConfig(node.Config)
+	if err != nil {
+		return "", fmt.Errorf("invalid config of join node %s: %v", node.DisplayId, err)
+	}
+	step.Input = node.Config
+
+	data := state.Snapshot()
+	var combined interface{}
+	if config.Output == models.JoinArray {
+		outputs := make([]interface{}, 0, len(upstream))
+		for _, upstreamNode := range upstream {
+			outputs = append(outputs, data[upstreamNode.DisplayId])
+		}
+		combined = outputs
+	} else {
+		outputs := make(map[string]interface{}, len(upstream))
+		for _, upstreamNode := range upstream {
+			outputs[upstreamNode.DisplayId] = data[upstreamNode.DisplayId]
+		}
+		combined = outputs
+	}
+
+	encoded, err := json.Marshal(combined)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
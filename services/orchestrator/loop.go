@@ -0,0 +1,188 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// Loop nodes run their body once per item of an array: {"items": "{{trigger.emails}}", "max_iterations": 100, "concurrency": 1}
+// The body is everything reachable through the loop's body edges. Every iteration has its own scope,
+// so the body reads {{item}} and {{index}} and its outputs don't mix with the other iterations.
+// The loop's output is {"results": [...], "count": n} with one result per item: the output of the last
+// node of the body, or {"<display id>": output, ...} when the body ends in several nodes
+type loopOutput struct {
+	Results []interface{} `json:"results"`
+	Count   int           `json:"count"`
+}
+
+func (orchestrator *OrchestratorService) executeLoop(ctx context.Context, graph *workflowGraph, node models.WorkflowNode, userId int, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	resolvedConfig, err := resolveVariables(node.Config, state.Snapshot())
+	if err != nil {
+		return "", fmt.Errorf("variable resolution failed for node %s: %w", node.DisplayId, err)
+	}
+	step.Input = resolvedConfig
+
+	config, err := models.ParseLoopConfig(resolvedConfig)
+	if err != nil {
+		return "", fmt.Errorf("invalid config of loop node %s: %v", node.DisplayId, err)
+	}
+	items, ok := config.Items.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("loop node %s: items must be an array, got %s", node.DisplayId, jsonTypeName(config.Items))
+	}
+	if len(items) > config.MaxIterations {
+		return "", fmt.Errorf("loop node %s: %d items exceed max_iterations (%d)", node.DisplayId, len(items), config.MaxIterations)
+	}
+
+	body := graph.bodyGraph(node)
+	sinks := body.sinks()
+
+	results := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+
+	// Iterations are started in order, at most config.Concurrency at a time
+	semaphore := make(chan struct{}, config.Concurrency)
+	var wg sync.WaitGroup
+	for index, item := range items {
+		if ctx.Err() != nil {
+			errs[index] = ctx.Err()
+			break
+		}
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			scope := state.NewScope(map[string]interface{}{"item": item, "index": index})
+			if err := orchestrator.runGraph(ctx, body, &node, userId, scope, nil); err != nil {
+				var waitErr WaitingError
+				if errors.As(err, &waitErr) {
+					err = fmt.Errorf("wait nodes can't be used inside loops")
+				}
+				errs[index] = fmt.Errorf("iteration %d: %w", index, err)
+				return
+			}
+			results[index] = graphResult(scope, sinks)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return "", fmt.Errorf("loop node %s failed: %w", node.DisplayId, err)
+	}
+
+	encoded, err := json.Marshal(loopOutput{Results: results, Count: len(results)})
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// graphResult is what a graph returns: the output of its last node, or of every last node by display id
+func graphResult(state *ExecutionContext, sinks []models.WorkflowNode) interface{} {
+	data := state.Snapshot()
+	if len(sinks) == 1 {
+		return data[sinks[0].DisplayId]
+	}
+	result := make(map[string]interface{}, len(sinks))
+	for _, sink := range sinks {
+		if output, ok := data[sink.DisplayId]; ok {
+			result[sink.DisplayId] = output
+		}
+	}
+	return result
+}
+
+// loopBody returns the ids of the nodes which can be reached through the body edges of the loop
+func (graph *workflowGraph) loopBody(loopId string) map[string]bool {
+	body := make(map[string]bool)
+	stack := make([]string, 0)
+	for _, edge := range graph.outgoing[loopId] {
+		if edge.Label == models.LoopBodyLabel && !body[edge.NodeTo] {
+			body[edge.NodeTo] = true
+			stack = append(stack, edge.NodeTo)
+		}
+	}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, edge := range graph.outgoing[current] {
+			if !body[edge.NodeTo] {
+				body[edge.NodeTo] = true
+				stack = append(stack, edge.NodeTo)
+			}
+		}
+	}
+	return body
+}
+
+// withoutLoopBodies drops the nodes inside the bodies of the loops among nodes, the loops run them
+func (graph *workflowGraph) withoutLoopBodies(nodes []models.WorkflowNode) []models.WorkflowNode {
+	inBody := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Type == models.Loop {
+			for nodeId := range graph.loopBody(node.Id) {
+				inBody[nodeId] = true
+			}
+		}
+	}
+
+	filtered := make([]models.WorkflowNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !inBody[node.Id] {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// bodyGraph is the graph which runs once per item of the loop, rooted at the loop node.
+// Loops nested in the body run their own bodies
+func (graph *workflowGraph) bodyGraph(loop models.WorkflowNode) *workflowGraph {
+	body := graph.loopBody(loop.Id)
+	sorted := make([]models.WorkflowNode, 0, len(body))
+	for _, node := range graph.sorted {
+		if body[node.Id] {
+			sorted = append(sorted, node)
+		}
+	}
+
+	return &workflowGraph{
+		nodes:    graph.nodes,
+		incoming: graph.incoming,
+		outgoing: graph.outgoing,
+		root:     loop.Id,
+		sorted:   sorted,
+		order:    graph.withoutLoopBodies(sorted),
+	}
+}
+
+// sinks returns the nodes of the graph which have no outgoing edges to other nodes of the graph
+func (graph *workflowGraph) sinks() []models.WorkflowNode {
+	inGraph := make(map[string]bool, len(graph.order))
+	for _, node := range graph.order {
+		inGraph[node.Id] = true
+	}
+
+	sinks := make([]models.WorkflowNode, 0)
+	for _, node := range graph.order {
+		isSink := true
+		for _, edge := range graph.outgoing[node.Id] {
+			if inGraph[edge.NodeTo] {
+				isSink = false
+				break
+			}
+		}
+		if isSink {
+			sinks = append(sinks, node)
+		}
+	}
+	return sinks
+}
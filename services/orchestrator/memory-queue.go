@@ -0,0 +1,106 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryJob struct {
+	Job
+	runAt          time.Time
+	leaseExpiresAt time.Time
+}
+
+// MemoryQueue keeps the jobs in the orchestrator process. Nothing survives a restart,
+// the worker queues the unfinished executions again when it starts
+type MemoryQueue struct {
+	mu     sync.Mutex
+	jobs   map[int]*memoryJob // execution id -> job
+	nextId int
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{jobs: make(map[int]*memoryJob)}
+}
+
+func (queue *MemoryQueue) Enqueue(ctx context.Context, executionId int, runAt time.Time) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if job, ok := queue.jobs[executionId]; ok {
+		job.runAt = runAt
+		job.Attempts = 0
+		job.LeaseToken = ""
+		job.leaseExpiresAt = time.Time{}
+		return nil
+	}
+	queue.add(executionId, runAt)
+	return nil
+}
+
+func (queue *MemoryQueue) EnqueueIfMissing(ctx context.Context, executionId int) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if _, ok := queue.jobs[executionId]; !ok {
+		queue.add(executionId, time.Now())
+	}
+	return nil
+}
+
+func (queue *MemoryQueue) add(executionId int, runAt time.Time) {
+	queue.nextId++
+	queue.jobs[executionId] = &memoryJob{
+		Job:   Job{Id: queue.nextId, ExecutionId: executionId},
+		runAt: runAt,
+	}
+}
+
+func (queue *MemoryQueue) Lease(ctx context.Context, workerId string, leaseFor time.Duration) (*Job, error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	var next *memoryJob
+	for _, job := range queue.jobs {
+		if job.runAt.After(now) || (job.LeaseToken != "" && job.leaseExpiresAt.After(now)) {
+			continue
+		}
+		// Oldest due job first
+		if next == nil || job.runAt.Before(next.runAt) || (job.runAt.Equal(next.runAt) && job.Id < next.Id) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Attempts++
+	next.LeaseToken = newLeaseToken()
+	next.leaseExpiresAt = now.Add(leaseFor)
+	leased := next.Job
+	return &leased, nil
+}
+
+func (queue *MemoryQueue) Heartbeat(ctx context.Context, job *Job, leaseFor time.Duration) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queued, ok := queue.jobs[job.ExecutionId]
+	if !ok || queued.LeaseToken != job.LeaseToken {
+		return ErrLeaseLost
+	}
+	queued.leaseExpiresAt = time.Now().Add(leaseFor)
+	return nil
+}
+
+func (queue *MemoryQueue) Complete(ctx context.Context, job *Job) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queued, ok := queue.jobs[job.ExecutionId]; ok && queued.LeaseToken == job.LeaseToken {
+		delete(queue.jobs, job.ExecutionId)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryQueueAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// Run between two leases of the job
+		between      func(queue *MemoryQueue, job *Job)
+		leases       int
+		wantAttempts int
+	}{
+		{
+			name: "paused and queued again",
+			between: func(queue *MemoryQueue, job *Job) {
+				queue.Enqueue(ctx, job.ExecutionId, time.Now())
+				queue.Complete(ctx, job)
+			},
+			leases:       2 * maxJobAttempts,
+			wantAttempts: 1,
+		},
+		{
+			name:         "lease expired",
+			between:      func(queue *MemoryQueue, job *Job) {},
+			leases:       maxJobAttempts + 1,
+			wantAttempts: maxJobAttempts + 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := NewMemoryQueue()
+			if err := queue.Enqueue(ctx, 7, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			var job *Job
+			for i := 0; i < test.leases; i++ {
+				if job != nil {
+					test.between(queue, job)
+				}
+				// Leases which are expired at once, as if the worker crashed
+				leased, err := queue.Lease(ctx, "worker", -time.Millisecond)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if leased == nil {
+					t.Fatalf("lease %d: no job", i+1)
+				}
+				job = leased
+			}
+			if job.Attempts != test.wantAttempts {
+				t.Errorf("Attempts = %d, want %d", job.Attempts, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestMemoryQueueReschedule(t *testing.T) {
+	ctx := context.Background()
+	queue := NewMemoryQueue()
+	queue.Enqueue(ctx, 7, time.Now())
+
+	job, _ := queue.Lease(ctx, "worker", time.Minute)
+	if job == nil {
+		t.Fatal("no job")
+	}
+	if again, _ := queue.Lease(ctx, "worker", time.Minute); again != nil {
+		t.Fatal("a leased job was leased again")
+	}
+
+	// Paused until later, the lease is gone and completing the old lease keeps the job
+	queue.Enqueue(ctx, 7, time.Now().Add(time.Hour))
+	if err := queue.Heartbeat(ctx, job, time.Minute); err != ErrLeaseLost {
+		t.Errorf("Heartbeat = %v, want ErrLeaseLost", err)
+	}
+	queue.Complete(ctx, job)
+	if _, ok := queue.jobs[7]; !ok {
+		t.Fatal("the rescheduled job was completed")
+	}
+	if due, _ := queue.Lease(ctx, "worker", time.Minute); due != nil {
+		t.Error("a job scheduled for later was leased")
+	}
+}
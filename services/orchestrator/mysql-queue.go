@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MySQLQueue keeps the jobs in the execution_jobs table, so they survive restarts and
+// several orchestrator processes can share them. Leasing locks the candidate row with
+// SELECT ... FOR UPDATE SKIP LOCKED, so two workers never get the same job
+type MySQLQueue struct {
+	Db *sql.DB
+}
+
+func (queue *MySQLQueue) Enqueue(ctx context.Context, executionId int, runAt time.Time) error {
+	_, err := queue.Db.ExecContext(ctx, `INSERT INTO execution_jobs(execution_id, run_at) VALUES (?, ?)
+	ON DUPLICATE KEY UPDATE run_at = VALUES(run_at), attempts = 0, lease_token = NULL, leased_by = NULL, lease_expires_at = NULL`,
+		executionId, runAt.UTC())
+	return err
+}
+
+func (queue *MySQLQueue) EnqueueIfMissing(ctx context.Context, executionId int) error {
+	_, err := queue.Db.ExecContext(ctx, "INSERT IGNORE INTO execution_jobs(execution_id, run_at) VALUES (?, ?)", executionId, time.Now().UTC())
+	return err
+}
+
+func (queue *MySQLQueue) Lease(ctx context.Context, workerId string, leaseFor time.Duration) (*Job, error) {
+	tx, err := queue.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	job := &Job{}
+	err = tx.QueryRowContext(ctx, `SELECT id, execution_id, attempts FROM execution_jobs
+	WHERE run_at <= ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+	ORDER BY run_at ASC, id ASC
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED`, now, now).Scan(&job.Id, &job.ExecutionId, &job.Attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.Attempts++
+	job.LeaseToken = newLeaseToken()
+	_, err = tx.ExecContext(ctx,
+		"UPDATE execution_jobs SET attempts = ?, lease_token = ?, leased_by = ?, lease_expires_at = ? WHERE id = ?",
+		job.Attempts, job.LeaseToken, workerId, now.Add(leaseFor), job.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (queue *MySQLQueue) Heartbeat(ctx context.Context, job *Job, leaseFor time.Duration) error {
+	res, err := queue.Db.ExecContext(ctx,
+		"UPDATE execution_jobs SET lease_expires_at = ? WHERE id = ? AND lease_token = ?",
+		time.Now().UTC().Add(leaseFor), job.Id, job.LeaseToken,
+	)
+	if err != nil {
+		return err
+	}
+	// MySQL reports 0 affected rows when the values did not change, which can't happen
+	// here because the expiry moves forward on every heartbeat
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (queue *MySQLQueue) Complete(ctx context.Context, job *Job) error {
+	_, err := queue.Db.ExecContext(ctx, "DELETE FROM execution_jobs WHERE id = ? AND lease_token = ?", job.Id, job.LeaseToken)
+	return err
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
@@ -14,101 +16,297 @@ import (
 )
 
 type OrchestratorService struct {
-	Db           *sql.DB
-	GmailService pb.TaskWorkerClient
+	Db          *sql.DB
+	// Finds the worker which runs the tasks of an action's service
+	Workers     *WorkerRegistry
 	UserService pb.UserServiceClient
-	// service -> grpc address
-	// Registry     map[string]string
+	// How many nodes of one execution can run at the same time, defaults to defaultMaxParallelNodes
+	MaxParallelNodes int
+	// Executions waiting for a Worker
+	Queue Queue
+	// Replaces the db for invoke nodes in tests
+	invokes invokeStore
 }
 
-type ExecutionContext struct {
-	WorkflowID   int
-	// The "Bag of State"
-	// TODO: Store this in DB
-	CurrentData  map[string]interface{}
+// CreateExecution stores a pending execution for the workflow of the listener node and queues it.
+// A Worker then runs it with ExecuteWorkflow. A dry run records its actions instead of running them
+func (orchestrator *OrchestratorService) CreateExecution(ctx context.Context, listenerNodeId string, initialPayload string, dryRun bool) (*models.Execution, error) {
+	workflowNodeRepo := repositories.WorkflowNode{ Db: orchestrator.Db }
+
+	listenerNode, err := workflowNodeRepo.FindById(listenerNodeId)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid trigger node")
+	}
+
+	if !json.Valid([]byte(initialPayload)) {
+		return nil, fmt.Errorf("initial payload is not valid JSON")
+	}
+
+	execution := &models.Execution{
+		WorkflowId:     listenerNode.WorkflowId,
+		ListenerNodeId: listenerNode.Id,
+		Status:         models.Pending,
+		TriggerPayload: initialPayload,
+		DryRun:         dryRun,
+	}
+	if err := orchestrator.queueExecution(ctx, execution); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+func (orchestrator *OrchestratorService) queueExecution(ctx context.Context, execution *models.Execution) error {
+	executionRepo := repositories.Execution{ Db: orchestrator.Db }
+	if err := executionRepo.Insert(execution); err != nil {
+		return fmt.Errorf("failed to store execution: %v", err)
+	}
+	// If this fails the execution stays pending and is queued again when a worker starts
+	if err := orchestrator.Queue.Enqueue(ctx, execution.Id, time.Now()); err != nil {
+		return fmt.Errorf("failed to queue execution: %v", err)
+	}
+	return nil
 }
 
-func (orchestrator *OrchestratorService) ExecuteWorkflow(ctx context.Context, listenerNodeId string, initialPayload string) error {
+func (orchestrator *OrchestratorService) ExecuteWorkflow(ctx context.Context, executionId int) error {
 	workflowNodeRepo := repositories.WorkflowNode{ Db: orchestrator.Db }
 	workflowRepo := repositories.Workflow{ Db: orchestrator.Db }
-	
-	
-	listenerNode, err := workflowNodeRepo.FindById(listenerNodeId)
+	executionRepo := repositories.Execution{ Db: orchestrator.Db }
+
+	execution, err := executionRepo.FindById(executionId)
+	if err != nil {
+		return err
+	}
+	if execution.Status.IsFinal() {
+		log.Printf("Execution %d already finished", executionId)
+		return nil
+	}
+
+	finish := func(status models.ExecutionStatus, err error) error {
+		if finishErr := executionRepo.Finish(executionId, status, err.Error()); finishErr != nil {
+			log.Printf("Failed to store status of execution %d: %v", executionId, finishErr)
+		}
+		orchestrator.notifyParent(ctx, execution)
+		return err
+	}
+	fail := func(err error) error {
+		return finish(models.Failed, err)
+	}
+
+	// Cancelled while a worker was already taking it off the queue
+	if execution.CancelRequested {
+		finish(models.Cancelled, ErrExecutionCancelled)
+		return nil
+	}
+
+	if err := executionRepo.MarkRunning(executionId); err != nil {
+		return err
+	}
+
+	listenerNode, err := workflowNodeRepo.FindById(execution.ListenerNodeId)
 	if err != nil {
-		return fmt.Errorf("Invalid trigger node");
+		return fail(fmt.Errorf("Invalid trigger node"))
 	}
 	workflowId := listenerNode.WorkflowId
-	workflow, _ := workflowRepo.FindById(workflowId)
+	workflow, err := workflowRepo.FindById(workflowId)
+	if err != nil {
+		return fail(err)
+	}
 
-	log.Printf("Starting Workflow %d", workflowId)
+	log.Printf("Starting Workflow %d (execution %d)", workflowId, executionId)
+
+	execCtx, stop := orchestrator.executionContext(ctx, execution, workflow)
+	defer stop()
 
 	var triggerData map[string]interface{}
-	if err := json.Unmarshal([]byte(initialPayload), &triggerData); err != nil {
-		return fmt.Errorf("failed to parse initial payload: %v", err)
+	if err := json.Unmarshal([]byte(execution.TriggerPayload), &triggerData); err != nil {
+		return fail(fmt.Errorf("failed to parse initial payload: %v", err))
 	}
 
-	state := &ExecutionContext{
-		WorkflowID:  workflowId,
-		CurrentData: map[string]interface{}{"trigger": triggerData},
+	state := NewExecutionContext(workflowId, executionId, triggerData)
+	state.Depth = execution.Depth
+	state.DryRun = execution.DryRun
+
+	graph, err := orchestrator.getWorkflowGraph(listenerNode)
+	if err != nil {
+		return fail(err)
 	}
 
-	nodes, err := orchestrator.getNodesInLinearOrder(listenerNode)
+	// An execution which was interrupted (e.g. by a restart) continues after the nodes which already finished
+	previous, err := orchestrator.previousSteps(executionId)
 	if err != nil {
+		return fail(fmt.Errorf("failed to load previous steps: %v", err))
+	}
+	if len(previous) > 0 {
+		log.Printf("Resuming execution %d", executionId)
+	}
+
+	err = orchestrator.runGraph(execCtx, graph, listenerNode, workflow.UserId, state, previous)
+	if status, ok := interruptedStatus(execCtx); ok && err != nil {
+		log.Printf("Execution %d stopped (%s): %v", executionId, status, err)
+		finish(status, context.Cause(execCtx))
+		if status == models.TimedOut {
+			orchestrator.triggerFailureHandlers(ctx, listenerNode, state, err)
+		}
+		return err
+	}
+	var waitErr WaitingError
+	if errors.As(err, &waitErr) {
+		resumeAt := waitErr.Until
+		// An execution which runs out of time while paused is resumed to be stopped as timed out
+		if deadline, ok := execCtx.Deadline(); ok && deadline.Before(resumeAt) {
+			resumeAt = deadline
+		}
+		// Nothing is kept in memory while waiting, the state is restored from the steps when the job runs again
+		if err := executionRepo.MarkWaiting(executionId); err != nil {
+			return fail(err)
+		}
+		if err := orchestrator.Queue.Enqueue(ctx, executionId, resumeAt); err != nil {
+			return fail(fmt.Errorf("failed to queue execution: %v", err))
+		}
+		log.Printf("Execution %d paused until %v", executionId, resumeAt)
+		return nil
+	}
+	if err != nil {
+		// The worker stopped or lost the job, the execution stays running and is resumed later
+		if ctx.Err() != nil {
+			return err
+		}
+		fail(err)
+		orchestrator.triggerFailureHandlers(ctx, listenerNode, state, err)
 		return err
 	}
-	
 
-	for _, node := range nodes {
-		log.Printf("Executing Node: %s (%s)", node.Id, node.Type)
-
-		var outputJSON string
-		var execErr error
-
-		switch node.Type.String() {
-		case "action":
-			outputJSON, execErr = orchestrator.executeAction(ctx, node, workflow.UserId, state)
-		// case "transformer":
-		// 	outputJSON, execErr = orchestrator.executeTransformer(ctx, node, state)
-		default:
-			log.Printf("Skipping unknown node type: %s", node.Type)
-			continue
+	// The output is what a parent which invoked this workflow receives
+	output, err := json.Marshal(graphResult(state, graph.sinks()))
+	if err != nil {
+		return fail(fmt.Errorf("failed to encode output: %v", err))
+	}
+	if err := executionRepo.SetOutput(executionId, string(output)); err != nil {
+		log.Printf("Failed to store output of execution %d: %v", executionId, err)
+	}
+	if err := executionRepo.Finish(executionId, models.Succeeded, ""); err != nil {
+		log.Printf("Failed to store status of execution %d: %v", executionId, err)
+	}
+	orchestrator.notifyParent(ctx, execution)
+	log.Printf("Workflow %d Completed Successfully", workflowId)
+	return nil
+}
+
+// executeStep runs a single node and records its input, output and status in execution_steps.
+// upstream holds the nodes through which the node was reached
+func (orchestrator *OrchestratorService) executeStep(ctx context.Context, graph *workflowGraph, node models.WorkflowNode, userId int, state *ExecutionContext, upstream []models.WorkflowNode) (interface{}, error) {
+	stepRepo := repositories.ExecutionStep{ Db: orchestrator.Db }
+
+	step := &models.ExecutionStep{
+		ExecutionId: state.ExecutionID,
+		NodeId:      node.Id,
+		DisplayId:   node.DisplayId,
+		Status:      models.Running,
+	}
+	if err := stepRepo.Insert(step); err != nil {
+		return nil, fmt.Errorf("failed to store step: %v", err)
+	}
+
+	finish := func(status models.ExecutionStatus, err error) error {
+		step.Status = status
+		if err != nil {
+			step.Error = err.Error()
+			// Only failures of the node itself, not a cancelled execution or a worker shutting down
+			if ctx.Err() == nil {
+				step.ErrorClass = errorClassOf(err)
+			}
+		}
+		if finishErr := stepRepo.Finish(step); finishErr != nil {
+			log.Printf("Failed to store step %d: %v", step.Id, finishErr)
 		}
+		return err
+	}
 
-		// Handle Failures
-		if execErr != nil {
-			log.Printf("Workflow Failed at Node %s: %v", node.Id, execErr)
-			return execErr 
+	var outputJSON string
+	var err error
+	switch node.Type {
+	case models.Action:
+		outputJSON, err = orchestrator.executeWithRetry(ctx, node, userId, state, step)
+	case models.Transformer:
+		outputJSON, err = orchestrator.executeTransformer(node, state, step)
+	case models.Condition:
+		outputJSON, err = orchestrator.executeCondition(node, state, step)
+	case models.Join:
+		outputJSON, err = orchestrator.executeJoin(node, state, upstream, step)
+	case models.Loop:
+		outputJSON, err = orchestrator.executeLoop(ctx, graph, node, userId, state, step)
+	}
+	if err != nil {
+		status := models.Failed
+		var timeoutErr NodeTimeoutError
+		if interrupted, ok := interruptedStatus(ctx); ok {
+			status = interrupted
+		} else if errors.As(err, &timeoutErr) {
+			status = models.TimedOut
 		}
+		return nil, finish(status, err)
+	}
 
-		// 4. Update State with Results
-		// So Step 2 can access {{ step_1.data }}
-		var outputMap map[string]interface{}
-		if outputJSON != "" {
-			if err := json.Unmarshal([]byte(outputJSON), &outputMap); err != nil {
-				return fmt.Errorf("failed to parse outputJSON: %v", err)
-			}
-			state.CurrentData[node.Id] = outputMap
+	// Update State with Results
+	// So Step 2 can access {{ step_1.data }} through the display id of step 1
+	var output interface{}
+	if outputJSON != "" {
+		if err := json.Unmarshal([]byte(outputJSON), &output); err != nil {
+			return nil, finish(models.Failed, fmt.Errorf("failed to parse outputJSON: %v", err))
 		}
+		step.Output = outputJSON
+		state.Set(node.DisplayId, output)
 	}
 
-	log.Printf("Workflow %d Completed Successfully", workflowId)
-	return nil
+	return output, finish(models.Succeeded, nil)
+}
+
+// skipStep records a node which did not run because its branch was not taken
+func (orchestrator *OrchestratorService) skipStep(node models.WorkflowNode, state *ExecutionContext) {
+	stepRepo := repositories.ExecutionStep{ Db: orchestrator.Db }
+
+	step := &models.ExecutionStep{
+		ExecutionId: state.ExecutionID,
+		NodeId:      node.Id,
+		DisplayId:   node.DisplayId,
+		Status:      models.Skipped,
+	}
+	if err := stepRepo.Insert(step); err != nil {
+		log.Printf("Failed to store skipped step %s: %v", node.Id, err)
+		return
+	}
+	if err := stepRepo.Finish(step); err != nil {
+		log.Printf("Failed to store skipped step %s: %v", node.Id, err)
+	}
 }
 
-func (orchestrator *OrchestratorService) executeAction(ctx context.Context, node models.WorkflowNode, userId int, state *ExecutionContext) (string, error) {
+// executeAction sends the task to its worker. The resolved config is kept on the step as its input
+func (orchestrator *OrchestratorService) executeAction(ctx context.Context, node models.WorkflowNode, userId int, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	config := node.Config
+	if node.ServiceName == "gmail" {
+		var err error
+		config, err = orchestrator.withEmailTemplate(config, userId)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// '{"subject": "Hello {{trigger.name}}"}' -> '{"subject": "Hello Bob"}'
-	// resolvedConfig, err := e.resolveVariables(node.ConfigJSON, state.CurrentData)
-	// if err != nil {
-	// 	return "", fmt.Errorf("variable resolution failed: %v", err)
-	// }
+	resolvedConfig, err := resolveVariables(config, state.Snapshot())
+	if err != nil {
+		return "", fmt.Errorf("variable resolution failed for node %s: %w", node.DisplayId, err)
+	}
+	step.Input = resolvedConfig
 
-	// Service Discovery
-	// Look up where the worker lives (e.g., "gmail" -> "localhost:50052")
-	// serviceAddr, exists := e.Registry[node.ServiceSlug]
-	// if !exists {
-	// 	// Fallback: Query DB if not in cache
-	// 	serviceAddr = "localhost:50052" // Hardcoded fallback for demo
-	// }
+	if state.DryRun {
+		return orchestrator.simulateAction(node, state, step)
+	}
+
+	// Find the worker of the service before fetching credentials for it
+	worker, err := orchestrator.Workers.Client(ctx, node.ServiceName, node.TaskName)
+	if err != nil {
+		return "", err
+	}
 
 	credentialId := 0
 	orchestrator.Db.QueryRow("SELECT id FROM credentials WHERE user_id = ?", userId).Scan(&credentialId)
@@ -118,91 +316,68 @@ func (orchestrator *OrchestratorService) executeAction(ctx context.Context, node
 	// if node.CredentialId != nil {
 	tokenResp, err := orchestrator.UserService.GetCredentials(ctx, &pb.GetCredentialsRequest{
 		CredentialId: int32(credentialId),
+		UserId:       int64(userId),
 	})
 	if err != nil {
-		return "", fmt.Errorf("auth failure: %v", err)
+		return "", fmt.Errorf("auth failure: %w", err)
 	}
 	authToken = tokenResp.AccessToken
 	// }
 
-	
-	if(node.ServiceName == "gmail") {
-		var subject, body, emailTo string
-		orchestrator.Db.QueryRow("SELECT subject, body, email_to FROM email_templates WHERE user_id = ?", userId).Scan(&subject, &body, &emailTo)
-		
-		res, err := orchestrator.GmailService.ExecuteTask(ctx, &pb.TaskRequest{
-			TaskName:   node.TaskName,
-			ConfigJson: fmt.Sprintf(`{
-				"subject": "%s",
-				"body": "%s",
-				"to": "%s"
-			}`, subject, body, emailTo),
-			AuthToken:  authToken,
-		})
-		if err != nil {
-			return "", err
-		}
-		if !res.Success {
-			return "", fmt.Errorf("Task failed: %s", res.ErrorMessage)
-		}
-
-		return res.OutputPayload, nil
+	res, err := worker.ExecuteTask(ctx, &pb.TaskRequest{
+		TaskName:   node.TaskName,
+		ConfigJson: resolvedConfig,
+		AuthToken:  authToken,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !res.Success {
+		return "", TaskError{Class: res.ErrorClass, Message: res.ErrorMessage}
 	}
-	return "", nil
-	// Dial the worker dynamically
-	// conn, err := grpc.Dial(serviceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	// if err != nil {
-	// 	return "", err
-	// }
-	// defer conn.Close()
 
-	// client := pb.NewTaskWorkerClient(conn)
+	return res.OutputPayload, nil
+}
 
-	// resp, err := client.ExecuteTask(ctx, &pb.TaskRequest{
-	// 	TaskType:   node.ActionSlug,   // e.g., "send_email"
-	// 	ConfigJson: resolvedConfig,    // The CLEAN, resolved JSON
-	// 	AuthToken:  authToken,         // The OAuth Access Token
-	// })
+// withEmailTemplate fills the fields which the node config leaves out from the user's email template.
+// The template itself can use variables too, e.g. "Re: {{trigger.email_subject}}"
+func (orchestrator *OrchestratorService) withEmailTemplate(configJSON string, userId int) (string, error) {
+	config := make(map[string]interface{})
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return "", fmt.Errorf("invalid node config: %v", err)
+		}
+	}
 
-	// if err != nil {
-	// 	return "", err
-	// }
+	var subject, body, emailTo string
+	orchestrator.Db.QueryRow("SELECT subject, body, email_to FROM email_templates WHERE user_id = ?", userId).Scan(&subject, &body, &emailTo)
 
-	// if !resp.Success {
-	// 	return "", fmt.Errorf("remote task failed: %s", resp.ErrorMessage)
-	// }
+	for key, value := range map[string]string{"subject": subject, "body": body, "to": emailTo} {
+		if _, ok := config[key]; !ok {
+			config[key] = value
+		}
+	}
+
+	merged, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
 
-	// return resp.OutputPayload, nil
+type workflowGraph struct {
+	nodes    map[string]models.WorkflowNode
+	incoming map[string][]models.WorkflowEdge
+	outgoing map[string][]models.WorkflowEdge
+	// The listener, or the loop node for the graph of a loop body
+	root     string
+	// Topological order of the nodes which can be reached from the listener
+	sorted   []models.WorkflowNode
+	// The nodes of sorted which run in this graph, the bodies of loops run in their own graphs
+	order    []models.WorkflowNode
 }
 
-// func (e *Engine) executeTransformer(ctx context.Context, node Node, state *ExecutionContext) (string, error) {
-// 	// Example: A "Delay" transformer
-// 	if node.ActionSlug == "delay" {
-// 		// Parse config to find duration
-// 		// e.g. {"seconds": 5}
-// 		log.Println("Transformer: Sleeping for 2 seconds...")
-// 		time.Sleep(2 * time.Second)
-// 		return `{"status": "delayed"}`, nil
-// 	}
-	
-// 	return "{}", nil
-// }
-
-// func (e *Engine) resolveVariables(templateJSON string, data map[string]interface{}) (string, error) {
-// 	// Uses Go's text/template to replace {{ key }} with values
-// 	tmpl, err := template.New("node").Option("missingkey=error").Parse(templateJSON)
-// 	if err != nil {
-// 		return "", err
-// 	}
-
-// 	var buf bytes.Buffer
-// 	if err := tmpl.Execute(&buf, data); err != nil {
-// 		return "", err
-// 	}
-// 	return buf.String(), nil
-// }
-
-func (orchestrator *OrchestratorService) getNodesInLinearOrder(listenerNode *models.WorkflowNode) ([]models.WorkflowNode, error) {
+func (orchestrator *OrchestratorService) getWorkflowGraph(listenerNode *models.WorkflowNode) (*workflowGraph, error) {
 	// TODO: Get from workflow service
 	nodeRepo := repositories.WorkflowNode{ Db: orchestrator.Db }
 	nodes, err := nodeRepo.FindByWorkflowId(listenerNode.WorkflowId)
@@ -210,9 +385,13 @@ func (orchestrator *OrchestratorService) getNodesInLinearOrder(listenerNode *mod
         return nil, fmt.Errorf("failed to fetch nodes: %v", err)
     }
 
-	idToNode := make(map[string]models.WorkflowNode)
+	graph := &workflowGraph{
+		nodes:    make(map[string]models.WorkflowNode),
+		incoming: make(map[string][]models.WorkflowEdge),
+		outgoing: make(map[string][]models.WorkflowEdge),
+	}
 	for _, node := range nodes {
-		idToNode[node.Id] = node
+		graph.nodes[node.Id] = node
     }
 
 	adjList := make(map[string][]string)
@@ -225,23 +404,68 @@ func (orchestrator *OrchestratorService) getNodesInLinearOrder(listenerNode *mod
 
 	for _, edge := range edges {
 		adjList[edge.NodeFrom] = append(adjList[edge.NodeFrom], edge.NodeTo)
+		graph.incoming[edge.NodeTo] = append(graph.incoming[edge.NodeTo], edge)
+		graph.outgoing[edge.NodeFrom] = append(graph.outgoing[edge.NodeFrom], edge)
 	}
 
-	// TODO: Only toposort the part of the graph that is reachable from listenerNode
-	toposorted, err := utils.TopologicalSort(adjList)
+	// A workflow can have several listeners, only the nodes downstream of the one which fired run
+	reachable := utils.ReachableFrom(adjList, listenerNode.Id)
+	toposorted, err := utils.TopologicalSort(utils.Subgraph(adjList, reachable))
 	if err != nil {
 		return nil, err
 	}
 
-	// Remove the trigger
-	toposorted = toposorted[1:]
-
-	toposortedNodes := make([]models.WorkflowNode, 0)
+	graph.root = listenerNode.Id
+	graph.sorted = make([]models.WorkflowNode, 0)
 	for _, nodeId := range toposorted {
-		toposortedNodes = append(toposortedNodes, idToNode[nodeId])
+		node, ok := graph.nodes[nodeId]
+		// The trigger itself already ran
+		if !ok || nodeId == listenerNode.Id || node.Type == models.Listener {
+			continue
+		}
+		graph.sorted = append(graph.sorted, node)
+	}
+	graph.order = graph.withoutLoopBodies(graph.sorted)
+
+	return graph, nil
+}
+
+// isEdgeActive tells if an edge leads the execution further: its source ran and,
+// when the source is a condition node, the edge belongs to the branch it selected.
+// on_error edges are the only active ones of a failed node
+func (graph *workflowGraph) isEdgeActive(edge models.WorkflowEdge, outputs map[string]interface{}) bool {
+	output, ran := outputs[edge.NodeFrom]
+	if !ran {
+		return false
 	}
+	_, failed := output.(nodeFailure)
+	if edge.Label == models.ErrorEdgeLabel || failed {
+		return edge.Label == models.ErrorEdgeLabel && failed
+	}
+	if graph.nodes[edge.NodeFrom].Type == models.Condition && edge.Label != selectedBranch(output) {
+		return false
+	}
+	// Body edges are only followed inside the graph of the loop's body
+	if graph.nodes[edge.NodeFrom].Type == models.Loop {
+		return (edge.Label == models.LoopBodyLabel) == (graph.root == edge.NodeFrom)
+	}
+	return true
+}
 
-	return toposortedNodes, nil
+// isReached tells if a node should run: at least one of its incoming edges is active
+func (graph *workflowGraph) isReached(nodeId string, outputs map[string]interface{}) bool {
+	return len(graph.activeUpstream(nodeId, outputs)) > 0
+}
+
+// activeUpstream returns the nodes through which a node was reached, in the order of its incoming edges
+func (graph *workflowGraph) activeUpstream(nodeId string, outputs map[string]interface{}) []models.WorkflowNode {
+	upstream := make([]models.WorkflowNode, 0)
+	for _, edge := range graph.incoming[nodeId] {
+		if graph.isEdgeActive(edge, outputs) {
+			upstream = append(upstream, graph.nodes[edge.NodeFrom])
+		}
+	}
+	return upstream
 }
 
 // func (orchestrator *OrchestratorService) getWorkflowStatus(workflowId int): active {
@@ -0,0 +1,45 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrLeaseLost is returned by heartbeats of a job which was leased by another worker or rescheduled
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Job is an execution waiting in the queue, or leased by a worker which runs it
+type Job struct {
+	Id          int
+	ExecutionId int
+	// How many times the job was leased since it was last queued, including the current lease.
+	// Only leases which ended without the job being completed or queued again (e.g. a crash) add up
+	Attempts   int
+	LeaseToken string
+}
+
+// Queue holds the executions which still have to run. There is at most one job per execution.
+// A worker leases a job, keeps the lease alive with heartbeats while the execution runs and completes it at the end.
+// Jobs whose lease expires (e.g. the worker crashed) can be leased again
+type Queue interface {
+	// Enqueue schedules the execution to run at runAt. An existing job of the execution
+	// is rescheduled instead and loses its lease, so completing it afterwards does nothing.
+	// Its attempts start over, a paused execution is not a crashed one
+	Enqueue(ctx context.Context, executionId int, runAt time.Time) error
+	// EnqueueIfMissing queues the execution to run now unless it already has a job
+	EnqueueIfMissing(ctx context.Context, executionId int) error
+	// Lease hands the next due job to the worker, nil when no job is due
+	Lease(ctx context.Context, workerId string, leaseFor time.Duration) (*Job, error)
+	Heartbeat(ctx context.Context, job *Job, leaseFor time.Duration) error
+	// Complete removes the job if the worker still holds its lease
+	Complete(ctx context.Context, job *Job) error
+}
+
+func newLeaseToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
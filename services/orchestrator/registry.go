@@ -0,0 +1,257 @@
+package orchestrator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// How long a registration read from the workers table is trusted before it is read again,
+// so a worker which registered with another orchestrator process at a new address is picked up
+const registryCacheTTL = 30 * time.Second
+
+// How long the catalog waits for a worker to describe its tasks
+const describeTimeout = 5 * time.Second
+
+// WorkerRegistration tells where the worker of a service runs and which tasks it has
+type WorkerRegistration struct {
+	ServiceName string   `json:"service_name"`
+	Address     string   `json:"address"`
+	Tasks       []string `json:"tasks"`
+}
+
+type cachedRegistration struct {
+	registration WorkerRegistration
+	loadedAt     time.Time
+}
+
+type cachedDescription struct {
+	address  string
+	tasks    []*pb.TaskDescription
+	loadedAt time.Time
+}
+
+// WorkerRegistry finds the worker of a service. Registrations are kept in the workers table,
+// so every orchestrator process sees them, and the connections to the workers are reused
+type WorkerRegistry struct {
+	Db *sql.DB
+
+	mu            sync.Mutex
+	registrations map[string]cachedRegistration
+	descriptions  map[string]cachedDescription
+	// address -> connection
+	conns map[string]*grpc.ClientConn
+}
+
+func NewWorkerRegistry(db *sql.DB) *WorkerRegistry {
+	return &WorkerRegistry{
+		Db:            db,
+		registrations: make(map[string]cachedRegistration),
+		descriptions:  make(map[string]cachedDescription),
+		conns:         make(map[string]*grpc.ClientConn),
+	}
+}
+
+// Validate checks that the registration names a service which is not built in and an address
+func (registration WorkerRegistration) Validate() error {
+	if registration.ServiceName == "" || registration.Address == "" {
+		return fmt.Errorf("a worker needs a service name and an address")
+	}
+	if registration.ServiceName == models.CoreServiceName {
+		return fmt.Errorf("the %s service is built into the orchestrator", models.CoreServiceName)
+	}
+	return nil
+}
+
+// Register stores the worker of a service, replacing the one registered before
+func (registry *WorkerRegistry) Register(ctx context.Context, registration WorkerRegistration) error {
+	if err := registration.Validate(); err != nil {
+		return err
+	}
+	if registration.Tasks == nil {
+		registration.Tasks = []string{}
+	}
+
+	tasks, err := json.Marshal(registration.Tasks)
+	if err != nil {
+		return err
+	}
+	_, err = registry.Db.ExecContext(ctx, `INSERT INTO workers(service_name, address, tasks) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE address = VALUES(address), tasks = VALUES(tasks)`,
+		registration.ServiceName, registration.Address, string(tasks))
+	if err != nil {
+		return err
+	}
+
+	registry.mu.Lock()
+	registry.registrations[registration.ServiceName] = cachedRegistration{registration: registration, loadedAt: time.Now()}
+	registry.mu.Unlock()
+	return nil
+}
+
+// LoadFile registers the workers listed in a JSON file:
+//
+//	[{"service_name": "gmail", "address": "localhost:50052", "tasks": ["send-email"]}]
+func (registry *WorkerRegistry) LoadFile(ctx context.Context, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var registrations []WorkerRegistration
+	if err := json.Unmarshal(content, &registrations); err != nil {
+		return fmt.Errorf("invalid worker file %s: %v", path, err)
+	}
+	for _, registration := range registrations {
+		if err := registry.Register(ctx, registration); err != nil {
+			return fmt.Errorf("worker %s in %s: %w", registration.ServiceName, path, err)
+		}
+	}
+	return nil
+}
+
+// Client returns a client for the worker which runs the task of the service
+func (registry *WorkerRegistry) Client(ctx context.Context, serviceName string, taskName string) (pb.TaskWorkerClient, error) {
+	registration, err := registry.lookup(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(registration.Tasks, taskName) {
+		return nil, TaskError{Class: models.ErrorClassInvalidInput, Message: fmt.Sprintf("the %s worker has no task %s", serviceName, taskName)}
+	}
+
+	conn, err := registry.conn(registration.Address)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewTaskWorkerClient(conn), nil
+}
+
+func (registry *WorkerRegistry) lookup(ctx context.Context, serviceName string) (WorkerRegistration, error) {
+	registry.mu.Lock()
+	cached, ok := registry.registrations[serviceName]
+	registry.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < registryCacheTTL {
+		return cached.registration, nil
+	}
+
+	registration := WorkerRegistration{ServiceName: serviceName}
+	var tasks string
+	err := registry.Db.QueryRowContext(ctx, "SELECT address, tasks FROM workers WHERE service_name = ?", serviceName).
+		Scan(&registration.Address, &tasks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkerRegistration{}, TaskError{Class: models.ErrorClassInvalidInput, Message: fmt.Sprintf("no worker is registered for service %s", serviceName)}
+	}
+	if err != nil {
+		// The last known registration is still better than failing the node
+		if ok {
+			return cached.registration, nil
+		}
+		return WorkerRegistration{}, fmt.Errorf("failed to look up worker of service %s: %v", serviceName, err)
+	}
+	if err := json.Unmarshal([]byte(tasks), &registration.Tasks); err != nil {
+		return WorkerRegistration{}, fmt.Errorf("invalid tasks of worker %s: %v", serviceName, err)
+	}
+
+	registry.mu.Lock()
+	registry.registrations[serviceName] = cachedRegistration{registration: registration, loadedAt: time.Now()}
+	registry.mu.Unlock()
+	return registration, nil
+}
+
+// Catalog describes the tasks of every registered worker. Workers which can't be reached are left out,
+// their tasks are missing until they answer again
+func (registry *WorkerRegistry) Catalog(ctx context.Context) ([]*pb.ServiceTasks, error) {
+	rows, err := registry.Db.QueryContext(ctx, "SELECT service_name, address FROM workers ORDER BY service_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workers := make([]WorkerRegistration, 0)
+	for rows.Next() {
+		var worker WorkerRegistration
+		if err := rows.Scan(&worker.ServiceName, &worker.Address); err != nil {
+			return nil, err
+		}
+		workers = append(workers, worker)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	catalog := make([]*pb.ServiceTasks, 0, len(workers))
+	for _, worker := range workers {
+		tasks, err := registry.describe(ctx, worker)
+		if err != nil {
+			log.Printf("Failed to describe the tasks of worker %s at %s: %v", worker.ServiceName, worker.Address, err)
+			continue
+		}
+		catalog = append(catalog, &pb.ServiceTasks{ServiceName: worker.ServiceName, Tasks: tasks})
+	}
+	return catalog, nil
+}
+
+func (registry *WorkerRegistry) describe(ctx context.Context, worker WorkerRegistration) ([]*pb.TaskDescription, error) {
+	registry.mu.Lock()
+	cached, ok := registry.descriptions[worker.ServiceName]
+	registry.mu.Unlock()
+	if ok && cached.address == worker.Address && time.Since(cached.loadedAt) < registryCacheTTL {
+		return cached.tasks, nil
+	}
+
+	conn, err := registry.conn(worker.Address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+	res, err := pb.NewTaskWorkerClient(conn).DescribeTasks(ctx, &pb.DescribeTasksRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	registry.mu.Lock()
+	registry.descriptions[worker.ServiceName] = cachedDescription{address: worker.Address, tasks: res.Tasks, loadedAt: time.Now()}
+	registry.mu.Unlock()
+	return res.Tasks, nil
+}
+
+// conn returns the connection to the address, opening it the first time. gRPC connects lazily
+// and reconnects by itself, so a connection is kept until the registry is closed
+func (registry *WorkerRegistry) conn(address string) (*grpc.ClientConn, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if conn, ok := registry.conns[address]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to worker at %s: %v", address, err)
+	}
+	registry.conns[address] = conn
+	return conn, nil
+}
+
+// Close closes the connections to the workers
+func (registry *WorkerRegistry) Close() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for address, conn := range registry.conns {
+		conn.Close()
+		delete(registry.conns, address)
+	}
+}
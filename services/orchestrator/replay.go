@@ -0,0 +1,163 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/utils"
+)
+
+// ReplayOptions pick what a replay runs again. Without any, the whole execution runs again
+type ReplayOptions struct {
+	// Run the nodes which did not succeed again and reuse the outputs of the others
+	FromFailure bool
+	// Display id of a node which runs again together with everything downstream of it
+	FromNode string
+}
+
+// ReplayError is a replay which the execution doesn't allow, e.g. one which is still running
+type ReplayError struct {
+	Message string
+}
+
+func (err ReplayError) Error() string {
+	return err.Message
+}
+
+// ReplayExecution queues a new execution with the trigger payload of a finished one. When it only re-runs
+// part of the workflow, the steps which succeeded before and are not re-run are copied into the new
+// execution, which then continues after them like a resumed execution
+func (orchestrator *OrchestratorService) ReplayExecution(ctx context.Context, executionId int, options ReplayOptions) (*models.Execution, error) {
+	executionRepo := repositories.Execution{Db: orchestrator.Db}
+	workflowNodeRepo := repositories.WorkflowNode{Db: orchestrator.Db}
+	stepRepo := repositories.ExecutionStep{Db: orchestrator.Db}
+
+	original, err := executionRepo.FindById(executionId)
+	if err != nil {
+		return nil, err
+	}
+	if !original.Status.IsFinal() {
+		return nil, ReplayError{Message: fmt.Sprintf("execution %d is still %s", executionId, original.Status)}
+	}
+
+	listenerNode, err := workflowNodeRepo.FindById(original.ListenerNodeId)
+	if err != nil {
+		return nil, ReplayError{Message: "the listener of the execution was removed from the workflow"}
+	}
+
+	reused := make([]models.ExecutionStep, 0)
+	if options.FromFailure || options.FromNode != "" {
+		graph, err := orchestrator.getWorkflowGraph(listenerNode)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := stepRepo.FindByExecutionId(executionId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch steps of execution %d: %v", executionId, err)
+		}
+		reused, err = stepsToReuse(graph, steps, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	originalId := original.Id
+	execution := &models.Execution{
+		WorkflowId:          original.WorkflowId,
+		ListenerNodeId:      original.ListenerNodeId,
+		Status:              models.Pending,
+		TriggerPayload:      original.TriggerPayload,
+		Depth:               original.Depth,
+		DryRun:              original.DryRun,
+		ReplayOfExecutionId: &originalId,
+	}
+	if err := executionRepo.Insert(execution); err != nil {
+		return nil, fmt.Errorf("failed to store execution: %v", err)
+	}
+
+	// The steps are in place before the execution is queued, so the worker resumes after them
+	for _, step := range reused {
+		copied := &models.ExecutionStep{
+			ExecutionId: execution.Id,
+			NodeId:      step.NodeId,
+			DisplayId:   step.DisplayId,
+			Status:      models.Succeeded,
+			Input:       step.Input,
+			Output:      step.Output,
+			StartedAt:   step.StartedAt,
+		}
+		if err := stepRepo.Insert(copied); err != nil {
+			return nil, fmt.Errorf("failed to copy step %d: %v", step.Id, err)
+		}
+		if err := stepRepo.Finish(copied); err != nil {
+			return nil, fmt.Errorf("failed to copy step %d: %v", step.Id, err)
+		}
+	}
+
+	if err := orchestrator.Queue.Enqueue(ctx, execution.Id, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to queue execution: %v", err)
+	}
+	log.Printf("Execution %d replays execution %d, reusing %d steps", execution.Id, executionId, len(reused))
+	return execution, nil
+}
+
+// stepsToReuse returns the succeeded steps which are not re-run: neither one of the nodes to run again
+// nor downstream of one
+func stepsToReuse(graph *workflowGraph, steps []models.ExecutionStep, options ReplayOptions) ([]models.ExecutionStep, error) {
+	adjList := make(map[string][]string)
+	for nodeId, edges := range graph.outgoing {
+		for _, edge := range edges {
+			adjList[nodeId] = append(adjList[nodeId], edge.NodeTo)
+		}
+	}
+
+	// Latest step per node, an execution which was resumed can have several
+	latest := make(map[string]models.ExecutionStep)
+	for _, step := range steps {
+		latest[step.NodeId] = step
+	}
+
+	starts := make([]string, 0)
+	if options.FromNode != "" {
+		found := false
+		for _, node := range graph.nodes {
+			if node.DisplayId == options.FromNode {
+				starts = append(starts, node.Id)
+				found = true
+			}
+		}
+		if !found {
+			return nil, ReplayError{Message: fmt.Sprintf("node %s is not in the workflow", options.FromNode)}
+		}
+	}
+	if options.FromFailure {
+		for nodeId, step := range latest {
+			if step.Status != models.Succeeded && step.Status != models.Skipped {
+				starts = append(starts, nodeId)
+			}
+		}
+		if len(starts) == 0 {
+			return nil, ReplayError{Message: "the execution has no failed step"}
+		}
+	}
+
+	rerun := make(map[string]bool)
+	for _, start := range starts {
+		for nodeId := range utils.ReachableFrom(adjList, start) {
+			rerun[nodeId] = true
+		}
+	}
+
+	reused := make([]models.ExecutionStep, 0)
+	for _, step := range steps {
+		if step.Status != models.Succeeded || rerun[step.NodeId] || latest[step.NodeId].Id != step.Id {
+			continue
+		}
+		reused = append(reused, step)
+	}
+	return reused, nil
+}
@@ -0,0 +1,53 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// previousSteps loads what earlier runs of an interrupted execution already did, the latest step per node id.
+// Steps which were still running when the process stopped are marked as failed, their nodes run again
+func (orchestrator *OrchestratorService) previousSteps(executionId int) (map[string]models.ExecutionStep, error) {
+	stepRepo := repositories.ExecutionStep{Db: orchestrator.Db}
+	steps, err := stepRepo.FindByExecutionId(executionId)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]models.ExecutionStep)
+	for _, step := range steps {
+		if step.Status == models.Running {
+			step.Status = models.Failed
+			step.Error = "interrupted before the node finished"
+			if err := stepRepo.Finish(&step); err != nil {
+				log.Printf("Failed to store step %d: %v", step.Id, err)
+			}
+		}
+		previous[step.NodeId] = step
+	}
+	return previous, nil
+}
+
+// isHandledFailure tells if the step is a failure of the node itself, which its on_error edges handled.
+// Steps which were interrupted have no error class and run again
+func isHandledFailure(graph *workflowGraph, step models.ExecutionStep) bool {
+	return (step.Status == models.Failed || step.Status == models.TimedOut) && step.ErrorClass != "" && graph.hasErrorHandler(step.NodeId)
+}
+
+// restoreStep puts the output of a node which succeeded in an earlier run back into the state.
+// The branch of a condition node is part of its output, so it is recovered too
+func restoreStep(node models.WorkflowNode, step models.ExecutionStep, state *ExecutionContext) (interface{}, error) {
+	if step.Output == "" {
+		return nil, nil
+	}
+	var output interface{}
+	if err := json.Unmarshal([]byte(step.Output), &output); err != nil {
+		return nil, fmt.Errorf("failed to restore output of node %s: %v", node.DisplayId, err)
+	}
+	state.Set(node.DisplayId, output)
+	return output, nil
+}
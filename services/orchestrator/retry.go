@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TaskError is a task which a worker ran and reported as failed
+type TaskError struct {
+	Class   string
+	Message string
+}
+
+func (err TaskError) Error() string {
+	if err.Class == "" {
+		return fmt.Sprintf("Task failed: %s", err.Message)
+	}
+	return fmt.Sprintf("Task failed (%s): %s", err.Class, err.Message)
+}
+
+// errorClassOf tells why an action failed, the retry policy of the node decides if the class is retried
+func errorClassOf(err error) string {
+	var taskErr TaskError
+	if errors.As(err, &taskErr) {
+		if taskErr.Class == "" {
+			return models.ErrorClassUnknown
+		}
+		return taskErr.Class
+	}
+
+	var variableErr VariableError
+	if errors.As(err, &variableErr) {
+		return models.ErrorClassInvalidInput
+	}
+
+	var timeoutErr NodeTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return models.ErrorClassTimeout
+	}
+
+	// The worker or the user service could not be reached or answered with an error
+	if grpcStatus, ok := status.FromError(err); ok {
+		switch grpcStatus.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+			return models.ErrorClassTransient
+		case codes.ResourceExhausted:
+			return models.ErrorClassRateLimited
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return models.ErrorClassAuth
+		case codes.InvalidArgument:
+			return models.ErrorClassInvalidInput
+		}
+	}
+	return models.ErrorClassUnknown
+}
+
+// executeWithRetry runs an action until it succeeds, fails with an error class which is not retried
+// or runs out of the attempts of the node's retry policy. Every attempt is stored for the step
+func (orchestrator *OrchestratorService) executeWithRetry(ctx context.Context, node models.WorkflowNode, userId int, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	attemptRepo := repositories.ExecutionStepAttempt{Db: orchestrator.Db}
+
+	settings, err := models.ParseNodeSettings(node.Settings)
+	if err != nil {
+		return "", TaskError{Class: models.ErrorClassInvalidInput, Message: fmt.Sprintf("invalid settings of node %s: %v", node.DisplayId, err)}
+	}
+	policy := settings.Retry
+	if policy == nil {
+		// No policy, the action runs once
+		policy = &models.RetryPolicy{MaxAttempts: 1}
+	}
+
+	timeout := time.Duration(settings.TimeoutMs) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		startedAt := time.Now()
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		output, err := orchestrator.executeAction(attemptCtx, node, userId, state, step)
+		// Only the node's own timeout, the execution running out of time is not retried
+		if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = NodeTimeoutError{Node: node.DisplayId, Timeout: timeout}
+		}
+		cancel()
+
+		record := &models.ExecutionStepAttempt{
+			StepId:     step.Id,
+			Attempt:    attempt,
+			Status:     models.Succeeded,
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		}
+		if err != nil {
+			record.Status = models.Failed
+			record.Error = err.Error()
+			record.ErrorClass = errorClassOf(err)
+		}
+		if insertErr := attemptRepo.Insert(record); insertErr != nil {
+			log.Printf("Failed to store attempt %d of step %d: %v", attempt, step.Id, insertErr)
+		}
+
+		if err == nil {
+			return output, nil
+		}
+		if attempt >= policy.MaxAttempts || !slices.Contains(policy.RetryOn, record.ErrorClass) {
+			if attempt > 1 {
+				return "", fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return "", err
+		}
+
+		delay := retryBackoff(policy, attempt)
+		log.Printf("Node %s failed (%s), retrying in %v: %v", node.DisplayId, record.ErrorClass, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return "", err
+		}
+	}
+}
+
+// retryBackoff is the wait after the given attempt: initial_backoff * multiplier^(attempt-1), +- jitter,
+// at most models.MaxRetryBackoffMs
+func retryBackoff(policy *models.RetryPolicy, attempt int) time.Duration {
+	backoff := float64(policy.InitialBackoffMs) * math.Pow(policy.Multiplier, float64(attempt-1))
+	if policy.Jitter > 0 {
+		backoff *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+	backoff = min(backoff, models.MaxRetryBackoffMs)
+	return time.Duration(backoff) * time.Millisecond
+}
+
+// sleepContext waits for the delay unless the execution is cancelled first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
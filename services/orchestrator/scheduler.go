@@ -0,0 +1,201 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+const defaultMaxParallelNodes = 4
+
+type nodeResult struct {
+	node   models.WorkflowNode
+	output interface{}
+	err    error
+}
+
+// runGraph starts every node as soon as all of its dependencies are done, so independent branches
+// run at the same time. At most MaxParallelNodes goroutines run per execution.
+// A failed node with on_error edges continues on those edges only. After any other failure
+// no new nodes are started, the ones already running are waited for.
+// Nodes which succeeded in a previous run of the execution are not run again, their output is reused.
+// Nodes whose failure was handled by on_error edges are not run again either, their edges are followed again
+// When a branch reaches a wait node which is not due, a WaitingError with the earliest resume time is returned
+func (orchestrator *OrchestratorService) runGraph(ctx context.Context, graph *workflowGraph, listenerNode *models.WorkflowNode, userId int, state *ExecutionContext, previous map[string]models.ExecutionStep) error {
+	maxParallel := orchestrator.MaxParallelNodes
+	if maxParallel <= 0 {
+		maxParallel = defaultMaxParallelNodes
+	}
+
+	inPlan := map[string]bool{listenerNode.Id: true}
+	for _, node := range graph.order {
+		inPlan[node.Id] = true
+	}
+
+	// Number of incoming edges per node whose source is not done yet.
+	// Edges from nodes outside of the plan (e.g. the branch of another listener) never resolve, so they are not counted
+	remaining := make(map[string]int)
+	for _, node := range graph.order {
+		for _, edge := range graph.incoming[node.Id] {
+			if inPlan[edge.NodeFrom] {
+				remaining[node.Id]++
+			}
+		}
+	}
+
+	// Outputs of the nodes which ran, the listener's output is the trigger payload.
+	// Only this goroutine touches it
+	outputs := map[string]interface{}{listenerNode.Id: state.Snapshot()["trigger"]}
+	ready := make([]models.WorkflowNode, 0)
+	// Nodes which were queued or skipped, a join node waiting for any input can be reached more than once
+	handled := make(map[string]bool)
+	// The nodes through which each queued node was reached, taken when it was queued
+	upstream := make(map[string][]models.WorkflowNode)
+
+	// resolve marks a node as done (ran or skipped) and queues the nodes which were waiting on it
+	var resolve func(nodeId string)
+	resolve = func(nodeId string) {
+		for _, edge := range graph.outgoing[nodeId] {
+			if !inPlan[edge.NodeTo] {
+				continue
+			}
+			remaining[edge.NodeTo]--
+
+			next := graph.nodes[edge.NodeTo]
+			if handled[next.Id] {
+				continue
+			}
+
+			// A join node waiting for any input starts with the first branch which reaches it
+			if next.Type == models.Join && graph.isEdgeActive(edge, outputs) {
+				if config, err := models.ParseJoinConfig(next.Config); err == nil && config.Strategy == models.JoinAny {
+					handled[next.Id] = true
+					upstream[next.Id] = graph.activeUpstream(next.Id, outputs)
+					ready = append(ready, next)
+					continue
+				}
+			}
+
+			if remaining[next.Id] > 0 {
+				continue
+			}
+			handled[next.Id] = true
+
+			// Everything downstream of a branch which was not taken is skipped
+			if !graph.isReached(next.Id, outputs) {
+				log.Printf("Skipping Node: %s (%s)", next.Id, next.Type)
+				if step, ok := previous[next.Id]; !ok || step.Status != models.Skipped {
+					orchestrator.skipStep(next, state)
+				}
+				resolve(next.Id)
+				continue
+			}
+			upstream[next.Id] = graph.activeUpstream(next.Id, outputs)
+			ready = append(ready, next)
+		}
+	}
+	resolve(listenerNode.Id)
+
+	results := make(chan nodeResult)
+	running := 0
+	var firstErr error
+	// The earliest wait node which is not due yet
+	var waiting *WaitingError
+
+	for len(ready) > 0 || running > 0 {
+		// A cancelled or timed out execution starts no new nodes
+		if firstErr == nil && ctx.Err() != nil {
+			firstErr = context.Cause(ctx)
+		}
+		for firstErr == nil && len(ready) > 0 && running < maxParallel {
+			node := ready[0]
+			ready = ready[1:]
+
+			if step, ok := previous[node.Id]; ok && step.Status == models.Succeeded {
+				output, err := restoreStep(node, step, state)
+				if err != nil {
+					firstErr = err
+					break
+				}
+				outputs[node.Id] = output
+				resolve(node.Id)
+				continue
+			}
+			if step, ok := previous[node.Id]; ok && isHandledFailure(graph, step) {
+				failure := nodeFailure{Error: step.Error, ErrorClass: step.ErrorClass}
+				state.Set(node.DisplayId, failure.data())
+				outputs[node.Id] = failure
+				resolve(node.Id)
+				continue
+			}
+
+			if node.Type != models.Action && node.Type != models.Transformer && node.Type != models.Condition && node.Type != models.Join && node.Type != models.Loop {
+				log.Printf("Skipping unknown node type: %s", node.Type)
+				outputs[node.Id] = nil
+				resolve(node.Id)
+				continue
+			}
+
+			log.Printf("Executing Node: %s (%s)", node.Id, node.Type)
+			running++
+			nodeUpstream := upstream[node.Id]
+			var nodePrevious *models.ExecutionStep
+			if step, ok := previous[node.Id]; ok {
+				nodePrevious = &step
+			}
+			go func() {
+				var output interface{}
+				var err error
+				if isWaitNode(node) {
+					output, err = orchestrator.executeWait(node, state, nodePrevious)
+				} else if isInvokeNode(node) {
+					output, err = orchestrator.executeInvoke(ctx, node, userId, state, nodePrevious)
+				} else {
+					output, err = orchestrator.executeStep(ctx, graph, node, userId, state, nodeUpstream)
+				}
+				results <- nodeResult{node: node, output: output, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		// The branch stops at the wait node, the others keep going until they are done or waiting too
+		var waitErr WaitingError
+		if errors.As(result.err, &waitErr) {
+			log.Printf("Node %s waits until %v", result.node.Id, waitErr.Until)
+			if waiting == nil || waitErr.Until.Before(waiting.Until) {
+				waiting = &waitErr
+			}
+			continue
+		}
+
+		if result.err != nil && graph.hasErrorHandler(result.node.Id) {
+			log.Printf("Node %s failed, following its on_error edges: %v", result.node.Id, result.err)
+			failure := nodeFailure{Error: result.err.Error(), ErrorClass: errorClassOf(result.err)}
+			state.Set(result.node.DisplayId, failure.data())
+			outputs[result.node.Id] = failure
+			resolve(result.node.Id)
+			continue
+		}
+		if result.err != nil {
+			log.Printf("Workflow Failed at Node %s: %v", result.node.Id, result.err)
+			if firstErr == nil {
+				firstErr = NodeError{Node: result.node, Err: result.err}
+			}
+			continue
+		}
+		outputs[result.node.Id] = result.output
+		resolve(result.node.Id)
+	}
+
+	if firstErr == nil && waiting != nil {
+		return *waiting
+	}
+	return firstErr
+}
@@ -0,0 +1,503 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+// Built-in transformers, they run inside the orchestrator instead of a worker.
+// The config is resolved like the config of an action first, so the inputs are usually variables:
+//
+//	map:           {"fields": {"subject": "{{trigger.email_subject}}", "from": "{{trigger.email_from}}"}}
+//	rename:        {"input": "{{node-1}}", "fields": {"email_subject": "subject"}}
+//	pick:          {"input": "{{trigger}}", "keys": ["email_subject", "email_from"]}
+//	omit:          {"input": "{{trigger}}", "keys": ["email_body"]}
+//	regex-extract: {"input": "{{trigger.email_subject}}", "pattern": "Order #(?P<order>\\d+)", "all": false}
+//	split:         {"input": "a, b, c", "separator": ","}
+//	trim:          {"input": "  text  ", "characters": ""}
+//	case:          {"input": "{{trigger.email_from}}", "to": "lower" | "upper" | "title"}
+//	math:          {"operation": "add", "values": [1, "{{node-1.total}}"], "precision": 2}
+//	date-parse:    {"input": "{{trigger.email_date}}", "layout": "", "timezone": "Europe/Sofia"}
+//	date-format:   {"input": "{{trigger.email_date}}", "format": "date" | "datetime" | "rfc3339" | "unix" | "unix_ms" | Go layout, "timezone": "UTC"}
+//
+// Transformers which produce a single value output {"value": ...}
+type transformerFunc func(config []byte) (interface{}, error)
+
+var transformers = map[string]transformerFunc{
+	"map":           mapTransformer,
+	"rename":        renameTransformer,
+	"pick":          pickTransformer,
+	"omit":          omitTransformer,
+	"regex-extract": regexExtractTransformer,
+	"split":         splitTransformer,
+	"trim":          trimTransformer,
+	"case":          caseTransformer,
+	"math":          mathTransformer,
+	"date-parse":    dateParseTransformer,
+	"date-format":   dateFormatTransformer,
+}
+
+func (orchestrator *OrchestratorService) executeTransformer(node models.WorkflowNode, state *ExecutionContext, step *models.ExecutionStep) (string, error) {
+	transform, ok := transformers[node.TaskName]
+	if !ok {
+		return "", fmt.Errorf("unknown transformer %s", node.TaskName)
+	}
+
+	resolvedConfig, err := resolveVariables(node.Config, state.Snapshot())
+	if err != nil {
+		return "", fmt.Errorf("variable resolution failed for node %s: %w", node.DisplayId, err)
+	}
+	step.Input = resolvedConfig
+
+	output, err := transform([]byte(resolvedConfig))
+	if err != nil {
+		return "", fmt.Errorf("transformer %s (%s) failed: %w", node.DisplayId, node.TaskName, err)
+	}
+
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func parseTransformerConfig(config []byte, target interface{}) error {
+	if err := json.Unmarshal(config, target); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+func valueOutput(value interface{}) map[string]interface{} {
+	return map[string]interface{}{"value": value}
+}
+
+func mapTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Fields == nil {
+		return map[string]interface{}{}, nil
+	}
+	return c.Fields, nil
+}
+
+func renameTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input  map[string]interface{} `json:"input"`
+		Fields map[string]string      `json:"fields"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]interface{}, len(c.Input))
+	for key, value := range c.Input {
+		if newKey, ok := c.Fields[key]; ok {
+			key = newKey
+		}
+		output[key] = value
+	}
+	return output, nil
+}
+
+func pickTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input map[string]interface{} `json:"input"`
+		Keys  []string               `json:"keys"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]interface{}, len(c.Keys))
+	for _, key := range c.Keys {
+		if value, ok := c.Input[key]; ok {
+			output[key] = value
+		}
+	}
+	return output, nil
+}
+
+func omitTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input map[string]interface{} `json:"input"`
+		Keys  []string               `json:"keys"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]interface{}, len(c.Input))
+	for key, value := range c.Input {
+		output[key] = value
+	}
+	for _, key := range c.Keys {
+		delete(output, key)
+	}
+	return output, nil
+}
+
+// regexExtractTransformer outputs the first match: {"matched": true, "match": "Order #42", "groups": ["42"], "named": {"order": "42"}}
+// or with "all" every match: {"matched": true, "matches": [{"match": ..., "groups": ..., "named": ...}]}
+func regexExtractTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input   interface{} `json:"input"`
+		Pattern string      `json:"pattern"`
+		All     bool        `json:"all"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	input := toText(c.Input)
+	describe := func(submatches []string) map[string]interface{} {
+		groups := make([]interface{}, 0, len(submatches)-1)
+		named := make(map[string]interface{})
+		for i, submatch := range submatches[1:] {
+			groups = append(groups, submatch)
+			if name := re.SubexpNames()[i+1]; name != "" {
+				named[name] = submatch
+			}
+		}
+		return map[string]interface{}{"match": submatches[0], "groups": groups, "named": named}
+	}
+
+	if c.All {
+		matches := make([]interface{}, 0)
+		for _, submatches := range re.FindAllStringSubmatch(input, -1) {
+			matches = append(matches, describe(submatches))
+		}
+		return map[string]interface{}{"matched": len(matches) > 0, "matches": matches}, nil
+	}
+
+	submatches := re.FindStringSubmatch(input)
+	if submatches == nil {
+		return map[string]interface{}{"matched": false, "match": nil, "groups": []interface{}{}, "named": map[string]interface{}{}}, nil
+	}
+	output := describe(submatches)
+	output["matched"] = true
+	return output, nil
+}
+
+// splitTransformer outputs {"items": ["a", "b", "c"], "count": 3}, the items are trimmed and empty ones dropped
+func splitTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input     interface{} `json:"input"`
+		Separator string      `json:"separator"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Separator == "" {
+		c.Separator = ","
+	}
+
+	items := make([]interface{}, 0)
+	for _, item := range strings.Split(toText(c.Input), c.Separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return map[string]interface{}{"items": items, "count": len(items)}, nil
+}
+
+func trimTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input interface{} `json:"input"`
+		// Trimmed instead of whitespace when set
+		Characters string `json:"characters"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	if c.Characters != "" {
+		return valueOutput(strings.Trim(toText(c.Input), c.Characters)), nil
+	}
+	return valueOutput(strings.TrimSpace(toText(c.Input))), nil
+}
+
+func caseTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input interface{} `json:"input"`
+		To    string      `json:"to"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	input := toText(c.Input)
+	switch c.To {
+	case "lower":
+		return valueOutput(strings.ToLower(input)), nil
+	case "upper":
+		return valueOutput(strings.ToUpper(input)), nil
+	case "title":
+		return valueOutput(titleCase(input)), nil
+	default:
+		return nil, fmt.Errorf("unknown case %q, expected lower, upper or title", c.To)
+	}
+}
+
+// titleCase upper cases the first letter of every word and lower cases the rest
+func titleCase(s string) string {
+	runes := []rune(s)
+	wordStart := true
+	for i, r := range runes {
+		if unicode.IsSpace(r) || r == '-' {
+			wordStart = true
+			continue
+		}
+		if wordStart {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+		wordStart = false
+	}
+	return string(runes)
+}
+
+// mathTransformer applies the operation to its values from left to right, e.g. subtract: values[0] - values[1] - ...
+// abs, floor, ceil and round take a single value. The result is rounded to precision decimals when it is set
+func mathTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Operation string        `json:"operation"`
+		Values    []interface{} `json:"values"`
+		Precision *int          `json:"precision"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Values) == 0 {
+		return nil, fmt.Errorf("math needs at least one value")
+	}
+
+	values := make([]float64, 0, len(c.Values))
+	for _, value := range c.Values {
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, number)
+	}
+
+	var result float64
+	switch c.Operation {
+	case "add", "subtract", "multiply", "divide", "modulo", "min", "max":
+		result = values[0]
+		for _, value := range values[1:] {
+			switch c.Operation {
+			case "add":
+				result += value
+			case "subtract":
+				result -= value
+			case "multiply":
+				result *= value
+			case "divide", "modulo":
+				if value == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				if c.Operation == "divide" {
+					result /= value
+				} else {
+					result = math.Mod(result, value)
+				}
+			case "min":
+				result = math.Min(result, value)
+			case "max":
+				result = math.Max(result, value)
+			}
+		}
+	case "abs", "floor", "ceil", "round":
+		if len(values) != 1 {
+			return nil, fmt.Errorf("%s takes a single value", c.Operation)
+		}
+		switch c.Operation {
+		case "abs":
+			result = math.Abs(values[0])
+		case "floor":
+			result = math.Floor(values[0])
+		case "ceil":
+			result = math.Ceil(values[0])
+		case "round":
+			result = math.Round(values[0])
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation %q", c.Operation)
+	}
+
+	if c.Precision != nil {
+		scale := math.Pow(10, float64(*c.Precision))
+		result = math.Round(result*scale) / scale
+	}
+	return valueOutput(result), nil
+}
+
+// toNumber accepts numbers and numeric strings, variables inside longer strings always resolve to strings
+func toNumber(value interface{}) (float64, error) {
+	switch v := normalizeValue(value).(type) {
+	case float64:
+		return v, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return number, nil
+	default:
+		return 0, fmt.Errorf("%s is not a number", jsonTypeName(v))
+	}
+}
+
+// Tried in order when a date has no explicit layout. Emails use the RFC 1123 / 822 forms
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 -0700 (MST)",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	time.UnixDate,
+	time.ANSIC,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Names which can be used instead of a Go layout
+var namedDateFormats = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123Z,
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": "2006-01-02 15:04:05",
+}
+
+// parseDate reads unix seconds or a date string. Dates without a zone are in loc
+func parseDate(input interface{}, layout string, loc *time.Location) (time.Time, error) {
+	if number, ok := normalizeValue(input).(float64); ok {
+		return time.Unix(int64(number), 0).In(loc), nil
+	}
+
+	text := strings.TrimSpace(toText(input))
+	if text == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	if layout != "" {
+		if named, ok := namedDateFormats[layout]; ok {
+			layout = named
+		}
+		t, err := time.ParseInLocation(layout, text, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q: %v", text, err)
+		}
+		return t, nil
+	}
+	for _, candidate := range dateLayouts {
+		if t, err := time.ParseInLocation(candidate, text, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date", text)
+}
+
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// dateParseTransformer outputs the date and its parts in the timezone:
+// {"value": "2024-03-01T10:00:00+02:00", "unix": 1709280000, "year": 2024, "month": 3, "day": 1, "hour": 10, ...}
+func dateParseTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input    interface{} `json:"input"`
+		Layout   string      `json:"layout"`
+		Timezone string      `json:"timezone"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseDate(c.Input, c.Layout, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	t = t.In(loc)
+	return map[string]interface{}{
+		"value":   t.Format(time.RFC3339),
+		"unix":    t.Unix(),
+		"year":    t.Year(),
+		"month":   int(t.Month()),
+		"day":     t.Day(),
+		"hour":    t.Hour(),
+		"minute":  t.Minute(),
+		"second":  t.Second(),
+		"weekday": strings.ToLower(t.Weekday().String()),
+	}, nil
+}
+
+func dateFormatTransformer(config []byte) (interface{}, error) {
+	var c struct {
+		Input       interface{} `json:"input"`
+		InputLayout string      `json:"input_layout"`
+		Format      string      `json:"format"`
+		Timezone    string      `json:"timezone"`
+	}
+	if err := parseTransformerConfig(config, &c); err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseDate(c.Input, c.InputLayout, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	t = t.In(loc)
+	switch c.Format {
+	case "":
+		return valueOutput(t.Format(time.RFC3339)), nil
+	case "unix":
+		return valueOutput(t.Unix()), nil
+	case "unix_ms":
+		return valueOutput(t.UnixMilli()), nil
+	}
+	if named, ok := namedDateFormats[c.Format]; ok {
+		return valueOutput(t.Format(named)), nil
+	}
+	return valueOutput(t.Format(c.Format)), nil
+}
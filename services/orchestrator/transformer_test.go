@@ -0,0 +1,162 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformers(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   string
+		config string
+		// JSON of the output
+		want string
+		// Part of the error message, the output is not checked when it is set
+		wantErr string
+	}{
+		{name: "map", task: "map", config: `{"fields": {"subject": "Hi", "count": 2}}`, want: `{"subject": "Hi", "count": 2}`},
+		{name: "map without fields", task: "map", config: `{}`, want: `{}`},
+		{name: "map invalid config", task: "map", config: `{"fields": []}`, wantErr: "invalid config"},
+
+		{name: "rename", task: "rename", config: `{"input": {"a": 1, "b": 2}, "fields": {"a": "x", "c": "y"}}`, want: `{"x": 1, "b": 2}`},
+		{name: "rename input not an object", task: "rename", config: `{"input": "text"}`, wantErr: "invalid config"},
+
+		{name: "pick", task: "pick", config: `{"input": {"a": 1, "b": 2, "c": null}, "keys": ["a", "c", "missing"]}`, want: `{"a": 1, "c": null}`},
+		{name: "omit", task: "omit", config: `{"input": {"a": 1, "b": 2}, "keys": ["b", "missing"]}`, want: `{"a": 1}`},
+
+		{
+			name:   "regex-extract first match",
+			task:   "regex-extract",
+			config: `{"input": "Order #42 and #43", "pattern": "#(?P<order>\\d+)"}`,
+			want:   `{"matched": true, "match": "#42", "groups": ["42"], "named": {"order": "42"}}`,
+		},
+		{
+			name:   "regex-extract all",
+			task:   "regex-extract",
+			config: `{"input": "a1 b2", "pattern": "([a-z])(\\d)", "all": true}`,
+			want: `{"matched": true, "matches": [
+				{"match": "a1", "groups": ["a", "1"], "named": {}},
+				{"match": "b2", "groups": ["b", "2"], "named": {}}
+			]}`,
+		},
+		{
+			name:   "regex-extract no match",
+			task:   "regex-extract",
+			config: `{"input": "nothing", "pattern": "\\d+"}`,
+			want:   `{"matched": false, "match": null, "groups": [], "named": {}}`,
+		},
+		{name: "regex-extract all without match", task: "regex-extract", config: `{"input": "x", "pattern": "\\d", "all": true}`, want: `{"matched": false, "matches": []}`},
+		{name: "regex-extract number input", task: "regex-extract", config: `{"input": 1234, "pattern": "^\\d{2}"}`, want: `{"matched": true, "match": "12", "groups": [], "named": {}}`},
+		{name: "regex-extract bad pattern", task: "regex-extract", config: `{"input": "x", "pattern": "(unclosed"}`, wantErr: "invalid pattern"},
+
+		{name: "split", task: "split", config: `{"input": " a, b,,c ", "separator": ","}`, want: `{"items": ["a", "b", "c"], "count": 3}`},
+		{name: "split default separator", task: "split", config: `{"input": "a,b"}`, want: `{"items": ["a", "b"], "count": 2}`},
+		{name: "split other separator", task: "split", config: `{"input": "a | b", "separator": "|"}`, want: `{"items": ["a", "b"], "count": 2}`},
+		{name: "split empty", task: "split", config: `{"input": ""}`, want: `{"items": [], "count": 0}`},
+
+		{name: "trim", task: "trim", config: `{"input": "  text \n"}`, want: `{"value": "text"}`},
+		{name: "trim characters", task: "trim", config: `{"input": "--text--", "characters": "-"}`, want: `{"value": "text"}`},
+
+		{name: "case lower", task: "case", config: `{"input": "Ann@Example.COM", "to": "lower"}`, want: `{"value": "ann@example.com"}`},
+		{name: "case upper", task: "case", config: `{"input": "café", "to": "upper"}`, want: `{"value": "CAFÉ"}`},
+		{name: "case title", task: "case", config: `{"input": "mary-ann o'NEIL", "to": "title"}`, want: `{"value": "Mary-Ann O'neil"}`},
+		{name: "case unknown", task: "case", config: `{"input": "x", "to": "snake"}`, wantErr: "unknown case"},
+
+		{name: "math add", task: "math", config: `{"operation": "add", "values": [1, "2.5", 3]}`, want: `{"value": 6.5}`},
+		{name: "math subtract", task: "math", config: `{"operation": "subtract", "values": [10, 3, 2]}`, want: `{"value": 5}`},
+		{name: "math multiply", task: "math", config: `{"operation": "multiply", "values": [2, 3, 4]}`, want: `{"value": 24}`},
+		{name: "math divide with precision", task: "math", config: `{"operation": "divide", "values": [10, 3], "precision": 2}`, want: `{"value": 3.33}`},
+		{name: "math modulo", task: "math", config: `{"operation": "modulo", "values": [10, 4]}`, want: `{"value": 2}`},
+		{name: "math min", task: "math", config: `{"operation": "min", "values": [3, -1, 2]}`, want: `{"value": -1}`},
+		{name: "math max", task: "math", config: `{"operation": "max", "values": [3, -1, 2]}`, want: `{"value": 3}`},
+		{name: "math abs", task: "math", config: `{"operation": "abs", "values": [-4]}`, want: `{"value": 4}`},
+		{name: "math floor", task: "math", config: `{"operation": "floor", "values": [4.7]}`, want: `{"value": 4}`},
+		{name: "math ceil", task: "math", config: `{"operation": "ceil", "values": [4.2]}`, want: `{"value": 5}`},
+		{name: "math round", task: "math", config: `{"operation": "round", "values": [" 4.5 "]}`, want: `{"value": 5}`},
+		{name: "math divide by zero", task: "math", config: `{"operation": "divide", "values": [1, 0]}`, wantErr: "division by zero"},
+		{name: "math modulo by zero", task: "math", config: `{"operation": "modulo", "values": [1, "0"]}`, wantErr: "division by zero"},
+		{name: "math no values", task: "math", config: `{"operation": "add", "values": []}`, wantErr: "at least one value"},
+		{name: "math not a number", task: "math", config: `{"operation": "add", "values": [1, "two"]}`, wantErr: `"two" is not a number`},
+		{name: "math object value", task: "math", config: `{"operation": "add", "values": [{}]}`, wantErr: "is not a number"},
+		{name: "math single value operation", task: "math", config: `{"operation": "abs", "values": [1, 2]}`, wantErr: "takes a single value"},
+		{name: "math unknown operation", task: "math", config: `{"operation": "power", "values": [2]}`, wantErr: "unknown operation"},
+
+		{
+			name:   "date-parse rfc1123 in a timezone",
+			task:   "date-parse",
+			config: `{"input": "Fri, 1 Mar 2024 08:00:00 +0000", "timezone": "Europe/Sofia"}`,
+			want: `{"value": "2024-03-01T10:00:00+02:00", "unix": 1709280000, "year": 2024, "month": 3, "day": 1,
+				"hour": 10, "minute": 0, "second": 0, "weekday": "friday"}`,
+		},
+		{
+			name:   "date-parse unix seconds",
+			task:   "date-parse",
+			config: `{"input": 1709280000}`,
+			want: `{"value": "2024-03-01T08:00:00Z", "unix": 1709280000, "year": 2024, "month": 3, "day": 1,
+				"hour": 8, "minute": 0, "second": 0, "weekday": "friday"}`,
+		},
+		{
+			name:   "date-parse without zone is in the timezone",
+			task:   "date-parse",
+			config: `{"input": "2024-07-01 09:30", "timezone": "Europe/Sofia"}`,
+			want: `{"value": "2024-07-01T09:30:00+03:00", "unix": 1719815400, "year": 2024, "month": 7, "day": 1,
+				"hour": 9, "minute": 30, "second": 0, "weekday": "monday"}`,
+		},
+		{
+			name:   "date-parse layout",
+			task:   "date-parse",
+			config: `{"input": "01/03/2024", "layout": "02/01/2006"}`,
+			want: `{"value": "2024-03-01T00:00:00Z", "unix": 1709251200, "year": 2024, "month": 3, "day": 1,
+				"hour": 0, "minute": 0, "second": 0, "weekday": "friday"}`,
+		},
+		{name: "date-parse unparsable", task: "date-parse", config: `{"input": "next tuesday"}`, wantErr: "cannot parse"},
+		{name: "date-parse wrong layout", task: "date-parse", config: `{"input": "2024-03-01", "layout": "date-time"}`, wantErr: "cannot parse"},
+		{name: "date-parse empty", task: "date-parse", config: `{"input": " "}`, wantErr: "empty date"},
+		{name: "date-parse unknown timezone", task: "date-parse", config: `{"input": "2024-03-01", "timezone": "Mars/Olympus"}`, wantErr: "unknown timezone"},
+
+		{name: "date-format default", task: "date-format", config: `{"input": "2024-03-01T10:00:00+02:00"}`, want: `{"value": "2024-03-01T08:00:00Z"}`},
+		{name: "date-format date", task: "date-format", config: `{"input": "2024-03-01T23:30:00Z", "format": "date", "timezone": "Europe/Sofia"}`, want: `{"value": "2024-03-02"}`},
+		{name: "date-format datetime", task: "date-format", config: `{"input": "2024-03-01T10:00:00Z", "format": "datetime"}`, want: `{"value": "2024-03-01 10:00:00"}`},
+		{name: "date-format unix", task: "date-format", config: `{"input": "2024-03-01T08:00:00Z", "format": "unix"}`, want: `{"value": 1709280000}`},
+		{name: "date-format unix_ms", task: "date-format", config: `{"input": "2024-03-01T08:00:00Z", "format": "unix_ms"}`, want: `{"value": 1709280000000}`},
+		{name: "date-format go layout", task: "date-format", config: `{"input": "2024-03-01", "format": "Jan 2, 2006"}`, want: `{"value": "Mar 1, 2024"}`},
+		{name: "date-format input layout", task: "date-format", config: `{"input": "01.03.2024", "input_layout": "02.01.2006", "format": "date"}`, want: `{"value": "2024-03-01"}`},
+		{name: "date-format unparsable", task: "date-format", config: `{"input": "soon", "format": "date"}`, wantErr: "cannot parse"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transform, ok := transformers[test.task]
+			if !ok {
+				t.Fatalf("no transformer %s", test.task)
+			}
+			output, err := transform([]byte(test.config))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("%s returned %v, %v, want an error containing %q", test.task, output, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s returned %v", test.task, err)
+			}
+
+			// Compared as JSON, which is how the output is stored
+			encoded, err := json.Marshal(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			json.Unmarshal(encoded, &got)
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatalf("invalid want: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s = %s, want %s", test.task, encoded, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches {{ path }} placeholders, e.g. {{trigger.email_subject}} or {{node-1.items[0].id}}
+var variablePattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+type VariableError struct {
+	Variable string
+	Reason   string
+}
+
+func (err VariableError) Error() string {
+	return fmt.Sprintf("cannot resolve {{%s}}: %s", err.Variable, err.Reason)
+}
+
+// resolveVariables replaces every placeholder inside the string values of a JSON config
+// with data from the execution state. The config is decoded and re-encoded, so the values
+// never break the JSON, no matter what quotes or newlines they contain.
+// '{"subject": "Hello {{trigger.name}}"}' -> '{"subject": "Hello Bob"}'
+func resolveVariables(templateJSON string, data map[string]interface{}) (string, error) {
+	if strings.TrimSpace(templateJSON) == "" {
+		return "{}", nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(templateJSON))
+	// Keep numbers as they are written instead of turning them into float64
+	decoder.UseNumber()
+
+	var config interface{}
+	if err := decoder.Decode(&config); err != nil {
+		return "", fmt.Errorf("invalid config JSON: %v", err)
+	}
+
+	resolved, err := resolveValue(config, data)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// Emails and urls should stay readable for the workers
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(resolved); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func resolveValue(value interface{}, data map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolvedItem, err := resolveValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = resolvedItem
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			resolvedItem, err := resolveValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = resolvedItem
+		}
+		return resolved, nil
+	case string:
+		return resolveString(v, data)
+	default:
+		return v, nil
+	}
+}
+
+func resolveString(s string, data map[string]interface{}) (interface{}, error) {
+	matches := variablePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// "{{trigger.labels}}" keeps the type of the referenced value (array, number, object...)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return lookupVariable(s[matches[0][2]:matches[0][3]], data)
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		builder.WriteString(s[last:match[0]])
+		value, err := lookupVariable(s[match[2]:match[3]], data)
+		if err != nil {
+			return nil, err
+		}
+		text, err := stringifyValue(value)
+		if err != nil {
+			return nil, err
+		}
+		builder.WriteString(text)
+		last = match[1]
+	}
+	builder.WriteString(s[last:])
+	return builder.String(), nil
+}
+
+func lookupVariable(path string, data map[string]interface{}) (interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, VariableError{Variable: path, Reason: err.Error()}
+	}
+
+	var current interface{} = data
+	for i, segment := range segments {
+		traversed := formatPath(segments[:i])
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				if traversed == "" {
+					return nil, VariableError{Variable: path, Reason: fmt.Sprintf("no data named %q in the execution", segment)}
+				}
+				return nil, VariableError{Variable: path, Reason: fmt.Sprintf("key %q not found in %s", segment, traversed)}
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, VariableError{Variable: path, Reason: fmt.Sprintf("%s is an array, %q is not an index", traversed, segment)}
+			}
+			if index < 0 || index >= len(v) {
+				return nil, VariableError{Variable: path, Reason: fmt.Sprintf("index %d out of range for %s (length %d)", index, traversed, len(v))}
+			}
+			current = v[index]
+		case nil:
+			return nil, VariableError{Variable: path, Reason: fmt.Sprintf("%s is null", traversed)}
+		default:
+			return nil, VariableError{Variable: path, Reason: fmt.Sprintf("%s is a %s and has no field %q", traversed, jsonTypeName(v), segment)}
+		}
+	}
+	return current, nil
+}
+
+// parsePath splits "a.b[0].c" (or "a.b.0.c") into ["a", "b", "0", "c"]
+func parsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty variable")
+	}
+
+	segments := make([]string, 0)
+	for _, part := range strings.Split(path, ".") {
+		name, rest, hasIndex := strings.Cut(part, "[")
+		if name == "" && (!hasIndex || len(segments) == 0) {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		if name != "" {
+			segments = append(segments, name)
+		}
+		for hasIndex {
+			var index string
+			index, rest, hasIndex = strings.Cut(rest, "]")
+			if !hasIndex {
+				return nil, fmt.Errorf("missing ] in %q", part)
+			}
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("invalid array index %q", index)
+			}
+			segments = append(segments, index)
+
+			if rest == "" {
+				break
+			}
+			if !strings.HasPrefix(rest, "[") {
+				return nil, fmt.Errorf("unexpected %q after ]", rest)
+			}
+			rest = rest[1:]
+		}
+	}
+	return segments, nil
+}
+
+func formatPath(segments []string) string {
+	var builder strings.Builder
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil && builder.Len() > 0 {
+			builder.WriteString("[" + segment + "]")
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString(".")
+		}
+		builder.WriteString(segment)
+	}
+	return builder.String()
+}
+
+func stringifyValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool, int, int64:
+		return fmt.Sprint(v), nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64, int, int64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,149 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "trigger", want: []string{"trigger"}},
+		{path: "trigger.email_subject", want: []string{"trigger", "email_subject"}},
+		{path: "node-1.items[0].id", want: []string{"node-1", "items", "0", "id"}},
+		{path: "node-1.items.0.id", want: []string{"node-1", "items", "0", "id"}},
+		{path: "matrix[1][2]", want: []string{"matrix", "1", "2"}},
+		{path: "", wantErr: true},
+		{path: "trigger..name", wantErr: true},
+		{path: "trigger.", wantErr: true},
+		{path: "[0]", wantErr: true},
+		{path: "items[0", wantErr: true},
+		{path: "items[a]", wantErr: true},
+		{path: "items[0]x", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := parsePath(test.path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parsePath(%q) = %v, want an error", test.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePath(%q) returned %v", test.path, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parsePath(%q) = %v, want %v", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLookupVariable(t *testing.T) {
+	data := map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"name":    "Bob",
+			"count":   json.Number("3"),
+			"missing": nil,
+			"labels":  []interface{}{"inbox", "work"},
+			"items": []interface{}{
+				map[string]interface{}{"id": "a1"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", path: "trigger.name", want: "Bob"},
+		{name: "number", path: "trigger.count", want: json.Number("3")},
+		{name: "null", path: "trigger.missing", want: nil},
+		{name: "array", path: "trigger.labels", want: []interface{}{"inbox", "work"}},
+		{name: "index", path: "trigger.labels[1]", want: "work"},
+		{name: "dotted index", path: "trigger.labels.0", want: "inbox"},
+		{name: "field of array item", path: "trigger.items[0].id", want: "a1"},
+		{name: "unknown root", path: "node-9.output", wantErr: true},
+		{name: "unknown key", path: "trigger.subject", wantErr: true},
+		{name: "index out of range", path: "trigger.labels[2]", wantErr: true},
+		{name: "key on array", path: "trigger.labels.first", wantErr: true},
+		{name: "field of null", path: "trigger.missing.id", wantErr: true},
+		{name: "field of string", path: "trigger.name.first", wantErr: true},
+		{name: "invalid path", path: "trigger..name", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := lookupVariable(test.path, data)
+			if test.wantErr {
+				var variableErr VariableError
+				if !errors.As(err, &variableErr) {
+					t.Fatalf("lookupVariable(%q) = %v, %v, want a VariableError", test.path, got, err)
+				}
+				if variableErr.Variable != test.path {
+					t.Errorf("VariableError.Variable = %q, want %q", variableErr.Variable, test.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookupVariable(%q) returned %v", test.path, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("lookupVariable(%q) = %#v, want %#v", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveVariables(t *testing.T) {
+	data := map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"name":   "Bob \"B\" <bob@example.com>",
+			"count":  json.Number("3"),
+			"labels": []interface{}{"inbox", "work"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "empty config", template: "", want: "{}"},
+		{name: "no placeholders", template: `{"a": 1.50}`, want: `{"a":1.50}`},
+		{name: "embedded", template: `{"subject": "Hi {{trigger.name}}"}`, want: `{"subject":"Hi Bob \"B\" <bob@example.com>"}`},
+		{name: "whole value keeps type", template: `{"labels": "{{ trigger.labels }}"}`, want: `{"labels":["inbox","work"]}`},
+		{name: "embedded array", template: `{"text": "{{trigger.labels}} x{{trigger.count}}"}`, want: `{"text":"[\"inbox\",\"work\"] x3"}`},
+		{name: "nested", template: `{"to": ["{{trigger.labels[0]}}"]}`, want: `{"to":["inbox"]}`},
+		{name: "missing variable", template: `{"a": "{{trigger.subject}}"}`, wantErr: true},
+		{name: "invalid JSON", template: `{"a": `, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolveVariables(test.template, data)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("resolveVariables(%q) = %q, want an error", test.template, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveVariables(%q) returned %v", test.template, err)
+			}
+			if got != test.want {
+				t.Errorf("resolveVariables(%q) = %q, want %q", test.template, got, test.want)
+			}
+		})
+	}
+}
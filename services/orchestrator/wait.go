@@ -0,0 +1,211 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+// Transformer tasks which pause the execution instead of holding a goroutine:
+//
+//	delay:      {"duration": "2h30m"} or {"days": 1, "hours": 2, "minutes": 0, "seconds": 0}
+//	wait-until: {"at": "2024-03-04 09:00", "timezone": "Europe/Sofia"}
+//	            {"weekday": "monday", "time": "09:00", "timezone": "Europe/Sofia"} -> next Monday 09:00
+//	            {"time": "09:00"} -> the next 09:00
+//
+// The first time the node is reached its step is stored as waiting with the time to resume at,
+// the execution is paused and queued for that time. When it runs again the step finishes.
+
+// WaitingError is returned when the execution has to pause until a wait node is due
+type WaitingError struct {
+	Until time.Time
+}
+
+func (err WaitingError) Error() string {
+	return fmt.Sprintf("waiting until %s", err.Until.Format(time.RFC3339))
+}
+
+type waitConfig struct {
+	// delay
+	Duration string  `json:"duration"`
+	Days     float64 `json:"days"`
+	Hours    float64 `json:"hours"`
+	Minutes  float64 `json:"minutes"`
+	Seconds  float64 `json:"seconds"`
+
+	// wait-until
+	At       interface{} `json:"at"`
+	Weekday  string      `json:"weekday"`
+	Time     string      `json:"time"`
+	Timezone string      `json:"timezone"`
+}
+
+type waitOutput struct {
+	ResumeAt  time.Time  `json:"resume_at"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+}
+
+func isWaitNode(node models.WorkflowNode) bool {
+	return node.Type == models.Transformer && (node.TaskName == models.DelayTask || node.TaskName == models.WaitUntilTask)
+}
+
+// executeWait finishes the step of a wait node whose time has come, or stores it as waiting and
+// returns a WaitingError. previous is the step stored when the node was first reached, nil the first time
+func (orchestrator *OrchestratorService) executeWait(node models.WorkflowNode, state *ExecutionContext, previous *models.ExecutionStep) (interface{}, error) {
+	stepRepo := repositories.ExecutionStep{Db: orchestrator.Db}
+
+	var step *models.ExecutionStep
+	var output waitOutput
+	if previous != nil && previous.Status == models.Waiting {
+		// The resume time was fixed when the node was reached, "next Monday" is not computed again
+		step = previous
+		if err := json.Unmarshal([]byte(step.Output), &output); err != nil {
+			return nil, fmt.Errorf("invalid waiting step of node %s: %v", node.DisplayId, err)
+		}
+	} else {
+		step = &models.ExecutionStep{
+			ExecutionId: state.ExecutionID,
+			NodeId:      node.Id,
+			DisplayId:   node.DisplayId,
+			Status:      models.Waiting,
+		}
+
+		resolvedConfig, err := resolveVariables(node.Config, state.Snapshot())
+		if err != nil {
+			return nil, orchestrator.storeFailedStep(step, fmt.Errorf("variable resolution failed for node %s: %w", node.DisplayId, err))
+		}
+		step.Input = resolvedConfig
+
+		resumeAt, err := waitResumeTime(node.TaskName, resolvedConfig, time.Now())
+		if err != nil {
+			return nil, orchestrator.storeFailedStep(step, fmt.Errorf("node %s: %w", node.DisplayId, err))
+		}
+		output.ResumeAt = resumeAt.UTC()
+
+		encoded, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		step.Output = string(encoded)
+		if err := stepRepo.Insert(step); err != nil {
+			return nil, fmt.Errorf("failed to store step: %v", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	// A dry run shows when the execution would continue without waiting for it
+	if now.Before(output.ResumeAt) && !state.DryRun {
+		return nil, WaitingError{Until: output.ResumeAt}
+	}
+
+	output.ResumedAt = &now
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	step.Output = string(encoded)
+	step.Status = models.Succeeded
+	if err := stepRepo.Finish(step); err != nil {
+		return nil, fmt.Errorf("failed to store step: %v", err)
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	state.Set(node.DisplayId, data)
+	return data, nil
+}
+
+// storeFailedStep stores the step of a node which failed before its step was stored
+func (orchestrator *OrchestratorService) storeFailedStep(step *models.ExecutionStep, err error) error {
+	stepRepo := repositories.ExecutionStep{Db: orchestrator.Db}
+	step.Status = models.Failed
+	step.Error = err.Error()
+	step.ErrorClass = errorClassOf(err)
+	if insertErr := stepRepo.Insert(step); insertErr != nil {
+		return fmt.Errorf("failed to store step: %v", insertErr)
+	}
+	if finishErr := stepRepo.Finish(step); finishErr != nil {
+		return fmt.Errorf("failed to store step: %v", finishErr)
+	}
+	return err
+}
+
+// waitResumeTime computes when a wait node which is reached at now lets the execution continue
+func waitResumeTime(task string, configJSON string, now time.Time) (time.Time, error) {
+	var config waitConfig
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return time.Time{}, fmt.Errorf("invalid config: %v", err)
+	}
+
+	switch task {
+	case models.DelayTask:
+		delay := time.Duration((config.Days*24*3600 + config.Hours*3600 + config.Minutes*60 + config.Seconds) * float64(time.Second))
+		if config.Duration != "" {
+			parsed, err := time.ParseDuration(config.Duration)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid duration %q", config.Duration)
+			}
+			delay += parsed
+		}
+		if delay <= 0 {
+			return time.Time{}, fmt.Errorf("delay must be positive")
+		}
+		return now.Add(delay), nil
+	case models.WaitUntilTask:
+		loc, err := loadTimezone(config.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if config.At != nil {
+			return parseDate(config.At, "", loc)
+		}
+		return nextClockTime(now, config.Weekday, config.Time, loc)
+	default:
+		return time.Time{}, fmt.Errorf("unknown wait task %s", task)
+	}
+}
+
+// nextClockTime returns the first time after now when the clock in loc shows clock ("15:04"),
+// on the given weekday if it is set
+func nextClockTime(now time.Time, weekday string, clock string, loc *time.Location) (time.Time, error) {
+	if clock == "" {
+		clock = "00:00"
+	}
+	parsedClock, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+
+	targetDay := -1
+	if weekday != "" {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String(), weekday) {
+				targetDay = int(day)
+			}
+		}
+		if targetDay == -1 {
+			return time.Time{}, fmt.Errorf("invalid weekday %q", weekday)
+		}
+	}
+
+	local := now.In(loc)
+	// A week and a day covers every weekday, including today's after the clock time passed
+	for days := 0; days <= 7; days++ {
+		date := local.AddDate(0, 0, days)
+		candidate := time.Date(date.Year(), date.Month(), date.Day(), parsedClock.Hour(), parsedClock.Minute(), 0, 0, loc)
+		if !candidate.After(now) {
+			continue
+		}
+		if targetDay != -1 && int(candidate.Weekday()) != targetDay {
+			continue
+		}
+		return candidate, nil
+	}
+	return time.Time{}, fmt.Errorf("no time found for %s %s", weekday, clock)
+}
@@ -0,0 +1,160 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+const (
+	defaultWorkerConcurrency = 4
+	defaultLeaseDuration     = 30 * time.Second
+	defaultPollInterval      = time.Second
+	// A job which keeps killing its worker is given up on instead of being leased forever
+	maxJobAttempts = 5
+)
+
+// Worker takes executions from the orchestrator's queue and runs them
+type Worker struct {
+	Orchestrator *OrchestratorService
+	// Unique per process, stored on the leased jobs. Defaults to hostname-pid
+	Id string
+	// How many executions run at the same time, defaults to defaultWorkerConcurrency
+	Concurrency   int
+	LeaseDuration time.Duration
+	PollInterval  time.Duration
+}
+
+// Run queues the unfinished executions again and then runs jobs until the context is cancelled
+func (worker *Worker) Run(ctx context.Context) error {
+	if worker.Id == "" {
+		hostname, _ := os.Hostname()
+		worker.Id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if worker.Concurrency <= 0 {
+		worker.Concurrency = defaultWorkerConcurrency
+	}
+	if worker.LeaseDuration <= 0 {
+		worker.LeaseDuration = defaultLeaseDuration
+	}
+	if worker.PollInterval <= 0 {
+		worker.PollInterval = defaultPollInterval
+	}
+
+	if err := worker.resumeUnfinished(ctx); err != nil {
+		return fmt.Errorf("failed to resume unfinished executions: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < worker.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// resumeUnfinished makes sure every execution which did not finish before a restart has a job.
+// With the MySQL queue they usually still have one, which is leased again once its lease expires
+func (worker *Worker) resumeUnfinished(ctx context.Context) error {
+	executionRepo := repositories.Execution{Db: worker.Orchestrator.Db}
+	executions, err := executionRepo.FindUnfinished()
+	if err != nil {
+		return err
+	}
+	for _, execution := range executions {
+		if err := worker.Orchestrator.Queue.EnqueueIfMissing(ctx, execution.Id); err != nil {
+			return err
+		}
+	}
+	if len(executions) > 0 {
+		log.Printf("Worker %s: %d unfinished executions queued", worker.Id, len(executions))
+	}
+	return nil
+}
+
+func (worker *Worker) loop(ctx context.Context) {
+	queue := worker.Orchestrator.Queue
+	for ctx.Err() == nil {
+		job, err := queue.Lease(ctx, worker.Id, worker.LeaseDuration)
+		if err != nil {
+			log.Printf("Worker %s: failed to lease job: %v", worker.Id, err)
+		}
+		if err != nil || job == nil {
+			sleepContext(ctx, worker.PollInterval)
+			continue
+		}
+		worker.runJob(ctx, job)
+	}
+}
+
+func (worker *Worker) runJob(ctx context.Context, job *Job) {
+	queue := worker.Orchestrator.Queue
+
+	if job.Attempts > maxJobAttempts {
+		log.Printf("Worker %s: giving up on execution %d after %d attempts", worker.Id, job.ExecutionId, job.Attempts-1)
+		executionRepo := repositories.Execution{Db: worker.Orchestrator.Db}
+		if err := executionRepo.Finish(job.ExecutionId, models.Failed, fmt.Sprintf("abandoned after %d attempts", job.Attempts-1)); err != nil {
+			log.Printf("Failed to store status of execution %d: %v", job.ExecutionId, err)
+		}
+		if err := queue.Complete(ctx, job); err != nil {
+			log.Printf("Worker %s: failed to complete job %d: %v", worker.Id, job.Id, err)
+		}
+		return
+	}
+
+	// The execution is stopped if the lease is lost, another worker may already be running it
+	execCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		worker.heartbeat(execCtx, cancel, job)
+	}()
+
+	if err := worker.Orchestrator.ExecuteWorkflow(execCtx, job.ExecutionId); err != nil {
+		log.Printf("Execution %d failed: %v", job.ExecutionId, err)
+	}
+	cancel()
+	<-heartbeatDone
+
+	// The process is shutting down, the job is resumed by the next lease
+	if ctx.Err() != nil {
+		return
+	}
+	if err := queue.Complete(ctx, job); err != nil {
+		log.Printf("Worker %s: failed to complete job %d: %v", worker.Id, job.Id, err)
+	}
+}
+
+func (worker *Worker) heartbeat(ctx context.Context, cancel context.CancelFunc, job *Job) {
+	ticker := time.NewTicker(worker.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := worker.Orchestrator.Queue.Heartbeat(ctx, job, worker.LeaseDuration)
+			if errors.Is(err, ErrLeaseLost) {
+				log.Printf("Worker %s: lost the lease of execution %d, stopping it", worker.Id, job.ExecutionId)
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Printf("Worker %s: heartbeat of job %d failed: %v", worker.Id, job.Id, err)
+			}
+		}
+	}
+}
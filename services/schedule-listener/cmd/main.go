@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/workers"
+)
+
+// Cron expressions are per minute, checking more often keeps the runs close to their time
+const pollInterval = 15 * time.Second
+
+// A run which is fired later than this after its time was missed, e.g. the listener was down
+const lateAfter = time.Minute
+
+// How many fire times are looked at in one poll. The rest is picked up by the next poll
+const maxDueRuns = 100000
+
+type ScheduleListener struct {
+	Db           *sql.DB
+	Orchestrator pb.OrchestratorClient
+	// Replaces the db for the checkpoints in tests
+	checkpoints checkpointStore
+}
+
+// checkpointStore keeps the time of the last run of each node, the db outside of tests
+type checkpointStore interface {
+	SetLastRun(nodeId string, lastRunAt time.Time)
+	// ClearLastRun forgets the last run, the node starts counting again when it is seen next
+	ClearLastRun(nodeId string)
+}
+
+type dbCheckpointStore struct {
+	Db *sql.DB
+}
+
+type ScheduleJob struct {
+	NodeId     string
+	WorkflowId int
+	Active     bool
+	Config     string
+	// Time of the last run which was fired or skipped, not set before the node was seen the first time
+	LastRunAt sql.NullTime
+}
+
+// A run to fire. With the once policy a single run stands for several missed ones
+type scheduledRun struct {
+	ScheduledAt time.Time
+	Late        bool
+	MissedRuns  int
+}
+
+// The trigger payload of a schedule listener
+type schedulePayload struct {
+	ScheduledAt string `json:"scheduled_at"`
+	FiredAt     string `json:"fired_at"`
+	Timezone    string `json:"timezone"`
+	// The run fires later than scheduled because it was missed
+	Late bool `json:"late"`
+	// With catch_up once, how many missed runs this one stands for
+	MissedRuns int `json:"missed_runs"`
+}
+
+func (l *ScheduleListener) Poll() {
+	// The last run of a schedule is kept in trigger_states.last_check_at. Nodes of inactive
+	// workflows are only read until their last run is cleared
+	rows, err := l.Db.Query(`
+		SELECT n.id, n.workflow_id, workflows.active, n.config, t.last_check_at
+		FROM workflow_nodes n
+		JOIN workflows ON n.workflow_id = workflows.id
+		LEFT JOIN trigger_states t ON n.id = t.node_id
+		WHERE n.type = 0
+		  AND n.service_name = ?
+		  AND (workflows.active = TRUE OR t.last_check_at IS NOT NULL)
+	`, models.ScheduleServiceName)
+	if err != nil {
+		log.Printf("DB Error: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	jobs := make([]ScheduleJob, 0)
+	for rows.Next() {
+		var job ScheduleJob
+		if err := rows.Scan(&job.NodeId, &job.WorkflowId, &job.Active, &job.Config, &job.LastRunAt); err != nil {
+			log.Printf("Scan error: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		l.CheckSchedule(job, now)
+	}
+}
+
+// CheckSchedule fires the runs of the node which are due at now according to its catch-up policy
+func (l *ScheduleListener) CheckSchedule(job ScheduleJob, now time.Time) {
+	// The last run of an inactive workflow is forgotten and a new node starts counting from now,
+	// so activating a workflow doesn't catch up on the time it was off
+	if !job.Active {
+		l.checkpointStore().ClearLastRun(job.NodeId)
+		return
+	}
+	if !job.LastRunAt.Valid {
+		l.checkpointStore().SetLastRun(job.NodeId, now)
+		return
+	}
+
+	schedule, err := models.ParseScheduleConfig(job.Config)
+	if err != nil {
+		log.Printf("Invalid schedule of node %s: %v", job.NodeId, err)
+		return
+	}
+
+	due := dueRuns(schedule, job.LastRunAt.Time, now)
+	if len(due) == 0 {
+		return
+	}
+
+	for _, run := range planRuns(schedule.Config.CatchUp, due, now) {
+		if err := l.fire(job, schedule, run, now); err != nil {
+			log.Printf("Failed to trigger workflow %d for node %s: %v", job.WorkflowId, job.NodeId, err)
+			// The runs from here on are fired by the next poll
+			return
+		}
+		l.checkpointStore().SetLastRun(job.NodeId, run.ScheduledAt)
+	}
+	// Skipped runs are done too
+	l.checkpointStore().SetLastRun(job.NodeId, due[len(due)-1])
+}
+
+// dueRuns returns the fire times after last which are not after now, oldest first
+func dueRuns(schedule *models.Schedule, last time.Time, now time.Time) []time.Time {
+	due := make([]time.Time, 0)
+	for next := schedule.Next(last); !next.IsZero() && !next.After(now) && len(due) < maxDueRuns; next = schedule.Next(next) {
+		due = append(due, next)
+	}
+	return due
+}
+
+// planRuns picks the runs to fire out of the due ones. Runs which are not late always fire,
+// the missed ones are dropped, merged into one run or fired up to models.MaxCatchUpRuns
+func planRuns(catchUp string, due []time.Time, now time.Time) []scheduledRun {
+	missed := make([]time.Time, 0)
+	runs := make([]scheduledRun, 0)
+	for _, scheduledAt := range due {
+		if now.Sub(scheduledAt) > lateAfter {
+			missed = append(missed, scheduledAt)
+		} else {
+			runs = append(runs, scheduledRun{ScheduledAt: scheduledAt})
+		}
+	}
+	if len(missed) == 0 {
+		return runs
+	}
+
+	caughtUp := make([]scheduledRun, 0)
+	switch catchUp {
+	case models.CatchUpOnce:
+		latest := missed[len(missed)-1]
+		caughtUp = append(caughtUp, scheduledRun{ScheduledAt: latest, Late: true, MissedRuns: len(missed)})
+	case models.CatchUpAll:
+		// The most recent ones
+		if len(missed) > models.MaxCatchUpRuns {
+			log.Printf("Dropping %d missed runs, at most %d are caught up", len(missed)-models.MaxCatchUpRuns, models.MaxCatchUpRuns)
+			missed = missed[len(missed)-models.MaxCatchUpRuns:]
+		}
+		for _, scheduledAt := range missed {
+			caughtUp = append(caughtUp, scheduledRun{ScheduledAt: scheduledAt, Late: true, MissedRuns: 1})
+		}
+	}
+	return append(caughtUp, runs...)
+}
+
+func (l *ScheduleListener) fire(job ScheduleJob, schedule *models.Schedule, run scheduledRun, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(schedulePayload{
+		ScheduledAt: run.ScheduledAt.In(schedule.Location).Format(time.RFC3339),
+		FiredAt:     now.In(schedule.Location).Format(time.RFC3339),
+		Timezone:    schedule.Location.String(),
+		Late:        run.Late,
+		MissedRuns:  run.MissedRuns,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = l.Orchestrator.TriggerWorkflow(ctx, &pb.TriggerRequest{
+		ListenerNodeId: job.NodeId,
+		InitialPayload: string(payload),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Fired node %s for %s", job.NodeId, run.ScheduledAt.In(schedule.Location).Format(time.RFC3339))
+	return nil
+}
+
+func (l *ScheduleListener) checkpointStore() checkpointStore {
+	if l.checkpoints != nil {
+		return l.checkpoints
+	}
+	return dbCheckpointStore{Db: l.Db}
+}
+
+func (store dbCheckpointStore) SetLastRun(nodeId string, lastRunAt time.Time) {
+	// Upsert
+	query := `
+		INSERT INTO trigger_states (node_id, last_check_at)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE last_check_at = VALUES(last_check_at);
+	`
+	_, err := store.Db.Exec(query, nodeId, lastRunAt.UTC())
+	if err != nil {
+		log.Printf("Failed to update checkpoint for %s: %v", nodeId, err)
+	}
+}
+
+func (store dbCheckpointStore) ClearLastRun(nodeId string) {
+	_, err := store.Db.Exec("UPDATE trigger_states SET last_check_at = NULL WHERE node_id = ?", nodeId)
+	if err != nil {
+		log.Printf("Failed to clear checkpoint for %s: %v", nodeId, err)
+	}
+}
+
+func main() {
+	db, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/was_api?parseTime=true&loc=UTC")
+	if err != nil {
+		log.Fatal("Could not connect to db", err)
+		return
+	}
+
+	orchConn, _ := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	defer orchConn.Close()
+
+	// The listener describes the schedule task for the catalog, it runs no actions
+	grpcListener, err := net.Listen("tcp", ":50057")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterTaskWorkerServer(grpcServer, &ScheduleServer{})
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	go workers.Register(workers.Getenv("ORCHESTRATOR_ADDRESS", "localhost:50051"), &pb.RegisterWorkerRequest{
+		ServiceName: models.ScheduleServiceName,
+		Address:     workers.Getenv("WORKER_ADDRESS", "localhost:50057"),
+		Tasks:       []string{},
+	})
+
+	listener := &ScheduleListener{
+		Db:           db,
+		Orchestrator: pb.NewOrchestratorClient(orchConn),
+	}
+
+	log.Printf("Schedule Listener started. Checking every %v...\n", pollInterval)
+
+	ticker := time.NewTicker(pollInterval)
+	for range ticker.C {
+		listener.Poll()
+	}
+}
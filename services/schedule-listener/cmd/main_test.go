@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// fakeOrchestrator records the triggered payloads, the trigger number failAt fails
+type fakeOrchestrator struct {
+	pb.OrchestratorClient
+	fired  []schedulePayload
+	failAt int
+}
+
+func (o *fakeOrchestrator) TriggerWorkflow(ctx context.Context, req *pb.TriggerRequest, opts ...grpc.CallOption) (*pb.TriggerResponse, error) {
+	if o.failAt == len(o.fired)+1 {
+		return nil, errors.New("orchestrator unavailable")
+	}
+	var payload schedulePayload
+	if err := json.Unmarshal([]byte(req.InitialPayload), &payload); err != nil {
+		return nil, err
+	}
+	o.fired = append(o.fired, payload)
+	return &pb.TriggerResponse{}, nil
+}
+
+// fakeCheckpointStore records the stored last runs, a zero time for a cleared one
+type fakeCheckpointStore struct {
+	lastRuns []time.Time
+}
+
+func (store *fakeCheckpointStore) SetLastRun(nodeId string, lastRunAt time.Time) {
+	store.lastRuns = append(store.lastRuns, lastRunAt)
+}
+
+func (store *fakeCheckpointStore) ClearLastRun(nodeId string) {
+	store.lastRuns = append(store.lastRuns, time.Time{})
+}
+
+func mustParseSchedule(t *testing.T, config string) *models.Schedule {
+	t.Helper()
+	schedule, err := models.ParseScheduleConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestDueRuns(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		last   time.Time
+		now    time.Time
+		want   []time.Time
+	}{
+		{name: "interval", config: `{"interval_seconds": 3600}`, last: at(10, 0), now: at(13, 30), want: []time.Time{at(11, 0), at(12, 0), at(13, 0)}},
+		{name: "run at now is due", config: `{"interval_seconds": 3600}`, last: at(10, 0), now: at(11, 0), want: []time.Time{at(11, 0)}},
+		{name: "nothing due", config: `{"interval_seconds": 3600}`, last: at(10, 0), now: at(10, 59), want: []time.Time{}},
+		{
+			name:   "cron over a weekend",
+			config: `{"cron": "0 9 * * MON-FRI"}`,
+			// Friday to Monday
+			last: at(9, 0),
+			now:  at(9, 0).AddDate(0, 0, 3),
+			want: []time.Time{at(9, 0).AddDate(0, 0, 3)},
+		},
+		{
+			name:   "cron in a timezone",
+			config: `{"cron": "0 9 * * *", "timezone": "Europe/Sofia"}`,
+			last:   at(0, 0),
+			now:    at(12, 0),
+			want:   []time.Time{at(7, 0)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := dueRuns(mustParseSchedule(t, test.config), test.last, test.now)
+			if len(got) != len(test.want) {
+				t.Fatalf("dueRuns() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if !got[i].Equal(test.want[i]) {
+					t.Errorf("dueRuns()[%d] = %v, want %v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDueRunsLimit(t *testing.T) {
+	schedule := mustParseSchedule(t, `{"interval_seconds": 60}`)
+	last := at(0, 0)
+	got := dueRuns(schedule, last, last.Add(2*maxDueRuns*time.Minute))
+	if len(got) != maxDueRuns {
+		t.Fatalf("dueRuns() returned %d runs, want %d", len(got), maxDueRuns)
+	}
+	if want := last.Add(maxDueRuns * time.Minute); !got[len(got)-1].Equal(want) {
+		t.Errorf("last due run %v, want %v", got[len(got)-1], want)
+	}
+}
+
+func TestPlanRuns(t *testing.T) {
+	now := at(12, 0).Add(30 * time.Second)
+	due := []time.Time{at(9, 0), at(10, 0), at(11, 0), at(12, 0)}
+	onTime := scheduledRun{ScheduledAt: at(12, 0)}
+
+	manyMissed := make([]time.Time, 0, models.MaxCatchUpRuns+50)
+	for i := cap(manyMissed); i > 0; i-- {
+		manyMissed = append(manyMissed, at(12, 0).Add(-time.Duration(i)*time.Minute))
+	}
+	lastCaughtUp := make([]scheduledRun, 0, models.MaxCatchUpRuns)
+	for _, scheduledAt := range manyMissed[50:] {
+		lastCaughtUp = append(lastCaughtUp, scheduledRun{ScheduledAt: scheduledAt, Late: true, MissedRuns: 1})
+	}
+
+	tests := []struct {
+		name    string
+		catchUp string
+		due     []time.Time
+		want    []scheduledRun
+	}{
+		{name: "skip", catchUp: models.CatchUpSkip, due: due, want: []scheduledRun{onTime}},
+		{
+			name:    "once",
+			catchUp: models.CatchUpOnce,
+			due:     due,
+			want:    []scheduledRun{{ScheduledAt: at(11, 0), Late: true, MissedRuns: 3}, onTime},
+		},
+		{
+			name:    "all",
+			catchUp: models.CatchUpAll,
+			due:     due,
+			want: []scheduledRun{
+				{ScheduledAt: at(9, 0), Late: true, MissedRuns: 1},
+				{ScheduledAt: at(10, 0), Late: true, MissedRuns: 1},
+				{ScheduledAt: at(11, 0), Late: true, MissedRuns: 1},
+				onTime,
+			},
+		},
+		{name: "all keeps the most recent", catchUp: models.CatchUpAll, due: manyMissed, want: lastCaughtUp},
+		{name: "nothing missed", catchUp: models.CatchUpOnce, due: []time.Time{at(12, 0)}, want: []scheduledRun{onTime}},
+		{name: "only missed runs skipped", catchUp: models.CatchUpSkip, due: due[:3], want: []scheduledRun{}},
+		{name: "run within lateAfter is on time", catchUp: models.CatchUpSkip, due: []time.Time{now.Add(-lateAfter)}, want: []scheduledRun{{ScheduledAt: now.Add(-lateAfter)}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := planRuns(test.catchUp, test.due, now)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("planRuns() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	now := at(12, 0).Add(30 * time.Second)
+	hourly := `{"interval_seconds": 3600, "timezone": "Europe/Sofia"}`
+
+	tests := []struct {
+		name      string
+		job       ScheduleJob
+		failAt    int
+		wantFired []schedulePayload
+		// Zero for a cleared last run
+		wantLastRuns []time.Time
+	}{
+		{
+			name:         "inactive workflow forgets its last run",
+			job:          ScheduleJob{Config: hourly, LastRunAt: sql.NullTime{Time: at(9, 0), Valid: true}},
+			wantLastRuns: []time.Time{{}},
+		},
+		{
+			name:         "new node starts counting from now",
+			job:          ScheduleJob{Active: true, Config: hourly},
+			wantLastRuns: []time.Time{now},
+		},
+		{
+			name:         "invalid config",
+			job:          ScheduleJob{Active: true, Config: `{"interval_seconds": 1}`, LastRunAt: sql.NullTime{Time: at(9, 0), Valid: true}},
+			wantLastRuns: []time.Time{},
+		},
+		{
+			name:         "nothing due",
+			job:          ScheduleJob{Active: true, Config: hourly, LastRunAt: sql.NullTime{Time: at(12, 0), Valid: true}},
+			wantLastRuns: []time.Time{},
+		},
+		{
+			name: "missed runs caught up once",
+			job:  ScheduleJob{Active: true, Config: hourly, LastRunAt: sql.NullTime{Time: at(9, 0), Valid: true}},
+			wantFired: []schedulePayload{
+				{ScheduledAt: "2024-03-01T13:00:00+02:00", FiredAt: "2024-03-01T14:00:30+02:00", Timezone: "Europe/Sofia", Late: true, MissedRuns: 2},
+				{ScheduledAt: "2024-03-01T14:00:00+02:00", FiredAt: "2024-03-01T14:00:30+02:00", Timezone: "Europe/Sofia"},
+			},
+			wantLastRuns: []time.Time{at(11, 0), at(12, 0), at(12, 0)},
+		},
+		{
+			name:         "skipped runs are done",
+			job:          ScheduleJob{Active: true, Config: `{"interval_seconds": 3600, "catch_up": "skip"}`, LastRunAt: sql.NullTime{Time: at(9, 30), Valid: true}},
+			wantLastRuns: []time.Time{at(11, 30)},
+		},
+		{
+			name:   "fire failure leaves the rest to the next poll",
+			job:    ScheduleJob{Active: true, Config: hourly, LastRunAt: sql.NullTime{Time: at(9, 0), Valid: true}},
+			failAt: 2,
+			wantFired: []schedulePayload{
+				{ScheduledAt: "2024-03-01T13:00:00+02:00", FiredAt: "2024-03-01T14:00:30+02:00", Timezone: "Europe/Sofia", Late: true, MissedRuns: 2},
+			},
+			wantLastRuns: []time.Time{at(11, 0)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			orchestrator := &fakeOrchestrator{failAt: test.failAt}
+			checkpoints := &fakeCheckpointStore{lastRuns: []time.Time{}}
+			listener := &ScheduleListener{Orchestrator: orchestrator, checkpoints: checkpoints}
+			test.job.NodeId = "node-1"
+
+			listener.CheckSchedule(test.job, now)
+
+			if !reflect.DeepEqual(orchestrator.fired, test.wantFired) {
+				t.Errorf("fired %+v, want %+v", orchestrator.fired, test.wantFired)
+			}
+			if len(checkpoints.lastRuns) != len(test.wantLastRuns) {
+				t.Fatalf("stored last runs %v, want %v", checkpoints.lastRuns, test.wantLastRuns)
+			}
+			for i := range checkpoints.lastRuns {
+				if !checkpoints.lastRuns[i].Equal(test.wantLastRuns[i]) {
+					t.Errorf("stored last run %d = %v, want %v", i, checkpoints.lastRuns[i], test.wantLastRuns[i])
+				}
+			}
+		})
+	}
+}
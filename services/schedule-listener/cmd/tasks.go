@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// ScheduleServer only describes the schedule listener, there is nothing to execute
+type ScheduleServer struct {
+	pb.UnimplementedTaskWorkerServer
+}
+
+var taskDescriptions = []*pb.TaskDescription{
+	{
+		Name:        models.ScheduleTask,
+		NodeType:    "listener",
+		DisplayName: "On a schedule",
+		ConfigSchema: `{
+			"type": "object",
+			"properties": {
+				"cron": {"type": "string", "title": "Cron expression", "description": "e.g. 0 9 * * MON-FRI", "default": "0 9 * * MON-FRI"},
+				"interval_seconds": {"type": "integer", "title": "Interval in seconds", "description": "Instead of a cron expression, at least 60"},
+				"timezone": {"type": "string", "title": "Time zone", "description": "IANA name, e.g. Europe/Sofia", "default": "UTC"},
+				"catch_up": {"type": "string", "title": "Missed runs", "enum": ["skip", "once", "all"], "default": "once"}
+			},
+			"additionalProperties": false
+		}`,
+		OutputSchema: `{
+			"type": "object",
+			"properties": {
+				"scheduled_at": {"type": "string", "description": "RFC 3339 in the time zone of the schedule"},
+				"fired_at": {"type": "string", "description": "RFC 3339 in the time zone of the schedule"},
+				"timezone": {"type": "string"},
+				"late": {"type": "boolean", "description": "The run was missed and is caught up"},
+				"missed_runs": {"type": "integer"}
+			}
+		}`,
+	},
+}
+
+func (s *ScheduleServer) DescribeTasks(ctx context.Context, req *pb.DescribeTasksRequest) (*pb.DescribeTasksResponse, error) {
+	return &pb.DescribeTasksResponse{Tasks: taskDescriptions}, nil
+}
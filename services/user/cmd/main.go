@@ -84,7 +84,7 @@ func (s *UserServiceServer) Login(ctx context.Context, req *pb.LoginRequest) (*p
 func (s *UserServiceServer) GetCredentials(ctx context.Context, req *pb.GetCredentialsRequest) (*pb.GetCredentialsResponse, error) {
 	var credential models.Credential
 
-	err := s.DB.QueryRowContext(ctx, `SELECT * FROM credentials WHERE id = ?`, req.CredentialId).Scan(&credential.Id, &credential.ServiceName, &credential.UserId, &credential.AccessToken, &credential.RefreshToken, &credential.ExpiresAt)
+	err := s.DB.QueryRowContext(ctx, `SELECT * FROM credentials WHERE id = ? AND user_id = ?`, req.CredentialId, req.UserId).Scan(&credential.Id, &credential.ServiceName, &credential.UserId, &credential.AccessToken, &credential.RefreshToken, &credential.ExpiresAt)
 
 	if err != nil {
 		fmt.Println(err)
@@ -93,7 +93,7 @@ func (s *UserServiceServer) GetCredentials(ctx context.Context, req *pb.GetCrede
 
 	// If not expired for more than 5 mins
 	if time.Now().Add(5 * time.Minute).Before(credential.ExpiresAt) {
-		return &pb.GetCredentialsResponse{AccessToken: credential.AccessToken, Success: true}, nil
+		return &pb.GetCredentialsResponse{AccessToken: credential.AccessToken, Success: true, ServiceName: credential.ServiceName}, nil
 	}
 
 	// If expired, try to refresh
@@ -133,7 +133,7 @@ func (s *UserServiceServer) GetCredentials(ctx context.Context, req *pb.GetCrede
 		log.Printf("Failed to save new token: %v", err)
 	}
 
-	return &pb.GetCredentialsResponse{AccessToken: newToken.AccessToken, Success: true}, nil
+	return &pb.GetCredentialsResponse{AccessToken: newToken.AccessToken, Success: true, ServiceName: credential.ServiceName}, nil
 }
 
 func main() {
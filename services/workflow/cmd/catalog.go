@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/utils"
+)
+
+// validateNodeConfigs checks the listeners and actions against the tasks their workers describe.
+// Services without a worker which answered are not checked, the workflow can be saved while a worker is down
+func validateNodeConfigs(ctx context.Context, orchestrator pb.OrchestratorClient, nodes []*pb.NodeInput) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := orchestrator.GetCatalog(ctx, &pb.GetCatalogRequest{})
+	if err != nil {
+		log.Printf("Could not fetch the task catalog, skipping config validation: %v", err)
+		return nil
+	}
+
+	catalog := make(map[string]map[string]*pb.TaskDescription)
+	for _, service := range res.Services {
+		catalog[service.ServiceName] = make(map[string]*pb.TaskDescription)
+		for _, task := range service.Tasks {
+			catalog[service.ServiceName][task.Name] = task
+		}
+	}
+
+	for _, node := range nodes {
+		if (node.Type != "listener" && node.Type != "action") || node.ServiceName == models.CoreServiceName {
+			continue
+		}
+		tasks, ok := catalog[node.ServiceName]
+		if !ok {
+			continue
+		}
+		task, ok := tasks[node.TaskName]
+		if !ok || task.NodeType != node.Type {
+			return fmt.Errorf("node %s: service %s has no %s %s", node.DisplayId, node.ServiceName, node.Type, node.TaskName)
+		}
+		if err := utils.ValidateJSON(task.ConfigSchema, node.Config); err != nil {
+			return fmt.Errorf("invalid config of node %s: %v", node.DisplayId, err)
+		}
+	}
+	return nil
+}
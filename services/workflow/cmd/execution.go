@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultExecutionsPageSize = 20
+	maxExecutionsPageSize     = 100
+)
+
+type ExecutionServiceServer struct {
+	pb.UnimplementedExecutionServiceServer
+	Db *sql.DB
+}
+
+func (s *ExecutionServiceServer) ListExecutions(ctx context.Context, req *pb.ListExecutionsRequest) (*pb.ListExecutionsResponse, error) {
+	if err := s.checkWorkflowOwner(int(req.WorkflowId), req.UserId); err != nil {
+		return nil, err
+	}
+
+	filter := repositories.ExecutionFilter{
+		WorkflowId: int(req.WorkflowId),
+		Limit:      int(req.Limit),
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultExecutionsPageSize
+	}
+	if filter.Limit > maxExecutionsPageSize {
+		filter.Limit = maxExecutionsPageSize
+	}
+
+	if req.Status != nil {
+		executionStatus, err := models.ParseExecutionStatus(*req.Status)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.Status = &executionStatus
+	}
+	if req.From != nil {
+		from := req.From.AsTime()
+		filter.From = &from
+	}
+	if req.To != nil {
+		to := req.To.AsTime()
+		filter.To = &to
+	}
+	if req.Cursor != "" {
+		beforeId, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+		filter.BeforeId = beforeId
+	}
+
+	// Fetch one more than needed to know if there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	executionRepo := repositories.Execution{Db: s.Db}
+	dbExecutions, err := executionRepo.FindByWorkflowId(filter)
+	if err != nil {
+		log.Printf("Repo error: %v", err)
+		return nil, status.Error(codes.Internal, "failed to fetch executions")
+	}
+
+	nextCursor := ""
+	if len(dbExecutions) > pageSize {
+		dbExecutions = dbExecutions[:pageSize]
+		nextCursor = encodeCursor(dbExecutions[pageSize-1].Id)
+	}
+
+	executions := make([]*pb.Execution, 0, len(dbExecutions))
+	for _, execution := range dbExecutions {
+		executions = append(executions, executionToPb(&execution))
+	}
+
+	return &pb.ListExecutionsResponse{Executions: executions, NextCursor: nextCursor}, nil
+}
+
+func (s *ExecutionServiceServer) GetExecution(ctx context.Context, req *pb.GetExecutionRequest) (*pb.GetExecutionResponse, error) {
+	execution, err := s.findOwnedExecution(int(req.Id), req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetExecutionResponse{Execution: executionToPb(execution)}, nil
+}
+
+func (s *ExecutionServiceServer) GetExecutionSteps(ctx context.Context, req *pb.GetExecutionStepsRequest) (*pb.GetExecutionStepsResponse, error) {
+	if _, err := s.findOwnedExecution(int(req.ExecutionId), req.UserId); err != nil {
+		return nil, err
+	}
+
+	stepRepo := repositories.ExecutionStep{Db: s.Db}
+	dbSteps, err := stepRepo.FindByExecutionId(int(req.ExecutionId))
+	if err != nil {
+		log.Printf("Repo error: %v", err)
+		return nil, status.Error(codes.Internal, "failed to fetch execution steps")
+	}
+
+	attemptRepo := repositories.ExecutionStepAttempt{Db: s.Db}
+	dbAttempts, err := attemptRepo.FindByExecutionId(int(req.ExecutionId))
+	if err != nil {
+		log.Printf("Repo error: %v", err)
+		return nil, status.Error(codes.Internal, "failed to fetch execution step attempts")
+	}
+
+	steps := make([]*pb.ExecutionStep, 0, len(dbSteps))
+	for _, step := range dbSteps {
+		attempts := make([]*pb.ExecutionStepAttempt, 0, len(dbAttempts[step.Id]))
+		for _, attempt := range dbAttempts[step.Id] {
+			attempts = append(attempts, &pb.ExecutionStepAttempt{
+				Attempt:    int32(attempt.Attempt),
+				Status:     attempt.Status.String(),
+				Error:      attempt.Error,
+				ErrorClass: attempt.ErrorClass,
+				StartedAt:  timestamppb.New(attempt.StartedAt),
+				FinishedAt: timestamppb.New(attempt.FinishedAt),
+			})
+		}
+		steps = append(steps, &pb.ExecutionStep{
+			Id:               int64(step.Id),
+			ExecutionId:      int64(step.ExecutionId),
+			NodeId:           step.NodeId,
+			DisplayId:        step.DisplayId,
+			Status:           step.Status.String(),
+			Input:            step.Input,
+			Output:           step.Output,
+			Error:            step.Error,
+			StartedAt:        timestamppb.New(step.StartedAt),
+			FinishedAt:       optionalTimestamp(step.FinishedAt),
+			Attempts:         attempts,
+			SimulatedRequest: step.SimulatedRequest,
+		})
+	}
+
+	return &pb.GetExecutionStepsResponse{Steps: steps}, nil
+}
+
+// CancelExecution stops an unfinished execution and the sub-workflows it invoked. A running execution
+// is stopped by its worker within a few seconds, the returned execution can still be running
+func (s *ExecutionServiceServer) CancelExecution(ctx context.Context, req *pb.CancelExecutionRequest) (*pb.CancelExecutionResponse, error) {
+	execution, err := s.findOwnedExecution(int(req.Id), req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if execution.Status.IsFinal() {
+		return nil, status.Errorf(codes.FailedPrecondition, "execution already %s", execution.Status)
+	}
+
+	executionRepo := repositories.Execution{Db: s.Db}
+	if err := cancelWithChildren(&executionRepo, execution.Id); err != nil {
+		log.Printf("Repo error: %v", err)
+		return nil, status.Error(codes.Internal, "failed to cancel execution")
+	}
+
+	execution, err = executionRepo.FindById(execution.Id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch execution")
+	}
+	return &pb.CancelExecutionResponse{Execution: executionToPb(execution)}, nil
+}
+
+func cancelWithChildren(executionRepo *repositories.Execution, id int) error {
+	if err := executionRepo.RequestCancel(id); err != nil {
+		return err
+	}
+	children, err := executionRepo.FindChildren(id)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child.Status.IsFinal() {
+			continue
+		}
+		if err := cancelWithChildren(executionRepo, child.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExecutionServiceServer) findOwnedExecution(id int, userId int64) (*models.Execution, error) {
+	executionRepo := repositories.Execution{Db: s.Db}
+	execution, err := executionRepo.FindById(id)
+	if err != nil {
+		if errors.Is(err, errs.NotFoundError{EntityName: "Execution"}) {
+			return nil, status.Error(codes.NotFound, "execution not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to fetch execution")
+	}
+	if err := s.checkWorkflowOwner(execution.WorkflowId, userId); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+// Executions of other users' workflows are reported as missing
+func (s *ExecutionServiceServer) checkWorkflowOwner(workflowId int, userId int64) error {
+	workflowRepo := repositories.Workflow{Db: s.Db}
+	workflow, err := workflowRepo.FindById(workflowId)
+	if err != nil {
+		if errors.Is(err, errs.NotFoundError{EntityName: "Workflow"}) {
+			return status.Error(codes.NotFound, "workflow not found")
+		}
+		return status.Error(codes.Internal, "failed to fetch workflow")
+	}
+	if int64(workflow.UserId) != userId {
+		return status.Error(codes.NotFound, "workflow not found")
+	}
+	return nil
+}
+
+func executionToPb(execution *models.Execution) *pb.Execution {
+	result := &pb.Execution{
+		Id:             int64(execution.Id),
+		WorkflowId:     int64(execution.WorkflowId),
+		ListenerNodeId: execution.ListenerNodeId,
+		Status:         execution.Status.String(),
+		TriggerPayload: execution.TriggerPayload,
+		Output:         execution.Output,
+		Error:          execution.Error,
+		Depth:          int32(execution.Depth),
+		DryRun:         execution.DryRun,
+		CreatedAt:      timestamppb.New(execution.CreatedAt),
+		StartedAt:      optionalTimestamp(execution.StartedAt),
+		FinishedAt:     optionalTimestamp(execution.FinishedAt),
+	}
+	if execution.ParentExecutionId != nil {
+		parentId := int64(*execution.ParentExecutionId)
+		result.ParentExecutionId = &parentId
+	}
+	if execution.ReplayOfExecutionId != nil {
+		replayOfId := int64(*execution.ReplayOfExecutionId)
+		result.ReplayOfExecutionId = &replayOfId
+	}
+	return result
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// The cursor is the id of the last execution on the page, kept opaque for the clients
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(decoded))
+}
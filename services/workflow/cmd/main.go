@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
 	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
 	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
@@ -14,6 +16,7 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -21,6 +24,8 @@ import (
 type WorkflowServiceServer struct {
 	pb.UnimplementedWorkflowServiceServer
 	Db *sql.DB
+	// Describes the tasks of the workers, node configs are validated against them
+	Orchestrator pb.OrchestratorClient
 }
 
 func (s *WorkflowServiceServer) GetWorkflows(ctx context.Context, req *pb.GetWorkflowsRequest) (*pb.GetWorkflowsResponse, error) {
@@ -35,12 +40,13 @@ func (s *WorkflowServiceServer) GetWorkflows(ctx context.Context, req *pb.GetWor
 	var workflows []*pb.Workflow
 	for _, w := range dbWorkflows {
 		workflows = append(workflows, &pb.Workflow{
-			Id:        int64(w.Id),
-			Name:      w.Name,
-			IsActive:  w.Active,
-			UserId:    int64(w.UserId),
-			CreatedAt: timestamppb.New(w.CreatedAt),
-			UpdatedAt: timestamppb.New(w.UpdatedAt),
+			Id:                 int64(w.Id),
+			Name:               w.Name,
+			IsActive:           w.Active,
+			UserId:             int64(w.UserId),
+			CreatedAt:          timestamppb.New(w.CreatedAt),
+			UpdatedAt:          timestamppb.New(w.UpdatedAt),
+			MaxDurationSeconds: int32(w.MaxDurationSeconds),
 		})
 	}
 
@@ -119,15 +125,26 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
     workflowRepo := repositories.Workflow{Db: s.Db}
     workflowNodeRepo := repositories.WorkflowNode{Db: s.Db}
     workflowEdgeRepo := repositories.WorkflowEdge{Db: s.Db}
+    webhookRepo := repositories.Webhook{Db: s.Db}
 
 	// TODO: Transaction + Unit of Work
 
+    if err := validateWorkflowGraph(req.Nodes, req.Edges); err != nil {
+        return nil, status.Error(codes.InvalidArgument, err.Error())
+    }
+    if err := validateNodeConfigs(ctx, s.Orchestrator, req.Nodes); err != nil {
+        return nil, status.Error(codes.InvalidArgument, err.Error())
+    }
+    if req.MaxDurationSeconds < 0 {
+        return nil, status.Error(codes.InvalidArgument, "max_duration_seconds can't be negative")
+    }
+
     var workflowId int
     if req.Id > 0 {
         // Update the workflow
         workflowId = int(req.Id)
 
-		if err := workflowRepo.Update(workflowId, req.Name); err != nil {
+		if err := workflowRepo.Update(workflowId, req.Name, int(req.MaxDurationSeconds)); err != nil {
              return nil, status.Errorf(codes.Internal, "failed to update workflow: %v", err)
         }
         
@@ -173,6 +190,7 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
             Name:   req.Name,
             UserId: int(req.UserId),
             Active: false,
+            MaxDurationSeconds: int(req.MaxDurationSeconds),
         }
         if err := workflowRepo.Insert(workflowModel); err != nil {
             return nil, status.Error(codes.Internal, "failed to create workflow")
@@ -204,6 +222,7 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
             CredentialId: nodeReq.CredentialId,
             DisplayId:    nodeReq.DisplayId,
             Position:     nodeReq.Position,
+            Settings:     nodeReq.Settings,
         }
 
         if nodeReq.Id != nil && *nodeReq.Id != "" {
@@ -219,6 +238,12 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
                 return nil, status.Error(codes.Internal, "failed to save workflow nodes")
             }
         }
+        if isWebhookListener(nodeModel) {
+            if _, err := ensureWebhook(&webhookRepo, realId); err != nil {
+                log.Printf("Failed to create webhook of node %s: %v", nodeReq.DisplayId, err)
+                return nil, status.Error(codes.Internal, "failed to save workflow nodes")
+            }
+        }
         // Track the ID for edge creation
         nodeIdMap[nodeReq.DisplayId] = realId
     }
@@ -228,6 +253,11 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
         fmt.Println("TEST")
         fmt.Println(edgeReq)
 		if edgeReq.Id != nil {
+			// Existing edges only change their branch label
+			if err := workflowEdgeRepo.UpdateLabel(*edgeReq.Id, edgeReq.Label); err != nil {
+				log.Printf("Failed to update edge %s: %v", *edgeReq.Id, err)
+				return nil, status.Error(codes.Internal, "failed to update workflow connections")
+			}
 			continue
 		}
         fromId, okFrom := nodeIdMap[edgeReq.FromId]
@@ -240,6 +270,7 @@ func (s *WorkflowServiceServer) CreateWorkflow(ctx context.Context, req *pb.Crea
                 DisplayId:  edgeReq.DisplayId,
                 NodeFrom:   fromId,
                 NodeTo:     toId,
+                Label:      edgeReq.Label,
             })
         }
     }
@@ -270,7 +301,9 @@ func (s *WorkflowServiceServer) GetWorkflowById(ctx context.Context, req *pb.Get
 
     workflow, err := workflowRepo.FindById(int(req.Id))
 	if err != nil {
-		// TODO: Return status error from google
+		if errors.Is(err, errs.NotFoundError{EntityName: "Workflow"}) {
+			return nil, status.Error(codes.NotFound, "workflow not found")
+		}
 		return nil, err
 	}
 	fmt.Println(workflow)
@@ -285,8 +318,19 @@ func (s *WorkflowServiceServer) GetWorkflowById(ctx context.Context, req *pb.Get
 	}
 	fmt.Println(edges)
 
+	webhookRepo := repositories.Webhook{ Db: s.Db }
+
 	nodesMapped := make([]*pb.Node, 0)
 	for _, node := range nodes {
+		var webhookToken *string
+		if isWebhookListener(&node) {
+			webhook, err := webhookRepo.FindByNodeId(node.Id)
+			if err != nil {
+				log.Printf("Failed to fetch webhook of node %s: %v", node.Id, err)
+			} else {
+				webhookToken = &webhook.Token
+			}
+		}
 		nodesMapped = append(nodesMapped, &pb.Node{
 			Id: node.Id,
 			DisplayId: node.DisplayId,
@@ -297,6 +341,8 @@ func (s *WorkflowServiceServer) GetWorkflowById(ctx context.Context, req *pb.Get
 			Type: node.Type.String(),
 			Position: node.Position,
 			CredentialId: node.CredentialId,
+			Settings: node.Settings,
+			WebhookToken: webhookToken,
 		})
 	}
 
@@ -308,6 +354,7 @@ func (s *WorkflowServiceServer) GetWorkflowById(ctx context.Context, req *pb.Get
 			WorkflowId: int64(edge.WorkflowId),
 			FromId: edge.NodeFrom,
 			ToId: edge.NodeTo,
+			Label: edge.Label,
 		})
 	}
 
@@ -318,6 +365,7 @@ func (s *WorkflowServiceServer) GetWorkflowById(ctx context.Context, req *pb.Get
 		IsActive: workflow.Active,
 		CreatedAt: timestamppb.New(workflow.CreatedAt),
 		UpdatedAt: timestamppb.New(workflow.UpdatedAt),
+		MaxDurationSeconds: int32(workflow.MaxDurationSeconds),
 	}, Nodes: nodesMapped, Edges: edgesMapped,}, nil
 }
 
@@ -334,8 +382,15 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
+	orchestratorConn, err := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Did not connect to Orchestrator: %v", err)
+	}
+	defer orchestratorConn.Close()
+
 	grpcServer := grpc.NewServer()
-	pb.RegisterWorkflowServiceServer(grpcServer, &WorkflowServiceServer{Db: db})
+	pb.RegisterWorkflowServiceServer(grpcServer, &WorkflowServiceServer{Db: db, Orchestrator: pb.NewOrchestratorClient(orchestratorConn)})
+	pb.RegisterExecutionServiceServer(grpcServer, &ExecutionServiceServer{Db: db})
 
 	log.Printf("Workflow Service running on :50056...")
 	if err := grpcServer.Serve(listener); err != nil {
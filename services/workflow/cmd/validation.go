@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+)
+
+// validateWorkflowGraph checks the rules which can't be expressed by validating nodes and edges one by one.
+// Edges reference nodes by their display id.
+func validateWorkflowGraph(nodes []*pb.NodeInput, edges []*pb.EdgeInput) error {
+	outgoing := make(map[string][]*pb.EdgeInput)
+	incoming := make(map[string][]*pb.EdgeInput)
+	for _, edge := range edges {
+		outgoing[edge.FromId] = append(outgoing[edge.FromId], edge)
+		incoming[edge.ToId] = append(incoming[edge.ToId], edge)
+	}
+
+	for _, node := range nodes {
+		if _, err := models.ValidateNodeSettings(node.Settings); err != nil {
+			return fmt.Errorf("invalid settings of node %s: %v", node.DisplayId, err)
+		}
+
+		switch node.Type {
+		case "listener":
+			if node.ServiceName == models.ScheduleServiceName {
+				if _, err := models.ParseScheduleConfig(node.Config); err != nil {
+					return fmt.Errorf("invalid config of schedule node %s: %v", node.DisplayId, err)
+				}
+			}
+			if node.ServiceName == models.WebhookServiceName {
+				if _, err := models.ParseWebhookConfig(node.Config); err != nil {
+					return fmt.Errorf("invalid config of webhook node %s: %v", node.DisplayId, err)
+				}
+			}
+			if node.ServiceName == models.HttpServiceName {
+				if _, err := models.ParseHttpPollConfig(node.Config); err != nil {
+					return fmt.Errorf("invalid config of http node %s: %v", node.DisplayId, err)
+				}
+			}
+			if node.ServiceName == models.GmailServiceName {
+				if _, err := models.ParseGmailListenerConfig(node.Config); err != nil {
+					return fmt.Errorf("invalid config of gmail node %s: %v", node.DisplayId, err)
+				}
+			}
+			if node.ServiceName == models.FeedServiceName {
+				if _, err := models.ParseFeedConfig(node.Config); err != nil {
+					return fmt.Errorf("invalid config of feed node %s: %v", node.DisplayId, err)
+				}
+			}
+			for _, edge := range outgoing[node.DisplayId] {
+				if edge.Label == models.ErrorEdgeLabel {
+					return fmt.Errorf("listener node %s can't have %s edges", node.DisplayId, models.ErrorEdgeLabel)
+				}
+			}
+		case "condition":
+			if err := validateConditionEdges(node, outgoing[node.DisplayId]); err != nil {
+				return err
+			}
+		case "join":
+			if len(incoming[node.DisplayId]) < 2 {
+				return fmt.Errorf("join node %s needs at least two incoming edges", node.DisplayId)
+			}
+			if _, err := models.ParseJoinConfig(node.Config); err != nil {
+				return fmt.Errorf("invalid config of join node %s: %v", node.DisplayId, err)
+			}
+		case "action":
+			if node.ServiceName == models.CoreServiceName && node.TaskName == models.InvokeWorkflowTask {
+				if err := validateInvokeConfig(node); err != nil {
+					return err
+				}
+			}
+		case "loop":
+			if _, err := models.ParseLoopConfig(node.Config); err != nil {
+				return fmt.Errorf("invalid config of loop node %s: %v", node.DisplayId, err)
+			}
+			if err := validateLoopBody(node, nodes, outgoing, incoming); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateInvokeConfig checks the workflow_id of an invoke-workflow node, which may also be a variable
+func validateInvokeConfig(node *pb.NodeInput) error {
+	var config struct {
+		WorkflowId interface{} `json:"workflow_id"`
+	}
+	if err := json.Unmarshal([]byte(node.Config), &config); err != nil {
+		return fmt.Errorf("invalid config of node %s: %v", node.DisplayId, err)
+	}
+	switch id := config.WorkflowId.(type) {
+	case float64:
+		if id <= 0 || id != float64(int(id)) {
+			return fmt.Errorf("node %s: workflow_id must be a positive integer", node.DisplayId)
+		}
+	case string:
+	default:
+		return fmt.Errorf("node %s needs a workflow_id", node.DisplayId)
+	}
+	return nil
+}
+
+// invokeWaits tells if an invoke-workflow node waits for the sub-workflow, which it does unless wait is false
+func invokeWaits(node *pb.NodeInput) bool {
+	var config struct {
+		Wait *bool `json:"wait"`
+	}
+	if err := json.Unmarshal([]byte(node.Config), &config); err != nil {
+		return true
+	}
+	return config.Wait == nil || *config.Wait
+}
+
+func validateConditionEdges(node *pb.NodeInput, edges []*pb.EdgeInput) error {
+	for _, edge := range edges {
+		if edge.Label == "" {
+			return fmt.Errorf("edge %s from condition node %s needs a branch label", edge.DisplayId, node.DisplayId)
+		}
+		if edge.Label == models.ErrorEdgeLabel {
+			continue
+		}
+		if node.TaskName == "if" && edge.Label != "true" && edge.Label != "false" {
+			return fmt.Errorf("edge %s from if node %s must be labelled true or false", edge.DisplayId, node.DisplayId)
+		}
+	}
+	return nil
+}
+
+// validateLoopBody checks that the body of a loop is only entered through the loop's body edges,
+// so it can run once per item on its own
+func validateLoopBody(loop *pb.NodeInput, nodes []*pb.NodeInput, outgoing, incoming map[string][]*pb.EdgeInput) error {
+	body := make(map[string]bool)
+	stack := make([]string, 0)
+	for _, edge := range outgoing[loop.DisplayId] {
+		switch edge.Label {
+		case models.LoopBodyLabel:
+			if !body[edge.ToId] {
+				body[edge.ToId] = true
+				stack = append(stack, edge.ToId)
+			}
+		case models.LoopDoneLabel, models.ErrorEdgeLabel:
+		default:
+			return fmt.Errorf("edge %s from loop node %s must be labelled %s or %s", edge.DisplayId, loop.DisplayId, models.LoopBodyLabel, models.LoopDoneLabel)
+		}
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("loop node %s needs a %s edge", loop.DisplayId, models.LoopBodyLabel)
+	}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, edge := range outgoing[current] {
+			if edge.ToId == loop.DisplayId {
+				return fmt.Errorf("the body of loop node %s can't lead back to it", loop.DisplayId)
+			}
+			if !body[edge.ToId] {
+				body[edge.ToId] = true
+				stack = append(stack, edge.ToId)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if !body[node.DisplayId] {
+			continue
+		}
+		if node.Type == "listener" {
+			return fmt.Errorf("listener node %s can't be inside the body of loop node %s", node.DisplayId, loop.DisplayId)
+		}
+		if node.Type == "transformer" && (node.TaskName == models.DelayTask || node.TaskName == models.WaitUntilTask) {
+			return fmt.Errorf("%s node %s can't be inside the body of loop node %s", node.TaskName, node.DisplayId, loop.DisplayId)
+		}
+		// An iteration can't pause until the sub-workflow finishes
+		if node.Type == "action" && node.ServiceName == models.CoreServiceName && node.TaskName == models.InvokeWorkflowTask && invokeWaits(node) {
+			return fmt.Errorf("invoke node %s inside the body of loop node %s needs \"wait\": false", node.DisplayId, loop.DisplayId)
+		}
+		for _, edge := range incoming[node.DisplayId] {
+			if !body[edge.FromId] && !(edge.FromId == loop.DisplayId && edge.Label == models.LoopBodyLabel) {
+				return fmt.Errorf("node %s is inside the body of loop node %s and can't be reached from outside of it", node.DisplayId, loop.DisplayId)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/repositories"
+)
+
+func isWebhookListener(node *models.WorkflowNode) bool {
+	return node.Type == models.Listener && node.ServiceName == models.WebhookServiceName
+}
+
+// ensureWebhook gives a webhook listener its URL token the first time it is saved, the token never changes afterwards
+func ensureWebhook(webhookRepo *repositories.Webhook, nodeId string) (*models.Webhook, error) {
+	webhook, err := webhookRepo.FindByNodeId(nodeId)
+	if err == nil {
+		return webhook, nil
+	}
+	if !errors.Is(err, errs.NotFoundError{EntityName: "Webhook"}) {
+		return nil, err
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	webhook = &models.Webhook{NodeId: nodeId, Token: hex.EncodeToString(token)}
+	if err := webhookRepo.Insert(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
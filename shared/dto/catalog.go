@@ -0,0 +1,23 @@
+package dto
+
+import "encoding/json"
+
+// A task of a service as its worker describes it
+type CatalogTask struct {
+	Name        string `json:"name"`
+	NodeType    string `json:"node_type"`
+	DisplayName string `json:"display_name"`
+	// JSON Schemas, null when the worker gives none
+	ConfigSchema json.RawMessage `json:"config_schema"`
+	OutputSchema json.RawMessage `json:"output_schema"`
+	OAuthScopes  []string        `json:"oauth_scopes"`
+}
+
+type CatalogService struct {
+	ServiceName string        `json:"service_name"`
+	Tasks       []CatalogTask `json:"tasks"`
+}
+
+type CatalogResponse struct {
+	Services []CatalogService `json:"services"`
+}
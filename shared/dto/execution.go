@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ListExecutionsQuery struct {
+	Status string `validate:"omitempty,oneof=pending running succeeded failed skipped waiting cancelled timed_out"`
+	From   *time.Time
+	To     *time.Time
+	Cursor string
+	Limit  int `validate:"omitempty,min=1,max=100"`
+}
+
+type Execution struct {
+	Id                  int             `json:"id"`
+	WorkflowId          int             `json:"workflow_id"`
+	ListenerNodeId      string          `json:"listener_node_id"`
+	Status              string          `json:"status"`
+	TriggerPayload      json.RawMessage `json:"trigger_payload"`
+	Output              json.RawMessage `json:"output,omitempty"`
+	Error               string          `json:"error,omitempty"`
+	ParentExecutionId   *int            `json:"parent_execution_id,omitempty"`
+	Depth               int             `json:"depth"`
+	DryRun              bool            `json:"dry_run"`
+	ReplayOfExecutionId *int            `json:"replay_of_execution_id,omitempty"`
+	CreatedAt           time.Time       `json:"created_at"`
+	StartedAt           *time.Time      `json:"started_at"`
+	FinishedAt          *time.Time      `json:"finished_at"`
+}
+
+type ListExecutionsResponse struct {
+	Executions []Execution `json:"executions"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type ExecutionStep struct {
+	Id          int             `json:"id"`
+	ExecutionId int             `json:"execution_id"`
+	NodeId      string          `json:"node_id"`
+	DisplayId   string          `json:"display_id"`
+	Status      string          `json:"status"`
+	Input       json.RawMessage `json:"input"`
+	Output      json.RawMessage `json:"output"`
+	Error       string          `json:"error,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+	// Only steps with a retry policy have more than one
+	Attempts []ExecutionStepAttempt `json:"attempts"`
+	// What an action of a dry run would have sent: {"service_name": ..., "task_name": ..., "config": {...}}
+	SimulatedRequest json.RawMessage `json:"simulated_request,omitempty"`
+}
+
+type ExecutionStepAttempt struct {
+	Attempt    int       `json:"attempt"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Without from_failure and from_node the whole execution runs again with its trigger payload
+type ReplayExecutionPayload struct {
+	// Re-run the nodes which did not succeed, reusing the outputs of the others
+	FromFailure bool `json:"from_failure"`
+	// Display id of a node to re-run with everything downstream of it
+	FromNode string `json:"from_node"`
+}
+
+type ReplayExecutionResponse struct {
+	ExecutionId int `json:"execution_id"`
+}
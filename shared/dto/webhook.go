@@ -0,0 +1,9 @@
+package dto
+
+// What a webhook answers when it doesn't respond with the output of the workflow:
+// right after queueing the execution or when the execution failed. Callers are not authenticated,
+// so the error of a failed execution is only shown in the app
+type WebhookResponse struct {
+	ExecutionId int    `json:"execution_id"`
+	Status      string `json:"status,omitempty"`
+}
@@ -1,10 +1,15 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type CreateWorkflow struct {
 	Id   *int   `json:"id"`
 	Name string
+	// Executions running longer are stopped as timed out, 0 means no limit
+	MaxDurationSeconds int `json:"max_duration_seconds" validate:"min=0"`
 }
 
 type CreateWorkflowNode struct {
@@ -12,10 +17,11 @@ type CreateWorkflowNode struct {
 	DisplayId    string `validate:"required"`
 	ServiceName  string `validate:"required"`
 	TaskName     string `validate:"required"`
-	Type         string `validate:"required,oneof=listener action transformer"`
+	Type         string `validate:"required,oneof=listener action transformer condition join loop"`
 	Position     string `validate:"required"`
 	Config       string `validate:"required"`
 	CredentialId *int32 `json:"credential_id"`
+	Settings     string `json:"settings"`
 }
 
 type CreateWorkflowEdge struct {
@@ -23,6 +29,7 @@ type CreateWorkflowEdge struct {
 	From      string `validate:"required"`
 	To        string `validate:"required"`
 	DisplayId string `validate:"required"`
+	Label     string `json:"label"`
 }
 
 type CreateWorkflowPayload struct {
@@ -43,6 +50,7 @@ type Workflow struct {
 	Name   string `json:"name"`
 	Active bool `json:"active"`
 	UserId int `json:"user_id"`
+	MaxDurationSeconds int `json:"max_duration_seconds"`
 }
 
 type ActivateWorkflowPayload struct {
@@ -59,6 +67,9 @@ type GetNodeResponse struct {
 	Position     string `json:"position"`
 	Config       string `json:"config"`
 	CredentialId *int32 `json:"credential_id"`
+	Settings     string `json:"settings"`
+	// Set for webhook listeners, their URL is /hooks/{webhook_token}
+	WebhookToken *string `json:"webhook_token,omitempty"`
 }
 
 type GetEdgeResponse struct {
@@ -67,6 +78,7 @@ type GetEdgeResponse struct {
 	WorkflowId   int 	`json:"workflow_id"`
 	NodeFrom 	 string `json:"node_from"`
 	NodeTo 	 string `json:"node_to"`
+	Label 	 string `json:"label"`
 }
 
 type GetWorkflowResponse struct {
@@ -74,3 +86,17 @@ type GetWorkflowResponse struct {
 	Nodes    []GetNodeResponse `json:"nodes"`
 	Edges    []GetEdgeResponse `json:"edges"`
 }
+
+// Runs the workflow once, also when it is not active
+type RunWorkflowPayload struct {
+	// Display id or id of the listener node the run starts at, can be left out when there is only one
+	Listener string `json:"listener"`
+	// The trigger payload, an object. Defaults to {}
+	Payload json.RawMessage `json:"payload"`
+	// Record the actions instead of running them
+	DryRun bool `json:"dry_run"`
+}
+
+type RunWorkflowResponse struct {
+	ExecutionId int `json:"execution_id"`
+}
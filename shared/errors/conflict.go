@@ -0,0 +1,10 @@
+package errs
+
+// ConflictError is a request which the current state of the entity doesn't allow
+type ConflictError struct {
+	Message string
+}
+
+func (err ConflictError) Error() string {
+	return err.Message
+}
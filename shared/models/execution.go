@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// How deep sub-workflows can invoke each other
+const MaxExecutionDepth = 5
+
+type ExecutionStatus int
+
+// Stored as ints in the db, so new statuses must be appended at the end
+const (
+	Pending ExecutionStatus = iota
+	Running
+	Succeeded
+	Failed
+	Skipped
+	// Paused by a delay or wait-until node, a queued job resumes it
+	Waiting
+	Cancelled
+	TimedOut
+)
+
+// IsFinal tells if an execution with the status is done and won't run again
+func (status ExecutionStatus) IsFinal() bool {
+	return status == Succeeded || status == Failed || status == Cancelled || status == TimedOut
+}
+
+func (status ExecutionStatus) String() string {
+	switch status {
+	case Pending:
+		return "pending"
+	case Running:
+		return "running"
+	case Succeeded:
+		return "succeeded"
+	case Failed:
+		return "failed"
+	case Skipped:
+		return "skipped"
+	case Waiting:
+		return "waiting"
+	case Cancelled:
+		return "cancelled"
+	case TimedOut:
+		return "timed_out"
+	default:
+		panic("Invalid execution status")
+	}
+}
+
+func ExecutionStatusFromString(s string) ExecutionStatus {
+	status, err := ParseExecutionStatus(s)
+	if err != nil {
+		panic("Invalid execution status")
+	}
+	return status
+}
+
+// ParseExecutionStatus is the non panicking version of ExecutionStatusFromString for user input
+func ParseExecutionStatus(s string) (ExecutionStatus, error) {
+	switch s {
+	case "pending":
+		return Pending, nil
+	case "running":
+		return Running, nil
+	case "succeeded":
+		return Succeeded, nil
+	case "failed":
+		return Failed, nil
+	case "skipped":
+		return Skipped, nil
+	case "waiting":
+		return Waiting, nil
+	case "cancelled":
+		return Cancelled, nil
+	case "timed_out":
+		return TimedOut, nil
+	default:
+		return 0, fmt.Errorf("invalid execution status %q", s)
+	}
+}
+
+type Execution struct {
+	Id        int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	WorkflowId     int
+	ListenerNodeId string
+	Status         ExecutionStatus
+	TriggerPayload string // JSON encoded
+	Output         string // JSON encoded
+	Error          string
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+
+	// The execution which invoked this one as a sub-workflow
+	ParentExecutionId *int
+	Depth             int
+	// The invoke step of the parent which started this execution
+	ParentStepId *int
+
+	CancelRequested bool
+	// Actions are recorded instead of being sent to their workers
+	DryRun bool
+	// The execution which this one replays
+	ReplayOfExecutionId *int
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type ExecutionStep struct {
+	Id        int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	ExecutionId int
+	NodeId      string
+	DisplayId   string
+	Status      ExecutionStatus
+	Input       string // JSON encoded, the resolved config which was sent to the worker
+	Output      string // JSON encoded
+	Error       string
+	// Set when the node itself failed, a failure handled by on_error edges is restored from it on resume
+	ErrorClass string
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+	// JSON encoded, set on the actions of dry runs
+	SimulatedRequest string
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// One try of running a step, a step with a retry policy can have several
+type ExecutionStepAttempt struct {
+	Id        int
+	CreatedAt time.Time
+
+	StepId     int
+	Attempt    int
+	Status     ExecutionStatus
+	Error      string
+	ErrorClass string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Listeners of this service poll RSS and Atom feeds, see services/feed-listener
+const (
+	FeedServiceName = "feed"
+	FeedEntryTask   = "new_entry"
+)
+
+const (
+	DefaultFeedIntervalSeconds = 300
+	// Feeds rarely change more often, and their servers rate limit
+	MinFeedIntervalSeconds = 60
+)
+
+// {"url": "https://go.dev/blog/feed.atom", "interval_seconds": 300}
+type FeedConfig struct {
+	URL             string `json:"url"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+// ParseFeedConfig reads the config of a feed listener, the interval defaults to 5 minutes
+func ParseFeedConfig(configJSON string) (*FeedConfig, error) {
+	config := FeedConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return nil, err
+		}
+	}
+
+	target, err := url.Parse(config.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = DefaultFeedIntervalSeconds
+	}
+	if config.IntervalSeconds < MinFeedIntervalSeconds {
+		return nil, fmt.Errorf("interval_seconds must be at least %d", MinFeedIntervalSeconds)
+	}
+	return &config, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Listeners of this service are polled by services/gmail-listener
+const (
+	GmailServiceName  = "gmail"
+	GmailListenerTask = "get-email"
+)
+
+// Longest search query which is accepted, Gmail rejects much longer ones
+const MaxGmailQueryLength = 1000
+
+// {"query": "from:github.com has:attachment -category:promotions", "labels": ["Work", "Clients/Acme"]}
+// Both are optional. An email has to match the query and carry one of the labels
+type GmailListenerConfig struct {
+	// Gmail search syntax, as in the search box
+	Query string `json:"query"`
+	// Label names
+	Labels []string `json:"labels"`
+}
+
+// ParseGmailListenerConfig reads the config of a gmail listener, an empty config matches every email
+func ParseGmailListenerConfig(configJSON string) (*GmailListenerConfig, error) {
+	config := GmailListenerConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return nil, err
+		}
+	}
+
+	config.Query = strings.TrimSpace(config.Query)
+	if len(config.Query) > MaxGmailQueryLength {
+		return nil, fmt.Errorf("query must be at most %d characters", MaxGmailQueryLength)
+	}
+	if strings.Count(config.Query, `"`)%2 != 0 {
+		return nil, fmt.Errorf("query has an unclosed quote")
+	}
+	if strings.Count(config.Query, "(") != strings.Count(config.Query, ")") ||
+		strings.Count(config.Query, "{") != strings.Count(config.Query, "}") {
+		return nil, fmt.Errorf("query has unbalanced brackets")
+	}
+
+	for i, label := range config.Labels {
+		config.Labels[i] = strings.TrimSpace(label)
+		if config.Labels[i] == "" {
+			return nil, fmt.Errorf("labels must not be empty")
+		}
+	}
+	return &config, nil
+}
+
+// SearchQuery combines the checkpoint with the filters of the node, e.g.
+// after:1698300000 (from:github.com) {label:work label:clients-acme}
+func (config *GmailListenerConfig) SearchQuery(after time.Time) string {
+	terms := []string{fmt.Sprintf("after:%d", after.Unix())}
+	if config.Query != "" {
+		// In brackets, so an OR in the query doesn't take the checkpoint apart
+		terms = append(terms, "("+config.Query+")")
+	}
+	if len(config.Labels) > 0 {
+		labels := make([]string, 0, len(config.Labels))
+		for _, label := range config.Labels {
+			labels = append(labels, "label:"+gmailLabelTerm(label))
+		}
+		// Curly brackets match any of the terms
+		terms = append(terms, "{"+strings.Join(labels, " ")+"}")
+	}
+	return strings.Join(terms, " ")
+}
+
+// gmailLabelTerm writes a label name the way Gmail search expects it: lower case,
+// with spaces and the slashes of nested labels as hyphens
+func gmailLabelTerm(label string) string {
+	return strings.NewReplacer(" ", "-", "/", "-").Replace(strings.ToLower(label))
+}
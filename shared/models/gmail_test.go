@@ -0,0 +1,89 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGmailListenerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *GmailListenerConfig
+		wantErr bool
+	}{
+		{name: "empty", config: "", want: &GmailListenerConfig{}},
+		{name: "empty object", config: `{}`, want: &GmailListenerConfig{}},
+		{
+			name:   "query and labels",
+			config: `{"query": "  from:github.com has:attachment ", "labels": [" Work ", "Clients/Acme"]}`,
+			want:   &GmailListenerConfig{Query: "from:github.com has:attachment", Labels: []string{"Work", "Clients/Acme"}},
+		},
+		{name: "quoted phrase", config: `{"query": "subject:\"weekly report\""}`, want: &GmailListenerConfig{Query: `subject:"weekly report"`}},
+		{name: "brackets", config: `{"query": "(from:a OR from:b) {is:starred is:important}"}`, want: &GmailListenerConfig{Query: "(from:a OR from:b) {is:starred is:important}"}},
+		{name: "unclosed quote", config: `{"query": "subject:\"weekly"}`, wantErr: true},
+		{name: "unbalanced round brackets", config: `{"query": "(from:a OR from:b"}`, wantErr: true},
+		{name: "unbalanced curly brackets", config: `{"query": "from:a}"}`, wantErr: true},
+		{name: "query too long", config: `{"query": "` + strings.Repeat("a", MaxGmailQueryLength+1) + `"}`, wantErr: true},
+		{name: "empty label", config: `{"labels": ["Work", " "]}`, wantErr: true},
+		{name: "labels not a list", config: `{"labels": "Work"}`, wantErr: true},
+		{name: "invalid JSON", config: `{"query": }`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseGmailListenerConfig(test.config)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ParseGmailListenerConfig(%q) = %+v, want an error", test.config, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseGmailListenerConfig(%q) = %+v, want %+v", test.config, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	after := time.Unix(1698300000, 0)
+
+	tests := []struct {
+		name   string
+		config GmailListenerConfig
+		want   string
+	}{
+		{name: "no filters", want: "after:1698300000"},
+		{name: "query", config: GmailListenerConfig{Query: "from:github.com"}, want: "after:1698300000 (from:github.com)"},
+		{
+			name:   "OR stays inside the brackets",
+			config: GmailListenerConfig{Query: "from:a OR from:b"},
+			want:   "after:1698300000 (from:a OR from:b)",
+		},
+		{name: "label", config: GmailListenerConfig{Labels: []string{"Work"}}, want: "after:1698300000 {label:work}"},
+		{
+			name:   "labels with spaces and nesting",
+			config: GmailListenerConfig{Labels: []string{"Important Stuff", "Clients/Acme Corp"}},
+			want:   "after:1698300000 {label:important-stuff label:clients-acme-corp}",
+		},
+		{
+			name:   "query and labels",
+			config: GmailListenerConfig{Query: "has:attachment", Labels: []string{"Work", "Home"}},
+			want:   "after:1698300000 (has:attachment) {label:work label:home}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.config.SearchQuery(after); got != test.want {
+				t.Errorf("SearchQuery() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
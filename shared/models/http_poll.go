@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/utils"
+)
+
+// Listeners of this service poll an HTTP endpoint, see services/http-listener
+const (
+	HttpServiceName = "http"
+	HttpPollTask    = "poll"
+)
+
+const (
+	DefaultHttpPollIntervalSeconds = 60
+	// The listener checks its nodes every 20 seconds
+	MinHttpPollIntervalSeconds = 20
+)
+
+// {"url": "https://api.example.com/orders", "method": "GET", "headers": {"Accept": "application/json"},
+// "items_path": "$.data.orders", "id_path": "$.id", "interval_seconds": 60}
+// Without id_path an item is new when its content changed, it is compared by hash
+type HttpPollConfig struct {
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	ItemsPath       string            `json:"items_path"`
+	IdPath          string            `json:"id_path"`
+	IntervalSeconds int               `json:"interval_seconds"`
+}
+
+// HttpPoll is a parsed HttpPollConfig
+type HttpPoll struct {
+	Config HttpPollConfig
+	Items  *utils.JSONPath
+	// Not set when items are compared by hash
+	Id *utils.JSONPath
+}
+
+// ParseHttpPollConfig reads the config of an HTTP poll listener, the defaults are GET, $ and 60 seconds
+func ParseHttpPollConfig(configJSON string) (*HttpPoll, error) {
+	config := HttpPollConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return nil, err
+		}
+	}
+
+	target, err := url.Parse(config.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+
+	config.Method = strings.ToUpper(config.Method)
+	switch config.Method {
+	case "":
+		config.Method = http.MethodGet
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("unsupported method %q", config.Method)
+	}
+
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = DefaultHttpPollIntervalSeconds
+	}
+	if config.IntervalSeconds < MinHttpPollIntervalSeconds {
+		return nil, fmt.Errorf("interval_seconds must be at least %d", MinHttpPollIntervalSeconds)
+	}
+
+	if config.ItemsPath == "" {
+		config.ItemsPath = "$"
+	}
+	poll := &HttpPoll{Config: config}
+	if poll.Items, err = utils.ParseJSONPath(config.ItemsPath); err != nil {
+		return nil, fmt.Errorf("items_path: %v", err)
+	}
+	if config.IdPath != "" {
+		if poll.Id, err = utils.ParseJSONPath(config.IdPath); err != nil {
+			return nil, fmt.Errorf("id_path: %v", err)
+		}
+	}
+	return poll, nil
+}
+
+// The hosts a credential of a service may be sent to by an http listener. OAuth tokens only go
+// to the API of their provider, whatever URL the node polls, and services not listed go nowhere
+var credentialHosts = map[string][]string{
+	GmailServiceName: {"googleapis.com"},
+}
+
+// CredentialAllowed tells if the http listener may authenticate to the URL with a credential of the service
+func CredentialAllowed(serviceName string, rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(target.Hostname())
+	for _, allowed := range credentialHosts[serviceName] {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestCredentialAllowed(t *testing.T) {
+	tests := []struct {
+		serviceName string
+		url         string
+		want        bool
+	}{
+		{serviceName: GmailServiceName, url: "https://gmail.googleapis.com/gmail/v1/users/me/labels", want: true},
+		{serviceName: GmailServiceName, url: "https://googleapis.com/x", want: true},
+		{serviceName: GmailServiceName, url: "https://GMAIL.googleapis.com:443/x", want: true},
+		{serviceName: GmailServiceName, url: "http://gmail.googleapis.com/x"},
+		{serviceName: GmailServiceName, url: "https://evilgoogleapis.com/x"},
+		{serviceName: GmailServiceName, url: "https://googleapis.com.example.com/x"},
+		{serviceName: GmailServiceName, url: "https://example.com/?host=googleapis.com"},
+		{serviceName: HttpServiceName, url: "https://example.com/x"},
+		{serviceName: "", url: "https://gmail.googleapis.com/x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.serviceName+" "+test.url, func(t *testing.T) {
+			if got := CredentialAllowed(test.serviceName, test.url); got != test.want {
+				t.Errorf("CredentialAllowed(%q, %q) = %v, want %v", test.serviceName, test.url, got, test.want)
+			}
+		})
+	}
+}
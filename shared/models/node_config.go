@@ -0,0 +1,168 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Configs of the nodes which are executed by the orchestrator itself.
+// They are shared so the workflow service can validate them when a workflow is saved
+
+const (
+	// Wait for every incoming branch which was not skipped
+	JoinAll = "all"
+	// Continue as soon as the first incoming branch is done
+	JoinAny = "any"
+
+	// {"<upstream display id>": output, ...}
+	JoinObject = "object"
+	// [output, ...] in the order of the incoming edges
+	JoinArray = "array"
+)
+
+// {"strategy": "all", "output": "object"}
+type JoinConfig struct {
+	Strategy string `json:"strategy"`
+	Output   string `json:"output"`
+}
+
+// ParseJoinConfig reads the config of a join node, missing fields get the defaults (all, object)
+func ParseJoinConfig(configJSON string) (*JoinConfig, error) {
+	config := &JoinConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+			return nil, err
+		}
+	}
+	if config.Strategy == "" {
+		config.Strategy = JoinAll
+	}
+	if config.Output == "" {
+		config.Output = JoinObject
+	}
+	if config.Strategy != JoinAll && config.Strategy != JoinAny {
+		return nil, fmt.Errorf("unknown join strategy %q", config.Strategy)
+	}
+	if config.Output != JoinObject && config.Output != JoinArray {
+		return nil, fmt.Errorf("unknown join output %q", config.Output)
+	}
+	return config, nil
+}
+
+const (
+	DefaultLoopIterations = 100
+	MaxLoopIterations     = 1000
+	MaxLoopConcurrency    = 10
+)
+
+// {"items": "{{trigger.emails}}", "max_iterations": 100, "concurrency": 1}
+// Items is the template until the config is resolved, then the array to iterate over
+type LoopConfig struct {
+	Items         interface{} `json:"items"`
+	MaxIterations int         `json:"max_iterations"`
+	Concurrency   int         `json:"concurrency"`
+}
+
+func ParseLoopConfig(configJSON string) (*LoopConfig, error) {
+	config := &LoopConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+			return nil, err
+		}
+	}
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = DefaultLoopIterations
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.MaxIterations > MaxLoopIterations {
+		return nil, fmt.Errorf("max_iterations can be at most %d", MaxLoopIterations)
+	}
+	if config.Concurrency > MaxLoopConcurrency {
+		return nil, fmt.Errorf("concurrency can be at most %d", MaxLoopConcurrency)
+	}
+	return config, nil
+}
+
+// Settings of how the orchestrator runs a node, stored next to the config which goes to the worker
+type NodeSettings struct {
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Limit of every attempt of the node, 0 means no limit
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// What the action returns in dry runs instead of calling its worker, can use variables
+	MockOutput json.RawMessage `json:"mock_output,omitempty"`
+}
+
+// A retrying node keeps the lease of its execution, so retries are bounded
+const (
+	MaxRetryAttempts = 10
+	// Upper bound of the initial backoff and of every backoff it grows into
+	MaxRetryBackoffMs = 5 * 60 * 1000
+)
+
+// {"max_attempts": 5, "initial_backoff_ms": 1000, "multiplier": 2, "jitter": 0.2, "retry_on": ["rate_limited", "transient"]}
+type RetryPolicy struct {
+	MaxAttempts      int     `json:"max_attempts"`
+	InitialBackoffMs int     `json:"initial_backoff_ms"`
+	Multiplier       float64 `json:"multiplier"`
+	// Fraction of the backoff which is randomly added or removed, 0.2 -> +-20%
+	Jitter float64 `json:"jitter"`
+	// Error classes which are retried
+	RetryOn []string `json:"retry_on"`
+}
+
+// ParseNodeSettings reads the settings of a node and fills in the defaults of its retry policy.
+// Retries above the maximums are clamped, ValidateNodeSettings rejects them when a workflow is saved
+func ParseNodeSettings(settingsJSON string) (*NodeSettings, error) {
+	settings := &NodeSettings{}
+	if settingsJSON != "" {
+		if err := json.Unmarshal([]byte(settingsJSON), settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if settings.TimeoutMs < 0 {
+		return nil, fmt.Errorf("timeout_ms can't be negative")
+	}
+
+	if retry := settings.Retry; retry != nil {
+		if retry.MaxAttempts < 1 {
+			retry.MaxAttempts = 1
+		}
+		retry.MaxAttempts = min(retry.MaxAttempts, MaxRetryAttempts)
+		if retry.InitialBackoffMs <= 0 {
+			retry.InitialBackoffMs = 1000
+		}
+		retry.InitialBackoffMs = min(retry.InitialBackoffMs, MaxRetryBackoffMs)
+		if retry.Multiplier < 1 {
+			retry.Multiplier = 2
+		}
+		if retry.Jitter < 0 || retry.Jitter > 1 {
+			return nil, fmt.Errorf("retry jitter must be between 0 and 1")
+		}
+		if len(retry.RetryOn) == 0 {
+			retry.RetryOn = []string{ErrorClassRateLimited, ErrorClassTransient}
+		}
+	}
+	return settings, nil
+}
+
+// ValidateNodeSettings is ParseNodeSettings without clamping, retries above the maximums are an error
+func ValidateNodeSettings(settingsJSON string) (*NodeSettings, error) {
+	raw := &NodeSettings{}
+	if settingsJSON != "" {
+		if err := json.Unmarshal([]byte(settingsJSON), raw); err != nil {
+			return nil, err
+		}
+	}
+	if retry := raw.Retry; retry != nil {
+		if retry.MaxAttempts > MaxRetryAttempts {
+			return nil, fmt.Errorf("retry max_attempts can be at most %d", MaxRetryAttempts)
+		}
+		if retry.InitialBackoffMs > MaxRetryBackoffMs {
+			return nil, fmt.Errorf("retry initial_backoff_ms can be at most %d", MaxRetryBackoffMs)
+		}
+	}
+	return ParseNodeSettings(settingsJSON)
+}
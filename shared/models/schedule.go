@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/utils"
+)
+
+// Listeners of this service fire on a schedule, see services/schedule-listener
+const ScheduleServiceName = "schedule"
+
+// Task of the schedule listener
+const ScheduleTask = "schedule"
+
+// What happens to the runs which were missed while the schedule listener was down
+const (
+	// Drop them
+	CatchUpSkip = "skip"
+	// Run once for all of them
+	CatchUpOnce = "once"
+	// Run every one of them, at most MaxCatchUpRuns
+	CatchUpAll = "all"
+)
+
+const MaxCatchUpRuns = 100
+
+// Schedules are checked every few seconds, so shorter intervals can't be kept
+const MinScheduleIntervalSeconds = 60
+
+// {"cron": "0 9 * * MON-FRI", "timezone": "Europe/Sofia", "catch_up": "once"} or {"interval_seconds": 3600}
+type ScheduleConfig struct {
+	Cron            string `json:"cron"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	// IANA name, defaults to UTC
+	Timezone string `json:"timezone"`
+	CatchUp  string `json:"catch_up"`
+}
+
+// Schedule is a parsed ScheduleConfig
+type Schedule struct {
+	Config   ScheduleConfig
+	Location *time.Location
+	cron     *utils.CronSchedule
+}
+
+// ParseScheduleConfig reads the config of a schedule listener, the catch-up policy defaults to once
+func ParseScheduleConfig(configJSON string) (*Schedule, error) {
+	config := ScheduleConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return nil, err
+		}
+	}
+	if config.CatchUp == "" {
+		config.CatchUp = CatchUpOnce
+	}
+	if config.CatchUp != CatchUpSkip && config.CatchUp != CatchUpOnce && config.CatchUp != CatchUpAll {
+		return nil, fmt.Errorf("unknown catch_up policy %q", config.CatchUp)
+	}
+
+	schedule := &Schedule{Config: config, Location: time.UTC}
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", config.Timezone)
+		}
+		schedule.Location = location
+	}
+
+	switch {
+	case config.Cron != "" && config.IntervalSeconds != 0:
+		return nil, fmt.Errorf("a schedule has either a cron expression or an interval, not both")
+	case config.Cron != "":
+		cron, err := utils.ParseCron(config.Cron)
+		if err != nil {
+			return nil, err
+		}
+		schedule.cron = cron
+	case config.IntervalSeconds == 0:
+		return nil, fmt.Errorf("a schedule needs a cron expression or interval_seconds")
+	case config.IntervalSeconds < MinScheduleIntervalSeconds:
+		return nil, fmt.Errorf("interval_seconds must be at least %d", MinScheduleIntervalSeconds)
+	}
+	return schedule, nil
+}
+
+// Next is the first fire time after the given one. It is the zero time when the cron expression never matches
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	if schedule.cron != nil {
+		return schedule.cron.Next(after.In(schedule.Location))
+	}
+	return after.Add(time.Duration(schedule.Config.IntervalSeconds) * time.Second).In(schedule.Location)
+}
@@ -0,0 +1,13 @@
+package models
+
+// Error classes reported by the workers in TaskResponse.error_class.
+// Retry policies decide based on them if a failed task is tried again
+const (
+	ErrorClassRateLimited  = "rate_limited"
+	ErrorClassAuth         = "auth"
+	ErrorClassInvalidInput = "invalid_input"
+	ErrorClassTransient    = "transient"
+	// The node ran longer than the timeout in its settings
+	ErrorClassTimeout = "timeout"
+	ErrorClassUnknown = "unknown"
+)
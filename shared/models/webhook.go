@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Listeners of this service fire when their URL is called, see POST /hooks/{token} in the API
+const (
+	WebhookServiceName = "webhook"
+	WebhookTask        = "webhook"
+)
+
+const (
+	DefaultWebhookSignatureHeader = "X-Signature"
+	// How long a webhook which responds with the output of the workflow waits for it by default, and at most
+	DefaultWebhookResponseTimeout = 10
+	MaxWebhookResponseTimeout     = 30
+)
+
+// The URL of a webhook listener, the token is the unguessable part of it
+type Webhook struct {
+	NodeId    string
+	CreatedAt time.Time
+	Token     string
+}
+
+// {"secret": "...", "signature_header": "X-Signature", "respond_with_output": true, "response_timeout_seconds": 10}
+// With a secret, requests need the hex HMAC-SHA256 of their body in the signature header, "sha256=" in front is fine too.
+// With respond_with_output the request waits for the execution and gets its output back
+type WebhookConfig struct {
+	Secret                 string `json:"secret"`
+	SignatureHeader        string `json:"signature_header"`
+	RespondWithOutput      bool   `json:"respond_with_output"`
+	ResponseTimeoutSeconds int    `json:"response_timeout_seconds"`
+}
+
+func ParseWebhookConfig(configJSON string) (*WebhookConfig, error) {
+	config := &WebhookConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+			return nil, err
+		}
+	}
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = DefaultWebhookSignatureHeader
+	}
+	if config.ResponseTimeoutSeconds == 0 {
+		config.ResponseTimeoutSeconds = DefaultWebhookResponseTimeout
+	}
+	if config.ResponseTimeoutSeconds < 0 || config.ResponseTimeoutSeconds > MaxWebhookResponseTimeout {
+		return nil, fmt.Errorf("response_timeout_seconds must be between 1 and %d", MaxWebhookResponseTimeout)
+	}
+	return config, nil
+}
@@ -10,4 +10,6 @@ type Workflow struct {
 	Name      string
 	Active    bool
 	UserId    int
+	// 0 means no limit
+	MaxDurationSeconds int
 }
\ No newline at end of file
@@ -2,6 +2,16 @@ package models
 
 import "time"
 
+// Edges with this label are only followed when their source node fails
+const ErrorEdgeLabel = "on_error"
+
+// Outgoing edges of loop nodes: body edges lead to the nodes which run once per item,
+// done edges are followed once with the collected results
+const (
+	LoopBodyLabel = "body"
+	LoopDoneLabel = "done"
+)
+
 type WorkflowEdge struct {
 	Id         string
 	CreatedAt  time.Time
@@ -11,4 +21,7 @@ type WorkflowEdge struct {
 	NodeTo     string
 	WorkflowId int
 	DisplayId string
+	// Set on the outgoing edges of condition nodes: "true"/"false" or the name of a switch case,
+	// of loop nodes: LoopBodyLabel or LoopDoneLabel, or ErrorEdgeLabel on the edges of any node
+	Label      string
 }
\ No newline at end of file
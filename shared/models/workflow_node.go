@@ -4,12 +4,33 @@ import (
 	"time"
 )
 
+// Nodes of this service are run by the orchestrator itself instead of a worker
+const CoreServiceName = "core"
+
+// Task of the core listener which fires when an execution of its workflow fails
+const WorkflowFailedTask = "workflow-failed"
+
+// A core action invokes another workflow of the user through its core workflow-called listener
+const (
+	InvokeWorkflowTask = "invoke-workflow"
+	WorkflowCalledTask = "workflow-called"
+)
+
+// Transformer tasks which pause the execution
+const (
+	DelayTask     = "delay"
+	WaitUntilTask = "wait-until"
+)
+
 type WorkflowNodeType int
 
 const (
 	Listener WorkflowNodeType = iota
 	Action
 	Transformer
+	Condition
+	Join
+	Loop
 )
 
 func (nt WorkflowNodeType) String() string {
@@ -20,6 +41,12 @@ func (nt WorkflowNodeType) String() string {
 		return "action"
 	case Transformer:
 		return "transformer"
+	case Condition:
+		return "condition"
+	case Join:
+		return "join"
+	case Loop:
+		return "loop"
 	default:
 		panic("Invalid workflow node type")
 	}
@@ -34,6 +61,12 @@ func FromString(s string) WorkflowNodeType {
 		return Action
 	case "transformer":
 		return Transformer
+	case "condition":
+		return Condition
+	case "join":
+		return Join
+	case "loop":
+		return Loop
 	default:
 		panic("Invalid workflow node type")
 	}
@@ -50,6 +83,7 @@ type WorkflowNode struct {
 	TaskName   string
 	Type         WorkflowNodeType
 	Config       string // JSON encoded
+	Settings     string // JSON encoded NodeSettings
 	CredentialId *int32
 	Position     string // JSON encoded position { x: ..., y: ... }
 }
\ No newline at end of file
@@ -0,0 +1,216 @@
+package poller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Job is a listener node of an active workflow which is polled
+type Job struct {
+	NodeId       string
+	WorkflowId   int
+	UserId       int
+	CredentialId *int32
+	Config       string
+	Checkpoint   Checkpoint
+}
+
+// Checkpoint is what a listener remembers about a node between polls, kept in trigger_states
+type Checkpoint struct {
+	// Zero before the node was checked the first time. A check returns it to keep the time
+	// of an earlier check, zero stores now
+	LastCheckAt time.Time
+	// Id of the last item which fired, e.g. a Gmail message id
+	LastMessageId string
+	// Ids of the items which already fired, newest last, for sources which return several at once
+	SeenItems []string
+}
+
+// Poller checks the listener nodes of a service, the ones checked longest ago first
+type Poller struct {
+	Db          *sql.DB
+	ServiceName string
+	Interval    time.Duration
+	// How many nodes are checked per round, 0 checks all of them
+	BatchSize int
+	// Checks one node and returns its new checkpoint, nil when there is nothing to store.
+	// Nodes are checked one after the other, e.g. because of rate limits
+	Check func(ctx context.Context, job Job) (*Checkpoint, error)
+	// How long a node waits between its checks, nodes which are not due yet are skipped.
+	// Without it every node is checked every Interval
+	NodeInterval func(job Job) (time.Duration, error)
+}
+
+// Run polls every Interval until ctx is done
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Poll(ctx)
+		}
+	}
+}
+
+// Poll checks a round of nodes
+func (p *Poller) Poll(ctx context.Context) {
+	jobs, err := p.findJobs(ctx)
+	if err != nil {
+		log.Printf("DB Error: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		p.check(ctx, job)
+	}
+	log.Printf("Finished polling %d %s nodes for now", len(jobs), p.ServiceName)
+}
+
+func (p *Poller) check(ctx context.Context, job Job) {
+	if checkpoint := p.checkpointAfter(ctx, job); checkpoint != nil {
+		p.SaveCheckpoint(job.NodeId, *checkpoint)
+	}
+}
+
+// checkpointAfter checks the node when it is due and returns the checkpoint to store, nil to store none
+func (p *Poller) checkpointAfter(ctx context.Context, job Job) *Checkpoint {
+	firstCheck := job.Checkpoint.LastCheckAt.IsZero()
+	if p.NodeInterval != nil {
+		interval, err := p.NodeInterval(job)
+		if err != nil {
+			log.Printf("Invalid config of node %s: %v", job.NodeId, err)
+			return nil
+		}
+		if !firstCheck && time.Since(job.Checkpoint.LastCheckAt) < interval {
+			return nil
+		}
+	}
+
+	checkpoint, err := p.Check(ctx, job)
+	if err != nil {
+		log.Printf("Failed to check %s node %s: %v", p.ServiceName, job.NodeId, err)
+		// The checkpoint stays as it was. A node with its own interval waits for it before it is tried again,
+		// unless it has no baseline yet: everything would be new to the check after it
+		if p.NodeInterval == nil || firstCheck {
+			return nil
+		}
+		return &Checkpoint{LastMessageId: job.Checkpoint.LastMessageId, SeenItems: job.Checkpoint.SeenItems}
+	}
+	return checkpoint
+}
+
+func (p *Poller) findJobs(ctx context.Context) ([]Job, error) {
+	// Nodes which were never checked come first
+	query := `
+		SELECT n.id, n.workflow_id, workflows.user_id, n.credential_id, n.config,
+			t.last_check_at, COALESCE(t.last_message_id, ''), COALESCE(t.seen_items, '')
+		FROM workflow_nodes n
+		JOIN workflows ON n.workflow_id = workflows.id
+		LEFT JOIN trigger_states t ON n.id = t.node_id
+		WHERE n.type = 0
+		  AND n.service_name = ?
+		  AND workflows.active = TRUE
+		ORDER BY t.last_check_at ASC`
+	args := []interface{}{p.ServiceName}
+	if p.BatchSize > 0 {
+		query += " LIMIT ?"
+		args = append(args, p.BatchSize)
+	}
+
+	rows, err := p.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]Job, 0)
+	for rows.Next() {
+		var job Job
+		var lastCheckAt sql.NullTime
+		var seenItems string
+		err := rows.Scan(&job.NodeId, &job.WorkflowId, &job.UserId, &job.CredentialId, &job.Config,
+			&lastCheckAt, &job.Checkpoint.LastMessageId, &seenItems)
+		if err != nil {
+			log.Printf("Scan error: %v", err)
+			continue
+		}
+		if lastCheckAt.Valid {
+			job.Checkpoint.LastCheckAt = lastCheckAt.Time
+		}
+		if seenItems != "" {
+			if err := json.Unmarshal([]byte(seenItems), &job.Checkpoint.SeenItems); err != nil {
+				log.Printf("Invalid seen items of node %s: %v", job.NodeId, err)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveCheckpoint stores the checkpoint of a node, its last check is now unless the checkpoint has one
+func (p *Poller) SaveCheckpoint(nodeId string, checkpoint Checkpoint) {
+	lastCheckAt := checkpoint.LastCheckAt
+	if lastCheckAt.IsZero() {
+		lastCheckAt = time.Now()
+	}
+
+	var seenItems *string
+	if checkpoint.SeenItems != nil {
+		encoded, err := json.Marshal(checkpoint.SeenItems)
+		if err != nil {
+			log.Printf("Failed to encode seen items of %s: %v", nodeId, err)
+			return
+		}
+		value := string(encoded)
+		seenItems = &value
+	}
+
+	// Upsert
+	query := `
+		INSERT INTO trigger_states (node_id, last_check_at, last_message_id, seen_items)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			last_check_at = VALUES(last_check_at),
+			last_message_id = VALUES(last_message_id),
+			seen_items = VALUES(seen_items);
+	`
+	_, err := p.Db.Exec(query, nodeId, lastCheckAt.UTC(), checkpoint.LastMessageId, seenItems)
+	if err != nil {
+		log.Printf("Failed to update checkpoint for %s: %v", nodeId, err)
+	}
+}
+
+// RememberSeen returns the seen ids to keep after a check. The ids of the current check come last,
+// so they are never dropped, after the older ones which still fit into limit
+func RememberSeen(seen []string, current []string, limit int) []string {
+	isCurrent := make(map[string]bool, len(current))
+	ids := make([]string, 0, len(current))
+	for _, id := range current {
+		if !isCurrent[id] {
+			isCurrent[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	older := make([]string, 0, len(seen))
+	for _, id := range seen {
+		if !isCurrent[id] {
+			older = append(older, id)
+		}
+	}
+
+	limit = max(limit, len(ids))
+	if drop := len(older) + len(ids) - limit; drop > 0 {
+		older = older[drop:]
+	}
+	return append(older, ids...)
+}
@@ -0,0 +1,96 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckpointAfter(t *testing.T) {
+	seen := []string{"a", "b"}
+	stored := Checkpoint{LastCheckAt: time.Now().Add(-time.Minute), LastMessageId: "m1", SeenItems: seen}
+	checked := &Checkpoint{SeenItems: []string{"a", "b", "c"}}
+	failed := errors.New("status 500")
+	minute := func(job Job) (time.Duration, error) { return time.Minute - time.Second, nil }
+	hour := func(job Job) (time.Duration, error) { return time.Hour, nil }
+
+	tests := []struct {
+		name         string
+		checkpoint   Checkpoint
+		nodeInterval func(job Job) (time.Duration, error)
+		result       *Checkpoint
+		err          error
+		wantChecked  bool
+		want         *Checkpoint
+	}{
+		{name: "checked", checkpoint: stored, result: checked, wantChecked: true, want: checked},
+		{name: "nothing to store", checkpoint: stored, wantChecked: true},
+		{name: "due", checkpoint: stored, nodeInterval: minute, result: checked, wantChecked: true, want: checked},
+		{name: "not due", checkpoint: stored, nodeInterval: hour, result: checked},
+		{name: "first check is always due", nodeInterval: hour, result: checked, wantChecked: true, want: checked},
+		{
+			name:         "invalid config",
+			checkpoint:   stored,
+			nodeInterval: func(job Job) (time.Duration, error) { return 0, errors.New("invalid") },
+		},
+		{name: "failed without node interval", checkpoint: stored, err: failed, wantChecked: true},
+		{name: "failed first check", nodeInterval: minute, err: failed, wantChecked: true},
+		{
+			name:         "failed check keeps the checkpoint",
+			checkpoint:   stored,
+			nodeInterval: minute,
+			err:          failed,
+			wantChecked:  true,
+			want:         &Checkpoint{LastMessageId: "m1", SeenItems: seen},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			didCheck := false
+			p := &Poller{
+				ServiceName: "test",
+				Check: func(ctx context.Context, job Job) (*Checkpoint, error) {
+					didCheck = true
+					return test.result, test.err
+				},
+				NodeInterval: test.nodeInterval,
+			}
+
+			got := p.checkpointAfter(context.Background(), Job{NodeId: "node-1", Checkpoint: test.checkpoint})
+			if didCheck != test.wantChecked {
+				t.Errorf("checked = %v, want %v", didCheck, test.wantChecked)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("checkpointAfter() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRememberSeen(t *testing.T) {
+	tests := []struct {
+		name    string
+		seen    []string
+		current []string
+		limit   int
+		want    []string
+	}{
+		{name: "nothing seen", current: []string{"a", "b"}, limit: 5, want: []string{"a", "b"}},
+		{name: "current last", seen: []string{"a", "b", "c"}, current: []string{"b", "d"}, limit: 5, want: []string{"a", "c", "b", "d"}},
+		{name: "oldest dropped", seen: []string{"a", "b", "c"}, current: []string{"d"}, limit: 3, want: []string{"b", "c", "d"}},
+		{name: "current kept over limit", seen: []string{"a"}, current: []string{"b", "c", "d"}, limit: 2, want: []string{"b", "c", "d"}},
+		{name: "duplicates", current: []string{"a", "a", "b"}, limit: 5, want: []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := RememberSeen(test.seen, test.current, test.limit)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("RememberSeen(%v, %v, %d) = %v, want %v", test.seen, test.current, test.limit, got, test.want)
+			}
+		})
+	}
+}
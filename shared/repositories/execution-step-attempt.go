@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+type ExecutionStepAttempt struct {
+	Db *sql.DB
+}
+
+func (repo *ExecutionStepAttempt) Insert(attempt *models.ExecutionStepAttempt) error {
+	stmt, err := repo.Db.Prepare(`INSERT INTO
+	 execution_step_attempts(step_id, attempt, status, error, error_class, started_at, finished_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(
+		attempt.StepId,
+		attempt.Attempt,
+		attempt.Status,
+		nullableString(attempt.Error),
+		nullableString(attempt.ErrorClass),
+		attempt.StartedAt,
+		attempt.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	newId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	attempt.Id = int(newId)
+	return nil
+}
+
+// FindByExecutionId returns the attempts of all steps of an execution grouped by step id
+func (repo *ExecutionStepAttempt) FindByExecutionId(executionId int) (map[int][]models.ExecutionStepAttempt, error) {
+	stmt, err := repo.Db.Prepare(`SELECT a.id, a.created_at, a.step_id, a.attempt, a.status, a.error, a.error_class, a.started_at, a.finished_at
+	FROM execution_step_attempts a
+	JOIN execution_steps s ON a.step_id = s.id
+	WHERE s.execution_id = ?
+	ORDER BY a.step_id ASC, a.attempt ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(executionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := make(map[int][]models.ExecutionStepAttempt)
+	for rows.Next() {
+		var attempt models.ExecutionStepAttempt
+		var attemptError, errorClass sql.NullString
+		err := rows.Scan(
+			&attempt.Id,
+			&attempt.CreatedAt,
+			&attempt.StepId,
+			&attempt.Attempt,
+			&attempt.Status,
+			&attemptError,
+			&errorClass,
+			&attempt.StartedAt,
+			&attempt.FinishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attempt.Error = attemptError.String
+		attempt.ErrorClass = errorClass.String
+		attempts[attempt.StepId] = append(attempts[attempt.StepId], attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+type ExecutionStep struct {
+	Db *sql.DB
+}
+
+const executionStepColumns = "id, created_at, updated_at, execution_id, node_id, display_id, status, input, output, error, error_class, started_at, finished_at, simulated_request"
+
+func scanExecutionStep(row rowScanner) (*models.ExecutionStep, error) {
+	var step models.ExecutionStep
+	var input, output, stepError, errorClass, simulatedRequest sql.NullString
+	err := row.Scan(
+		&step.Id,
+		&step.CreatedAt,
+		&step.UpdatedAt,
+		&step.ExecutionId,
+		&step.NodeId,
+		&step.DisplayId,
+		&step.Status,
+		&input,
+		&output,
+		&stepError,
+		&errorClass,
+		&step.StartedAt,
+		&step.FinishedAt,
+		&simulatedRequest,
+	)
+	if err != nil {
+		return nil, err
+	}
+	step.Input = input.String
+	step.Output = output.String
+	step.Error = stepError.String
+	step.ErrorClass = errorClass.String
+	step.SimulatedRequest = simulatedRequest.String
+	return &step, nil
+}
+
+// Insert stores a step which has just started running
+func (repo *ExecutionStep) Insert(step *models.ExecutionStep) error {
+	stmt, err := repo.Db.Prepare(`INSERT INTO
+	 execution_steps(execution_id, node_id, display_id, status, input, output, error, started_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return err
+	}
+	defer stmt.Close()
+
+	if step.StartedAt.IsZero() {
+		step.StartedAt = time.Now().UTC()
+	}
+
+	res, err := stmt.Exec(
+		step.ExecutionId,
+		step.NodeId,
+		step.DisplayId,
+		step.Status,
+		nullableString(step.Input),
+		nullableString(step.Output),
+		nullableString(step.Error),
+		step.StartedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	newId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	step.Id = int(newId)
+	return nil
+}
+
+// SetOutput stores the output of a step which is still running or waiting
+func (repo *ExecutionStep) SetOutput(step *models.ExecutionStep) error {
+	_, err := repo.Db.Exec("UPDATE execution_steps SET output = ? WHERE id = ?", nullableString(step.Output), step.Id)
+	return err
+}
+
+// Finish stores the final status, input and output of a step
+func (repo *ExecutionStep) Finish(step *models.ExecutionStep) error {
+	finishedAt := time.Now().UTC()
+	_, err := repo.Db.Exec(
+		"UPDATE execution_steps SET status = ?, input = ?, output = ?, error = ?, error_class = ?, finished_at = ?, simulated_request = ? WHERE id = ?",
+		step.Status,
+		nullableString(step.Input),
+		nullableString(step.Output),
+		nullableString(step.Error),
+		nullableString(step.ErrorClass),
+		finishedAt,
+		nullableString(step.SimulatedRequest),
+		step.Id,
+	)
+	if err != nil {
+		return err
+	}
+	step.FinishedAt = &finishedAt
+	return nil
+}
+
+func (repo *ExecutionStep) FindByExecutionId(executionId int) ([]models.ExecutionStep, error) {
+	stmt, err := repo.Db.Prepare("SELECT " + executionStepColumns + " FROM execution_steps WHERE execution_id = ? ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(executionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := make([]models.ExecutionStep, 0)
+	for rows.Next() {
+		step, err := scanExecutionStep(rows)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, *step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
@@ -0,0 +1,302 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+type Execution struct {
+	Db *sql.DB
+}
+
+const executionColumns = "id, created_at, updated_at, workflow_id, listener_node_id, status, trigger_payload, output, error, started_at, finished_at, parent_execution_id, depth, parent_step_id, cancel_requested, dry_run, replay_of_execution_id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExecution(row rowScanner) (*models.Execution, error) {
+	var execution models.Execution
+	var triggerPayload, output, execError sql.NullString
+	var parentExecutionId, parentStepId, replayOfExecutionId sql.NullInt64
+	err := row.Scan(
+		&execution.Id,
+		&execution.CreatedAt,
+		&execution.UpdatedAt,
+		&execution.WorkflowId,
+		&execution.ListenerNodeId,
+		&execution.Status,
+		&triggerPayload,
+		&output,
+		&execError,
+		&execution.StartedAt,
+		&execution.FinishedAt,
+		&parentExecutionId,
+		&execution.Depth,
+		&parentStepId,
+		&execution.CancelRequested,
+		&execution.DryRun,
+		&replayOfExecutionId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	execution.TriggerPayload = triggerPayload.String
+	execution.Output = output.String
+	execution.Error = execError.String
+	if parentExecutionId.Valid {
+		parentId := int(parentExecutionId.Int64)
+		execution.ParentExecutionId = &parentId
+	}
+	if parentStepId.Valid {
+		stepId := int(parentStepId.Int64)
+		execution.ParentStepId = &stepId
+	}
+	if replayOfExecutionId.Valid {
+		replayOfId := int(replayOfExecutionId.Int64)
+		execution.ReplayOfExecutionId = &replayOfId
+	}
+	return &execution, nil
+}
+
+// JSON and TEXT columns are NULL instead of empty
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (repo *Execution) FindById(id int) (*models.Execution, error) {
+	stmt, err := repo.Db.Prepare("SELECT " + executionColumns + " FROM executions WHERE id = ?")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	execution, err := scanExecution(stmt.QueryRow(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFoundError{EntityName: "Execution"}
+		}
+		fmt.Println(err)
+		return nil, err
+	}
+	return execution, nil
+}
+
+type ExecutionFilter struct {
+	WorkflowId int
+	Status     *models.ExecutionStatus
+	From       *time.Time
+	To         *time.Time
+	// Only executions with a smaller id, used as the pagination cursor
+	BeforeId int
+	Limit    int
+}
+
+// FindByWorkflowId returns the executions of a workflow from the newest to the oldest
+func (repo *Execution) FindByWorkflowId(filter ExecutionFilter) ([]models.Execution, error) {
+	conditions := []string{"workflow_id = ?"}
+	params := []interface{}{filter.WorkflowId}
+
+	if filter.Status != nil {
+		conditions = append(conditions, "status = ?")
+		params = append(params, *filter.Status)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		params = append(params, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		params = append(params, filter.To.UTC())
+	}
+	if filter.BeforeId > 0 {
+		conditions = append(conditions, "id < ?")
+		params = append(params, filter.BeforeId)
+	}
+	params = append(params, filter.Limit)
+
+	query := "SELECT " + executionColumns + " FROM executions WHERE " + strings.Join(conditions, " AND ") + " ORDER BY id DESC LIMIT ?"
+	stmt, err := repo.Db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := make([]models.Execution, 0)
+	for rows.Next() {
+		execution, err := scanExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, *execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+func (repo *Execution) Insert(execution *models.Execution) error {
+	stmt, err := repo.Db.Prepare("INSERT INTO executions(workflow_id, listener_node_id, status, trigger_payload, parent_execution_id, depth, parent_step_id, dry_run, replay_of_execution_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(execution.WorkflowId, execution.ListenerNodeId, execution.Status, nullableString(execution.TriggerPayload), execution.ParentExecutionId, execution.Depth, execution.ParentStepId, execution.DryRun, execution.ReplayOfExecutionId)
+	if err != nil {
+		return err
+	}
+
+	newId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	newExecution, err := repo.FindById(int(newId))
+	if err != nil {
+		return err
+	}
+
+	*execution = *newExecution
+	return nil
+}
+
+func (repo *Execution) MarkRunning(id int) error {
+	_, err := repo.Db.Exec("UPDATE executions SET status = ?, started_at = COALESCE(started_at, ?) WHERE id = ?", models.Running, time.Now().UTC(), id)
+	return err
+}
+
+func (repo *Execution) SetOutput(id int, output string) error {
+	_, err := repo.Db.Exec("UPDATE executions SET output = ? WHERE id = ?", nullableString(output), id)
+	return err
+}
+
+func (repo *Execution) MarkWaiting(id int) error {
+	_, err := repo.Db.Exec("UPDATE executions SET status = ? WHERE id = ?", models.Waiting, id)
+	return err
+}
+
+// RequestCancel cancels an unfinished execution. Pending and waiting executions are finished right away,
+// a running one is stopped by its worker
+func (repo *Execution) RequestCancel(id int) error {
+	_, err := repo.Db.Exec(
+		"UPDATE executions SET cancel_requested = TRUE WHERE id = ? AND status IN (?, ?, ?)",
+		id, models.Pending, models.Running, models.Waiting,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Db.Exec(
+		"UPDATE executions SET status = ?, error = ?, finished_at = ? WHERE id = ? AND status IN (?, ?)",
+		models.Cancelled, "execution cancelled", time.Now().UTC(), id, models.Pending, models.Waiting,
+	)
+	return err
+}
+
+// IsCancelRequested is polled by the worker running the execution
+func (repo *Execution) IsCancelRequested(id int) (bool, error) {
+	var cancelRequested bool
+	err := repo.Db.QueryRow("SELECT cancel_requested FROM executions WHERE id = ?", id).Scan(&cancelRequested)
+	return cancelRequested, err
+}
+
+func (repo *Execution) Finish(id int, status models.ExecutionStatus, execError string) error {
+	_, err := repo.Db.Exec(
+		"UPDATE executions SET status = ?, error = ?, finished_at = ? WHERE id = ?",
+		status, nullableString(execError), time.Now().UTC(), id,
+	)
+	return err
+}
+
+// FindUnfinished returns the executions which were queued, started or paused but never finished
+func (repo *Execution) FindUnfinished() ([]models.Execution, error) {
+	stmt, err := repo.Db.Prepare("SELECT " + executionColumns + " FROM executions WHERE status IN (?, ?, ?) ORDER BY id ASC")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(models.Pending, models.Running, models.Waiting)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := make([]models.Execution, 0)
+	for rows.Next() {
+		execution, err := scanExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, *execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// FindByParentStep returns the execution which the invoke step started
+func (repo *Execution) FindByParentStep(stepId int) (*models.Execution, error) {
+	stmt, err := repo.Db.Prepare("SELECT " + executionColumns + " FROM executions WHERE parent_step_id = ?")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	execution, err := scanExecution(stmt.QueryRow(stepId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFoundError{EntityName: "Execution"}
+		}
+		return nil, err
+	}
+	return execution, nil
+}
+
+// FindChildren returns the executions of the sub-workflows which the execution invoked
+func (repo *Execution) FindChildren(parentId int) ([]models.Execution, error) {
+	stmt, err := repo.Db.Prepare("SELECT " + executionColumns + " FROM executions WHERE parent_execution_id = ? ORDER BY id ASC")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(parentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := make([]models.Execution, 0)
+	for rows.Next() {
+		execution, err := scanExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, *execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	errs "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/errors"
+	"github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/models"
+)
+
+type Webhook struct {
+	Db *sql.DB
+}
+
+func (repo *Webhook) Insert(webhook *models.Webhook) error {
+	stmt, err := repo.Db.Prepare("INSERT INTO webhooks(node_id, token) VALUES (?, ?)")
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(webhook.NodeId, webhook.Token)
+	return err
+}
+
+func (repo *Webhook) FindByNodeId(nodeId string) (*models.Webhook, error) {
+	return repo.findOne("SELECT node_id, created_at, token FROM webhooks WHERE node_id = ?", nodeId)
+}
+
+func (repo *Webhook) FindByToken(token string) (*models.Webhook, error) {
+	return repo.findOne("SELECT node_id, created_at, token FROM webhooks WHERE token = ?", token)
+}
+
+func (repo *Webhook) findOne(query string, arg string) (*models.Webhook, error) {
+	stmt, err := repo.Db.Prepare(query)
+	if err != nil {
+		fmt.Printf("Could not form prepared stmt\n")
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var webhook models.Webhook
+	err = stmt.QueryRow(arg).Scan(&webhook.NodeId, &webhook.CreatedAt, &webhook.Token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.NotFoundError{EntityName: "Webhook"}
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
@@ -16,7 +16,7 @@ type WorkflowNode struct {
 
 // TODO: Wrap these errors
 func (repo *WorkflowNode) FindById(id string) (*models.WorkflowNode, error) {
-	stmt, err := repo.Db.Prepare("SELECT id, created_at, updated_at, workflow_id, display_id, service_name, task_name, type, config, credential_id, position FROM workflow_nodes WHERE id = ?");
+	stmt, err := repo.Db.Prepare("SELECT id, created_at, updated_at, workflow_id, display_id, service_name, task_name, type, config, COALESCE(settings, '{}'), credential_id, position FROM workflow_nodes WHERE id = ?");
 	if err != nil {
 		fmt.Printf("Could not form prepared stmt\n");
 		return nil, err
@@ -32,6 +32,7 @@ func (repo *WorkflowNode) FindById(id string) (*models.WorkflowNode, error) {
 		&workflowNode.TaskName, 
 		&workflowNode.Type,
 		&workflowNode.Config,
+		&workflowNode.Settings,
 		&workflowNode.CredentialId,
 		&workflowNode.Position,
 	)
@@ -48,7 +49,7 @@ func (repo *WorkflowNode) FindById(id string) (*models.WorkflowNode, error) {
 }
 
 func (repo *WorkflowNode) FindByWorkflowId(id int) ([]models.WorkflowNode, error) {
-	stmt, err := repo.Db.Prepare("SELECT id, created_at, updated_at, workflow_id, display_id, service_name, task_name, type, config, credential_id, position FROM workflow_nodes WHERE workflow_id = ?");
+	stmt, err := repo.Db.Prepare("SELECT id, created_at, updated_at, workflow_id, display_id, service_name, task_name, type, config, COALESCE(settings, '{}'), credential_id, position FROM workflow_nodes WHERE workflow_id = ?");
 	if err != nil {
 		fmt.Printf("Could not form prepared stmt\n");
 		return nil, err
@@ -73,6 +74,7 @@ func (repo *WorkflowNode) FindByWorkflowId(id int) ([]models.WorkflowNode, error
 			&workflowNode.TaskName, 
 			&workflowNode.Type,
 			&workflowNode.Config,
+			&workflowNode.Settings,
 			&workflowNode.CredentialId,
 			&workflowNode.Position,
 		)
@@ -96,13 +98,13 @@ func (repo *WorkflowNode) FindByWorkflowId(id int) ([]models.WorkflowNode, error
 }
 
 func (repo *WorkflowNode) InsertMany(workflowNodes []models.WorkflowNode) error {
-	sql := "INSERT INTO workflow_nodes(id, workflow_id, service_name, task_name, type, config, credential_id, position) VALUES"
+	sql := "INSERT INTO workflow_nodes(id, display_id, workflow_id, service_name, task_name, type, config, settings, credential_id, position) VALUES"
 	var inserts []string
     var params []interface{}
 
     for _, node := range workflowNodes {
-        inserts = append(inserts, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
-        params = append(params, node.Id, node.DisplayId, node.WorkflowId, node.ServiceName, node.TaskName, node.Type, node.Config, node.CredentialId, node.Position)
+        inserts = append(inserts, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+        params = append(params, node.Id, node.DisplayId, node.WorkflowId, node.ServiceName, node.TaskName, node.Type, node.Config, nullableString(node.Settings), node.CredentialId, node.Position)
     }
 
     sql = sql + strings.Join(inserts, ",")
@@ -125,15 +127,15 @@ func (repo *WorkflowNode) InsertMany(workflowNodes []models.WorkflowNode) error
 
 func (repo *WorkflowNode) Insert(workflowNode *models.WorkflowNode) error {
 	stmt, err := repo.Db.Prepare(`INSERT INTO
-	 workflow_nodes(id, display_id, workflow_id, service_name, task_name, type, config, credential_id, position) 
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`);
+	 workflow_nodes(id, display_id, workflow_id, service_name, task_name, type, config, settings, credential_id, position) 
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`);
 	if err != nil {
 		fmt.Printf("Could not form prepared stmt\n");
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(workflowNode.Id, workflowNode.DisplayId, workflowNode.WorkflowId, workflowNode.ServiceName, workflowNode.TaskName, workflowNode.Type, workflowNode.Config, workflowNode.CredentialId, workflowNode.Position)
+	_, err = stmt.Exec(workflowNode.Id, workflowNode.DisplayId, workflowNode.WorkflowId, workflowNode.ServiceName, workflowNode.TaskName, workflowNode.Type, workflowNode.Config, nullableString(workflowNode.Settings), workflowNode.CredentialId, workflowNode.Position)
 	if err != nil {
 		fmt.Printf("Could not scan row/some other error\n");
 		return err
@@ -157,8 +159,8 @@ func (repo *WorkflowNode) Delete(id string) error {
 func (repo *WorkflowNode) Update(node *models.WorkflowNode) error {
     query := `
         UPDATE workflow_nodes 
-        SET service_name=?, task_name=?, type=?, config=?, credential_id=?, position=?, display_id=?, workflow_id=?
+        SET service_name=?, task_name=?, type=?, config=?, settings=?, credential_id=?, position=?, display_id=?, workflow_id=?
         WHERE id=?`
-    _, err := repo.Db.Exec(query, node.ServiceName, node.TaskName, node.Type, node.Config, node.CredentialId, node.Position, node.DisplayId, node.WorkflowId, node.Id)
+    _, err := repo.Db.Exec(query, node.ServiceName, node.TaskName, node.Type, node.Config, nullableString(node.Settings), node.CredentialId, node.Position, node.DisplayId, node.WorkflowId, node.Id)
     return err
 }
\ No newline at end of file
@@ -13,15 +13,17 @@ type Workflow struct {
 	Db *sql.DB
 }
 
+const workflowColumns = "id, created_at, updated_at, name, active, user_id, max_duration_seconds"
+
 // TODO: Wrap these errors
 func (repo *Workflow) FindById(id int) (*models.Workflow, error) {
-	stmt, err := repo.Db.Prepare("SELECT * FROM workflows WHERE id = ?");
+	stmt, err := repo.Db.Prepare("SELECT " + workflowColumns + " FROM workflows WHERE id = ?");
 	if err != nil {
 		fmt.Printf("Could not form prepared stmt\n");
 		return nil, err
 	}
 	var workflow models.Workflow
-	err = stmt.QueryRow(id).Scan(&workflow.Id, &workflow.CreatedAt, &workflow.UpdatedAt, &workflow.Name, &workflow.Active, &workflow.UserId)
+	err = stmt.QueryRow(id).Scan(&workflow.Id, &workflow.CreatedAt, &workflow.UpdatedAt, &workflow.Name, &workflow.Active, &workflow.UserId, &workflow.MaxDurationSeconds)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			fmt.Printf("No workflow found\n");
@@ -34,20 +36,20 @@ func (repo *Workflow) FindById(id int) (*models.Workflow, error) {
 	return &workflow, nil
 }
 
-func (repo *Workflow) Update(id int, name string) error {
-    _, err := repo.Db.Exec("UPDATE workflows SET name = ? WHERE id = ?", name, id)
+func (repo *Workflow) Update(id int, name string, maxDurationSeconds int) error {
+    _, err := repo.Db.Exec("UPDATE workflows SET name = ?, max_duration_seconds = ? WHERE id = ?", name, maxDurationSeconds, id)
     return err
 }
 
 func (repo *Workflow) Insert(workflow *models.Workflow) error {
-	stmt, err := repo.Db.Prepare("INSERT INTO workflows(name, active, user_id) VALUES (?, ?, ?)");
+	stmt, err := repo.Db.Prepare("INSERT INTO workflows(name, active, user_id, max_duration_seconds) VALUES (?, ?, ?, ?)");
 	if err != nil {
 		fmt.Printf("Could not form prepared stmt\n");
 		return err
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(workflow.Name, workflow.Active, workflow.UserId)
+	res, err := stmt.Exec(workflow.Name, workflow.Active, workflow.UserId, workflow.MaxDurationSeconds)
 	if err != nil {
 		fmt.Printf("Could not scan row/some other error\n");
 		return err
@@ -70,7 +72,7 @@ func (repo *Workflow) Insert(workflow *models.Workflow) error {
 }
 
 func (repo *Workflow) FindByUserId(userId int64) ([]models.Workflow, error) {
-	stmt, err := repo.Db.Prepare("SELECT * FROM workflows WHERE user_id = ? ORDER BY updated_at DESC")
+	stmt, err := repo.Db.Prepare("SELECT " + workflowColumns + " FROM workflows WHERE user_id = ? ORDER BY updated_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +87,7 @@ func (repo *Workflow) FindByUserId(userId int64) ([]models.Workflow, error) {
 	var workflows []models.Workflow
 	for rows.Next() {
 		var w models.Workflow
-		if err := rows.Scan(&w.Id, &w.CreatedAt, &w.UpdatedAt, &w.Name, &w.Active, &w.UserId); err != nil {
+		if err := rows.Scan(&w.Id, &w.CreatedAt, &w.UpdatedAt, &w.Name, &w.Active, &w.UserId, &w.MaxDurationSeconds); err != nil {
 			return nil, err
 		}
 		workflows = append(workflows, w)
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five field cron expression: minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	// A field restricted to some values, as opposed to *. When both day fields are restricted
+	// a day matching either of them is enough, like in cron
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var weekdayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// ParseCron reads an expression like "0 9 * * MON-FRI". Fields take *, values, ranges, steps and lists,
+// months and weekdays also take their names and 7 is Sunday too. @daily, @hourly etc. are supported
+func ParseCron(expression string) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expression, len(fields))
+	}
+
+	schedule := &CronSchedule{}
+	if err := parseCronField(fields[0], 0, 59, nil, schedule.minutes[:]); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, nil, schedule.hours[:]); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, nil, schedule.days[:]); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, monthNames, schedule.months[:]); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	// 0-7 so both 0 and 7 are Sunday
+	var weekdays [8]bool
+	if err := parseCronField(fields[4], 0, 7, weekdayNames, weekdays[:]); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	copy(schedule.weekdays[:], weekdays[:7])
+	schedule.weekdays[0] = schedule.weekdays[0] || weekdays[7]
+
+	schedule.daysRestricted = !strings.HasPrefix(fields[2], "*")
+	schedule.weekdaysRestricted = !strings.HasPrefix(fields[4], "*")
+	return schedule, nil
+}
+
+func parseCronField(field string, min int, max int, names map[string]int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = parseCronValue(from, min, max, names)
+			if err != nil {
+				return err
+			}
+			end = start
+			if isRange {
+				end, err = parseCronValue(to, min, max, names)
+				if err != nil {
+					return err
+				}
+			} else if hasStep {
+				// 5/15 runs from 5 to the end of the field
+				end = max
+			}
+			if start > end {
+				return fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+func parseCronValue(value string, min int, max int, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToUpper(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if number < min || number > max {
+		return 0, fmt.Errorf("%d is out of range %d-%d", number, min, max)
+	}
+	return number, nil
+}
+
+// Next returns the first time after the given one which matches the schedule, in the location of after.
+// It is the zero time when nothing matches within five years, e.g. for "0 0 31 2 *".
+// Times skipped when the clocks go forward don't match, times repeated when they go back match twice
+func (schedule *CronSchedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Year() + 5
+
+	// Every loop moves to the start of the next month, day, hour or minute. When that rolls over
+	// into the next larger unit, the larger units are checked again. Hours and minutes are added
+	// rather than set with time.Date, which may go back when the clocks change
+wrap:
+	for t.Year() <= limit {
+		for !schedule.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			if t.Month() == time.January {
+				continue wrap
+			}
+		}
+		for !schedule.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			if t.Day() == 1 {
+				continue wrap
+			}
+		}
+		for !schedule.hours[t.Hour()] {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			if t.Hour() == 0 {
+				continue wrap
+			}
+		}
+		for !schedule.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			if t.Minute() == 0 {
+				continue wrap
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	day := schedule.days[t.Day()]
+	weekday := schedule.weekdays[t.Weekday()]
+	if schedule.daysRestricted && schedule.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
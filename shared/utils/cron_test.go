@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "* * * * *"},
+		{expression: "0 9 * * MON-FRI"},
+		{expression: "*/15 0-6,22,23 1,15 JAN-jun 0,7"},
+		{expression: "5/20 * * * *"},
+		{expression: "@daily"},
+		{expression: "@Weekly"},
+		{expression: "* * * *", wantErr: true},
+		{expression: "60 * * * *", wantErr: true},
+		{expression: "* 24 * * *", wantErr: true},
+		{expression: "* * 0 * *", wantErr: true},
+		{expression: "* * * 13 *", wantErr: true},
+		{expression: "* * * * 8", wantErr: true},
+		{expression: "10-5 * * * *", wantErr: true},
+		{expression: "*/0 * * * *", wantErr: true},
+		{expression: "* * * FOO *", wantErr: true},
+		{expression: "@sometimes", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := ParseCron(test.expression)
+			if test.wantErr && err == nil {
+				t.Errorf("ParseCron(%q) succeeded, want an error", test.expression)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("ParseCron(%q) returned %v", test.expression, err)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		// Both in UTC, so the times around DST changes are unambiguous
+		after string
+		want  string
+	}{
+		{name: "next minute", expression: "* * * * *", after: "2024-03-01T10:07:00Z", want: "2024-03-01T10:08:00Z"},
+		{name: "seconds are dropped", expression: "30 10 * * *", after: "2024-03-01T10:29:30Z", want: "2024-03-01T10:30:00Z"},
+		{name: "strictly after", expression: "30 10 * * *", after: "2024-03-01T10:30:00Z", want: "2024-03-02T10:30:00Z"},
+		{name: "step", expression: "*/15 * * * *", after: "2024-03-01T10:07:00Z", want: "2024-03-01T10:15:00Z"},
+		{name: "hour rolls over", expression: "*/15 * * * *", after: "2024-03-01T10:50:00Z", want: "2024-03-01T11:00:00Z"},
+		{name: "weekdays skip the weekend", expression: "0 9 * * MON-FRI", after: "2024-03-01T10:00:00Z", want: "2024-03-04T09:00:00Z"},
+		{name: "7 is sunday", expression: "0 0 * * 7", after: "2024-03-01T10:00:00Z", want: "2024-03-03T00:00:00Z"},
+		{name: "first of next month", expression: "0 0 1 * *", after: "2024-01-31T12:00:00Z", want: "2024-02-01T00:00:00Z"},
+		{name: "month without the day", expression: "0 0 31 * *", after: "2024-04-01T00:00:00Z", want: "2024-05-31T00:00:00Z"},
+		{name: "year rolls over", expression: "0 0 1 1 *", after: "2024-12-31T23:59:00Z", want: "2025-01-01T00:00:00Z"},
+		{name: "leap day", expression: "0 0 29 2 *", after: "2024-03-01T00:00:00Z", want: "2028-02-29T00:00:00Z"},
+		{name: "month and weekday", expression: "0 0 * FEB MON", after: "2024-03-01T00:00:00Z", want: "2025-02-03T00:00:00Z"},
+		{name: "day or weekday", expression: "0 0 13 * FRI", after: "2024-09-01T00:00:00Z", want: "2024-09-06T00:00:00Z"},
+		{name: "day or weekday, day first", expression: "0 0 13 * FRI", after: "2024-09-07T00:00:00Z", want: "2024-09-13T00:00:00Z"},
+		{name: "never", expression: "0 0 31 2 *", after: "2024-01-01T00:00:00Z", want: ""},
+
+		// 2024-03-10 02:00 EST -> 03:00 EDT
+		{name: "spring forward skips the missing hour", expression: "0 * * * *", location: newYork, after: "2024-03-10T06:00:00Z", want: "2024-03-10T07:00:00Z"},
+		{name: "spring forward skips the missing time", expression: "30 2 * * *", location: newYork, after: "2024-03-10T05:00:00Z", want: "2024-03-11T06:30:00Z"},
+		{name: "spring forward keeps wall clock", expression: "0 9 * * *", location: newYork, after: "2024-03-09T15:00:00Z", want: "2024-03-10T13:00:00Z"},
+		// 2024-11-03 02:00 EDT -> 01:00 EST
+		{name: "fall back first occurrence", expression: "30 1 * * *", location: newYork, after: "2024-11-03T04:00:00Z", want: "2024-11-03T05:30:00Z"},
+		{name: "fall back second occurrence", expression: "30 1 * * *", location: newYork, after: "2024-11-03T05:30:00Z", want: "2024-11-03T06:30:00Z"},
+		{name: "fall back within the repeated hour", expression: "* * * * *", location: newYork, after: "2024-11-03T06:45:00Z", want: "2024-11-03T06:46:00Z"},
+		{name: "fall back end of the repeated hour", expression: "0 2 * * *", location: newYork, after: "2024-11-03T06:59:00Z", want: "2024-11-03T07:00:00Z"},
+		{name: "fall back after the repeated time", expression: "15 1 * * *", location: newYork, after: "2024-11-03T06:30:00Z", want: "2024-11-04T06:15:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			location := test.location
+			if location == nil {
+				location = time.UTC
+			}
+			after, err := time.Parse(time.RFC3339, test.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after = after.In(location)
+
+			got := schedule.Next(after)
+			if test.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%v) = %v, want the zero time", after, got)
+				}
+				return
+			}
+			want, err := time.Parse(time.RFC3339, test.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("Next(%v) = %v, want %v", after, got, want.In(location))
+			}
+			if got.Location() != location {
+				t.Errorf("Next(%v) is in %v, want %v", after, got.Location(), location)
+			}
+		})
+	}
+}
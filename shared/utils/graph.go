@@ -2,9 +2,8 @@ package utils
 
 import "fmt"
 
-// TODO: This only works for one single listener in the whole worflow
+// Sorts the whole graph, use Subgraph first to sort only the part which runs after one listener
 func TopologicalSort(graph map[string][]string) ([]string, error) {
-	fmt.Println(graph)
     inDegree := make(map[string]int)
     
     for node := range graph {
@@ -47,4 +46,38 @@ func TopologicalSort(graph map[string][]string) ([]string, error) {
     }
 
     return sortedOrder, nil
+}
+
+// ReachableFrom returns every node which can be reached from start, including start itself
+func ReachableFrom(graph map[string][]string, start string) map[string]bool {
+    reachable := map[string]bool{start: true}
+    stack := []string{start}
+
+    for len(stack) > 0 {
+        current := stack[len(stack)-1]
+        stack = stack[:len(stack)-1]
+
+        for _, neighbor := range graph[current] {
+            if !reachable[neighbor] {
+                reachable[neighbor] = true
+                stack = append(stack, neighbor)
+            }
+        }
+    }
+
+    return reachable
+}
+
+// Subgraph keeps only the given nodes and the edges between them
+func Subgraph(graph map[string][]string, nodes map[string]bool) map[string][]string {
+    subgraph := make(map[string][]string)
+    for node := range nodes {
+        subgraph[node] = []string{}
+        for _, neighbor := range graph[node] {
+            if nodes[neighbor] {
+                subgraph[node] = append(subgraph[node], neighbor)
+            }
+        }
+    }
+    return subgraph
 }
\ No newline at end of file
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed path like $.data.items, $.results[0].id or $['first name'].
+// A trailing [*] selects the array itself, so $.items[*] and $.items are the same
+type JSONPath struct {
+	// string keys and int indices
+	steps []interface{}
+}
+
+func ParseJSONPath(path string) (*JSONPath, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+	path = strings.TrimSuffix(path, "[*]")
+
+	parsed := &JSONPath{}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in JSONPath %q", path)
+			}
+			parsed.steps = append(parsed.steps, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed [ in JSONPath %q", path)
+			}
+			inside := rest[1:end]
+			rest = rest[end+1:]
+			if len(inside) >= 2 && (inside[0] == '\'' || inside[0] == '"') && inside[len(inside)-1] == inside[0] {
+				parsed.steps = append(parsed.steps, inside[1:len(inside)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inside)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index %q in JSONPath %q", inside, path)
+			}
+			parsed.steps = append(parsed.steps, index)
+		default:
+			return nil, fmt.Errorf("unexpected %q in JSONPath %q", rest[0], path)
+		}
+	}
+	return parsed, nil
+}
+
+// Get returns the value at the path in a decoded JSON document and if it exists
+func (path *JSONPath) Get(document interface{}) (interface{}, bool) {
+	current := document
+	for _, step := range path.steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			current, ok = object[step]
+			if !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := current.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, false
+			}
+			current = array[step]
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "$"},
+		{path: "$.data.items"},
+		{path: "$.results[0].id"},
+		{path: "$['first name']"},
+		{path: `$["first name"].x`},
+		{path: "$.items[*]"},
+		{path: " $.padded "},
+		{path: "data.items", wantErr: true},
+		{path: "$..items", wantErr: true},
+		{path: "$.", wantErr: true},
+		{path: "$.items[0", wantErr: true},
+		{path: "$.items[-1]", wantErr: true},
+		{path: "$.items[a]", wantErr: true},
+		{path: "$.items[*].id", wantErr: true},
+		{path: "$items", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := ParseJSONPath(test.path)
+			if test.wantErr && err == nil {
+				t.Errorf("ParseJSONPath(%q) succeeded, want an error", test.path)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("ParseJSONPath(%q) returned %v", test.path, err)
+			}
+		})
+	}
+}
+
+func TestJSONPathGet(t *testing.T) {
+	var document interface{}
+	err := json.Unmarshal([]byte(`{
+		"data": {
+			"items": [{"id": 1, "title": "a"}, {"id": 2, "title": null}],
+			"first name": "Ann",
+			"empty": []
+		},
+		"count": 2
+	}`), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		want   interface{}
+		wantOk bool
+	}{
+		{name: "root", path: "$", want: document, wantOk: true},
+		{name: "key", path: "$.count", want: float64(2), wantOk: true},
+		{name: "nested key", path: "$.data.empty", want: []interface{}{}, wantOk: true},
+		{name: "index", path: "$.data.items[1].id", want: float64(2), wantOk: true},
+		{name: "null value", path: "$.data.items[1].title", want: nil, wantOk: true},
+		{name: "quoted key", path: "$.data['first name']", want: "Ann", wantOk: true},
+		{name: "whole array", path: "$.data.empty[*]", want: []interface{}{}, wantOk: true},
+		{name: "missing key", path: "$.data.total"},
+		{name: "index out of range", path: "$.data.items[2]"},
+		{name: "key of array", path: "$.data.items.id"},
+		{name: "index of object", path: "$.data[0]"},
+		{name: "key of number", path: "$.count.value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, err := ParseJSONPath(test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := path.Get(document)
+			if ok != test.wantOk {
+				t.Fatalf("Get(%q) = %v, %v, want ok %v", test.path, got, ok, test.wantOk)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Get(%q) = %#v, want %#v", test.path, got, test.want)
+			}
+		})
+	}
+}
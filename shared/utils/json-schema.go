@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// JSONSchema is the part of JSON Schema the task catalog uses to describe configs and outputs
+type JSONSchema struct {
+	// A type name or a list of them
+	Type                 interface{}            `json:"type,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+}
+
+// ValidateJSON checks a JSON document against a schema. Strings holding a {{variable}} are accepted
+// for any type, they are only resolved when the node runs
+func ValidateJSON(schemaJSON string, document string) error {
+	if schemaJSON == "" {
+		return nil
+	}
+	var schema JSONSchema
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+
+	var value interface{}
+	if document == "" {
+		document = "{}"
+	}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return schema.validate(value, "")
+}
+
+func (schema *JSONSchema) validate(value interface{}, path string) error {
+	if text, ok := value.(string); ok && strings.Contains(text, "{{") {
+		return nil
+	}
+
+	if types := schema.types(); len(types) > 0 && !slices.ContainsFunc(types, func(name string) bool { return hasJSONType(value, name) }) {
+		return fmt.Errorf("%s must be %s", fieldName(path), strings.Join(types, " or "))
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(option interface{}) bool { return fmt.Sprint(option) == fmt.Sprint(value) }) {
+		return fmt.Errorf("%s must be one of %v", fieldName(path), schema.Enum)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s is required", fieldName(joinPath(path, name)))
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s is not allowed", fieldName(joinPath(path, name)))
+				}
+				continue
+			}
+			if err := property.validate(value[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for index, item := range value {
+				if err := schema.Items.validate(item, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (schema *JSONSchema) types() []string {
+	switch t := schema.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func hasJSONType(value interface{}, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldName(path string) string {
+	if path == "" {
+		return "the value"
+	}
+	return path
+}
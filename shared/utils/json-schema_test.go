@@ -0,0 +1,69 @@
+package utils
+
+import "testing"
+
+func TestValidateJSON(t *testing.T) {
+	const config = `{
+		"type": "object",
+		"required": ["to", "subject"],
+		"additionalProperties": false,
+		"properties": {
+			"to": {"type": "string"},
+			"subject": {"type": "string"},
+			"priority": {"type": "string", "enum": ["low", "high"]},
+			"retries": {"type": "integer"},
+			"ratio": {"type": "number"},
+			"draft": {"type": "boolean"},
+			"reply_to": {"type": ["string", "null"]},
+			"labels": {"type": "array", "items": {"type": "string"}},
+			"headers": {"type": "object", "properties": {"id": {"type": "integer"}}}
+		}
+	}`
+
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		// The error message, empty when the document is valid
+		wantErr string
+	}{
+		{name: "valid", schema: config, document: `{"to": "a@example.com", "subject": "Hi", "priority": "high", "retries": 3, "ratio": 0.5, "draft": false}`},
+		{name: "no schema", document: `[1, "anything"]`},
+		{name: "empty document is an empty object", schema: `{"type": "object"}`},
+		{name: "missing required", schema: config, document: `{"to": "a@example.com"}`, wantErr: "subject is required"},
+		{name: "wrong type", schema: config, document: `{"to": 5, "subject": "Hi"}`, wantErr: "to must be string"},
+		{name: "root type", schema: config, document: `[]`, wantErr: "the value must be object"},
+		{name: "integer", schema: config, document: `{"to": "a", "subject": "b", "retries": 1.5}`, wantErr: "retries must be integer"},
+		{name: "number accepts integers", schema: config, document: `{"to": "a", "subject": "b", "ratio": 2}`},
+		{name: "boolean", schema: config, document: `{"to": "a", "subject": "b", "draft": "yes"}`, wantErr: "draft must be boolean"},
+		{name: "one of several types", schema: config, document: `{"to": "a", "subject": "b", "reply_to": null}`},
+		{name: "none of several types", schema: config, document: `{"to": "a", "subject": "b", "reply_to": 1}`, wantErr: "reply_to must be string or null"},
+		{name: "enum", schema: config, document: `{"to": "a", "subject": "b", "priority": "urgent"}`, wantErr: "priority must be one of [low high]"},
+		{name: "additional property", schema: config, document: `{"to": "a", "subject": "b", "cc": "c"}`, wantErr: "cc is not allowed"},
+		{name: "additional properties allowed by default", schema: `{"type": "object", "properties": {"a": {"type": "string"}}}`, document: `{"b": 1}`},
+		{name: "items", schema: config, document: `{"to": "a", "subject": "b", "labels": ["x", 2]}`, wantErr: "labels[1] must be string"},
+		{name: "nested property", schema: config, document: `{"to": "a", "subject": "b", "headers": {"id": "x"}}`, wantErr: "headers.id must be integer"},
+		{name: "first invalid field in name order", schema: config, document: `{"to": 1, "subject": 2}`, wantErr: "subject must be string"},
+		{name: "variable for a string", schema: config, document: `{"to": "{{trigger.email}}", "subject": "Re: {{trigger.subject}}"}`},
+		{name: "variable for another type", schema: config, document: `{"to": "a", "subject": "b", "retries": "{{steps.count}}", "labels": "{{trigger.labels}}"}`},
+		{name: "variable outside the enum", schema: config, document: `{"to": "a", "subject": "b", "priority": "{{trigger.priority}}"}`},
+		{name: "variable does not satisfy required", schema: config, document: `{"to": "{{trigger.email}}"}`, wantErr: "subject is required"},
+		{name: "invalid schema", schema: `{"type": `, document: `{}`, wantErr: "invalid schema: unexpected end of JSON input"},
+		{name: "invalid document", schema: config, document: `{"to": }`, wantErr: "invalid JSON: invalid character '}' looking for beginning of value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateJSON(test.schema, test.document)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateJSON() returned %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("ValidateJSON() returned %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	pb "github.com/Peshka564/WAS-WorkflowAutomationSystem/shared/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// How long to wait before trying to register again when the orchestrator is not up yet
+const registerRetryInterval = 5 * time.Second
+
+// Register tells the orchestrator where the worker runs. It keeps trying until the orchestrator is up.
+// The secret is taken from WORKER_REGISTRATION_SECRET unless the registration has one
+func Register(orchestratorAddress string, registration *pb.RegisterWorkerRequest) {
+	if registration.Secret == "" {
+		registration.Secret = os.Getenv("WORKER_REGISTRATION_SECRET")
+	}
+
+	conn, err := grpc.NewClient(orchestratorAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Printf("Failed to connect to the orchestrator: %v", err)
+		return
+	}
+	defer conn.Close()
+	client := pb.NewOrchestratorClient(conn)
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.RegisterWorker(ctx, registration)
+		cancel()
+		if err == nil {
+			log.Printf("Registered %s with the orchestrator at %s", registration.ServiceName, orchestratorAddress)
+			return
+		}
+		// Trying again doesn't help with a missing or wrong secret
+		if code := status.Code(err); code == codes.PermissionDenied || code == codes.Unauthenticated {
+			log.Printf("The orchestrator refused to register %s: %v", registration.ServiceName, err)
+			return
+		}
+		log.Printf("Failed to register with the orchestrator, retrying: %v", err)
+		time.Sleep(registerRetryInterval)
+	}
+}
+
+// Getenv returns the environment variable or fallback when it is not set
+func Getenv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}